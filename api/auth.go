@@ -0,0 +1,47 @@
+package api
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/auth"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/scrap"
+)
+
+// RequiresAuthentication is the API's auth.Policy: anything but reading, like creating books and scrapping, requires
+// authentication. Reading does too unless publicReads is set, health and the spec never do
+func RequiresAuthentication(publicReads bool) auth.Policy {
+	return func(request events.APIGatewayProxyRequest) bool {
+		path := strings.TrimSuffix(request.Path, "/")
+
+		switch {
+		case path == "/health", path == "/openapi.json":
+			return false
+		case request.HTTPMethod != "GET" && request.HTTPMethod != "HEAD":
+			return true
+		case path == "/books" && scrap.WorkingModeFromString(request.QueryStringParameters["mode"]) != scrap.RetrieveAll:
+			return true
+		default:
+			return !publicReads
+		}
+	}
+}
+
+// Authentication creates the middleware authenticating requests to handlers, see auth.NewAuthenticatorFromEnv.
+// Reading stays public unless AUTH_PUBLIC_READS is false
+func Authentication() (middleware.Middleware, error) {
+	authenticator, err := auth.NewAuthenticatorFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	publicReads, err := strconv.ParseBool(os.Getenv("AUTH_PUBLIC_READS"))
+	if err != nil {
+		publicReads = true
+	}
+
+	return auth.Middleware(authenticator, RequiresAuthentication(publicReads)), nil
+}
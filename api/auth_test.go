@@ -0,0 +1,31 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequiresAuthentication(t *testing.T) {
+	requests := []struct {
+		request     events.APIGatewayProxyRequest
+		publicReads bool
+		required    bool
+	}{
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/health"}, false, false},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/openapi.json"}, false, false},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/book/1"}, true, false},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/book/1"}, false, true},
+		{events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/book"}, true, true},
+		{events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/scrap/jobs"}, true, true},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/scrap/jobs/1"}, true, false},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/books"}, true, false},
+		{events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/books/", QueryStringParameters: map[string]string{"mode": "scrap_and_store"}}, true, true},
+	}
+
+	for _, test := range requests {
+		required := RequiresAuthentication(test.publicReads)(test.request)
+		assert.Equal(t, test.required, required, "%s %s %v", test.request.HTTPMethod, test.request.Path, test.request.QueryStringParameters)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/tracing"
+)
+
+// Bootstrap configures tracing and creates the middleware every binary serving the API wraps its handler with:
+// middleware.Standard, failed authentication limiting, authentication and rate limiting, in that order. Rate limit
+// buckets are kept in rateLimitStore unless RATE_LIMIT_STORE says otherwise, see RateLimiting
+func Bootstrap(rateLimitStore string) (middleware.Middleware, error) {
+	if err := tracing.Configure(); err != nil {
+		return nil, fmt.Errorf("Could not configure tracing: %s", err.Error())
+	}
+
+	authenticate, err := Authentication()
+	if err != nil {
+		return nil, fmt.Errorf("Could not configure authentication: %s", err.Error())
+	}
+
+	rateLimit, err := RateLimiting(rateLimitStore)
+	if err != nil {
+		return nil, fmt.Errorf("Could not configure rate limiting: %s", err.Error())
+	}
+
+	limitFailures, err := FailedAuthenticationLimiting(rateLimitStore)
+	if err != nil {
+		return nil, fmt.Errorf("Could not configure failed authentication limiting: %s", err.Error())
+	}
+
+	return func(handler middleware.HandlerFunc) middleware.HandlerFunc {
+		return middleware.Standard(limitFailures(authenticate(rateLimit(handler))))
+	}, nil
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootstrapWrapsHandler(t *testing.T) {
+	serve, err := Bootstrap("memory")
+	assert.Equal(t, nil, err)
+
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: `{"status":"ok"}`}, nil
+	}
+
+	response, err := serve(handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/health"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, response.StatusCode)
+	assert.NotEmpty(t, response.Headers["X-Request-Id"])
+}
+
+func TestBootstrapFailsWithUnknownRateLimitStore(t *testing.T) {
+	os.Setenv("RATE_LIMIT_STORE", "redis")
+	defer os.Unsetenv("RATE_LIMIT_STORE")
+
+	_, err := Bootstrap("memory")
+	assert.EqualError(t, err, `Could not configure rate limiting: RATE_LIMIT_STORE must be db or memory, got "redis"`)
+}
+
+func TestBootstrapKeepsRateLimitsInMemoryWithoutDatabase(t *testing.T) {
+	os.Setenv("DB_DRIVER", "memory")
+	defer os.Unsetenv("DB_DRIVER")
+
+	_, err := Bootstrap("db")
+	assert.Equal(t, nil, err)
+
+	os.Setenv("RATE_LIMIT_STORE", "db")
+	defer os.Unsetenv("RATE_LIMIT_STORE")
+
+	_, err = Bootstrap("db")
+	assert.EqualError(t, err, "Could not configure rate limiting: RATE_LIMIT_STORE can't be db when DB_DRIVER is memory, there's no database")
+}
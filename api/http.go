@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+)
+
+// maxBodySize is the largest request body served, it's the most a Lambda function can be invoked with
+const maxBodySize = 6 << 20
+
+// NewHTTPHandler serves handler over net/http, handler usually being a Router's. Each http.Request is translated into
+// the APIGatewayProxyRequest API Gateway would have sent, with no resource since it's up to handler to find it, and
+// handler's response back. That way handlers behave the same with or without Lambda
+func NewHTTPHandler(handler HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request, err := toProxyRequest(r)
+		if err != nil {
+			tooLarge := apierror.New(413, apierror.RequestTooLarge, fmt.Sprintf("Body cannot be larger than %d bytes", maxBodySize))
+			writeProxyResponse(w, tooLarge.Response(events.APIGatewayProxyRequest{
+				Headers: map[string]string{middleware.RequestIDHeader: r.Header.Get(middleware.RequestIDHeader)},
+			}))
+			return
+		}
+
+		response, err := handler(r.Context(), request)
+		if err != nil {
+			// API Gateway answers the same when a function fails
+			log.Printf("%s %s failed: %s", r.Method, r.URL.Path, err.Error())
+			writeJSON(w, http.StatusBadGateway, `{"message": "Internal server error"}`)
+			return
+		}
+
+		writeProxyResponse(w, response)
+	})
+}
+
+// toProxyRequest translates r into the request API Gateway sends, bodies that aren't UTF-8 are base64 encoded like
+// API Gateway does
+func toProxyRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	request := events.APIGatewayProxyRequest{
+		Path:       r.URL.Path,
+		HTTPMethod: r.Method,
+		Body:       string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			HTTPMethod: r.Method,
+			Path:       r.URL.Path,
+		},
+	}
+
+	if !utf8.Valid(body) {
+		request.Body = base64.StdEncoding.EncodeToString(body)
+		request.IsBase64Encoded = true
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		request.RequestContext.Identity.SourceIP = host
+	}
+
+	if len(r.Header) > 0 {
+		request.Headers = make(map[string]string)
+		request.MultiValueHeaders = make(map[string][]string)
+		for name, values := range r.Header {
+			request.Headers[name] = values[len(values)-1]
+			request.MultiValueHeaders[name] = values
+		}
+	}
+
+	if query := r.URL.Query(); len(query) > 0 {
+		request.QueryStringParameters = make(map[string]string)
+		request.MultiValueQueryStringParameters = make(map[string][]string)
+		for name, values := range query {
+			request.QueryStringParameters[name] = values[len(values)-1]
+			request.MultiValueQueryStringParameters[name] = values
+		}
+	}
+
+	return request, nil
+}
+
+func writeProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	// API Gateway's default content type
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, `{"message": "Internal server error"}`)
+			return
+		}
+
+		body = decoded
+	}
+
+	if response.StatusCode == 0 {
+		// API Gateway rejects responses without status code too
+		writeJSON(w, http.StatusBadGateway, `{"message": "Internal server error"}`)
+		return
+	}
+
+	w.WriteHeader(response.StatusCode)
+	w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(body))
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// recordingRoutes answers every request with response and keeps the last request it got
+func recordingRoutes(response events.APIGatewayProxyResponse, err error) ([]Route, *events.APIGatewayProxyRequest) {
+	received := &events.APIGatewayProxyRequest{}
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		*received = request
+		return response, err
+	}
+
+	return []Route{
+		{Method: "POST", Path: "/book", Handler: handler},
+		{Method: "GET", Path: "/book/{id}", Handler: handler},
+		{Method: "DELETE", Path: "/book/{id}", Handler: handler},
+	}, received
+}
+
+func TestHTTPHandlerTranslatesRequestAndResponse(t *testing.T) {
+	routes, received := recordingRoutes(events.APIGatewayProxyResponse{
+		StatusCode:        201,
+		Body:              `{"book_id": 7}`,
+		Headers:           map[string]string{"Location": "/book/7"},
+		MultiValueHeaders: map[string][]string{"Vary": {"Accept", "Origin"}},
+	}, nil)
+
+	request := httptest.NewRequest("POST", "/book?merge=overwrite&merge=fill_empty", strings.NewReader(`{"title": "Kotlin in Action"}`))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+
+	NewHTTPHandler(NewRouter(routes).Handle).ServeHTTP(recorder, request)
+
+	assert.Equal(t, "/book", received.Resource)
+	assert.Equal(t, "POST", received.HTTPMethod)
+	assert.Equal(t, "/book", received.Path)
+	assert.Equal(t, `{"title": "Kotlin in Action"}`, received.Body)
+	assert.False(t, received.IsBase64Encoded)
+	assert.Equal(t, map[string]string{"merge": "fill_empty"}, received.QueryStringParameters)
+	assert.Equal(t, map[string][]string{"merge": {"overwrite", "fill_empty"}}, received.MultiValueQueryStringParameters)
+	assert.Equal(t, "application/json", received.Headers["Content-Type"])
+	assert.Equal(t, "192.0.2.1", received.RequestContext.Identity.SourceIP)
+	assert.Nil(t, received.PathParameters)
+
+	assert.Equal(t, 201, recorder.Code)
+	assert.Equal(t, `{"book_id": 7}`, recorder.Body.String())
+	assert.Equal(t, "/book/7", recorder.Header().Get("Location"))
+	assert.Equal(t, []string{"Accept", "Origin"}, recorder.Header()["Vary"])
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+}
+
+func TestHTTPHandlerExtractsPathParameters(t *testing.T) {
+	routes, received := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+
+	NewHTTPHandler(NewRouter(routes).Handle).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/book/42", nil))
+
+	assert.Equal(t, "/book/{id}", received.Resource)
+	assert.Equal(t, map[string]string{"id": "42"}, received.PathParameters)
+}
+
+func TestHTTPHandlerEncodesBinaryBodies(t *testing.T) {
+	routes, received := recordingRoutes(events.APIGatewayProxyResponse{
+		StatusCode:      200,
+		Body:            base64.StdEncoding.EncodeToString([]byte{0xff, 0xfe}),
+		IsBase64Encoded: true,
+		Headers:         map[string]string{"Content-Type": "application/octet-stream"},
+	}, nil)
+	recorder := httptest.NewRecorder()
+
+	NewHTTPHandler(NewRouter(routes).Handle).ServeHTTP(recorder, httptest.NewRequest("POST", "/book", strings.NewReader("\xff\xfe")))
+
+	assert.True(t, received.IsBase64Encoded)
+	assert.Equal(t, "//4=", received.Body)
+	assert.Equal(t, []byte{0xff, 0xfe}, recorder.Body.Bytes())
+	assert.Equal(t, "application/octet-stream", recorder.Header().Get("Content-Type"))
+}
+
+func TestHTTPHandlerAnswersUnknownRoutes(t *testing.T) {
+	routes, _ := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	handler := NewHTTPHandler(NewRouter(routes).Handle)
+
+	for _, path := range []string{"/", "/books/1", "/book//", "/book/1/2"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+
+		assert.Equal(t, 404, recorder.Code, path)
+		assert.Equal(t, `{"error":{"status":404,"code":"ROUTE_NOT_FOUND","message":"No route for `+path+`"}}`, recorder.Body.String(), path)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("PUT", "/book/1", nil))
+
+	assert.Equal(t, 405, recorder.Code)
+	assert.Equal(t, "GET, DELETE", recorder.Header().Get("Allow"))
+	assert.Equal(t, `{"error":{"status":405,"code":"METHOD_NOT_ALLOWED","message":"PUT must be one of GET, DELETE"}}`, recorder.Body.String())
+}
+
+func TestHTTPHandlerAnswersLikeAPIGatewayWhenHandlerFails(t *testing.T) {
+	failing, _ := recordingRoutes(events.APIGatewayProxyResponse{}, errors.New("Boom"))
+	withoutStatusCode, _ := recordingRoutes(events.APIGatewayProxyResponse{}, nil)
+
+	for _, routes := range [][]Route{failing, withoutStatusCode} {
+		recorder := httptest.NewRecorder()
+		NewHTTPHandler(NewRouter(routes).Handle).ServeHTTP(recorder, httptest.NewRequest("POST", "/book", nil))
+
+		assert.Equal(t, 502, recorder.Code)
+		assert.Equal(t, `{"message": "Internal server error"}`, recorder.Body.String())
+	}
+}
+
+func TestHTTPHandlerRejectsTooLargeBodies(t *testing.T) {
+	routes, _ := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	recorder := httptest.NewRecorder()
+
+	NewHTTPHandler(NewRouter(routes).Handle).ServeHTTP(recorder, httptest.NewRequest("POST", "/book", strings.NewReader(strings.Repeat("a", maxBodySize+1))))
+
+	assert.Equal(t, 413, recorder.Code)
+	assert.Equal(t, `{"error":{"status":413,"code":"REQUEST_TOO_LARGE","message":"Body cannot be larger than 6291456 bytes"}}`, recorder.Body.String())
+}
+
+func TestHTTPHandlerServesCORS(t *testing.T) {
+	routes, _ := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	cors := middleware.CORS(middleware.CORSConfig{AllowedOrigins: []string{"http://localhost:3000"}, AllowedMethods: []string{"GET", "POST"}})
+	handler := NewHTTPHandler(cors(NewRouter(routes).Handle))
+
+	preflight := httptest.NewRequest("OPTIONS", "/book", nil)
+	preflight.Header.Set("Origin", "http://localhost:3000")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, preflight)
+
+	assert.Equal(t, 204, recorder.Code)
+	assert.Equal(t, "http://localhost:3000", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", recorder.Header().Get("Access-Control-Allow-Methods"))
+
+	request := httptest.NewRequest("GET", "/book/1", nil)
+	request.Header.Set("Origin", "http://localhost:3000")
+	recorder = httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, request)
+
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "http://localhost:3000", recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestRoutesServeHandlersOverHTTP(t *testing.T) {
+	books := model.NewInMemoryBookRepository()
+	server := httptest.NewServer(NewHTTPHandler(NewRouter(Routes(books)).Handle))
+	defer server.Close()
+
+	response, err := http.Post(server.URL+"/book", "application/json", strings.NewReader(`{"title": "Kotlin in Action", "description": "A book", "isbn": "9781617293290", "language": "EN"}`))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 201, response.StatusCode)
+
+	response, err = http.Get(server.URL + "/book/1")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 200, response.StatusCode)
+
+	body, _ := ioutil.ReadAll(response.Body)
+	assert.Equal(t, `{"id":1,"isbn":"9781617293290","title":"Kotlin in Action","description":"A book","language":"EN"}`, string(body))
+
+	stored, _ := books.FindByID(1)
+	assert.Equal(t, null.StringFrom(model.SourceAPI), stored.SourceName)
+}
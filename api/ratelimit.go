@@ -0,0 +1,154 @@
+package api
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/ratelimit"
+	"github.com/felipefill/books/scrap"
+	"github.com/felipefill/books/utils"
+)
+
+// defaultRateLimits are the limits of each rule, see rateLimitRule, and of failed authentications per IP, see
+// FailedAuthenticationLimiting. Scrapping modes are the most expensive, they hit Kotlin's website and, for
+// scrap_and_store, write the whole catalog
+var defaultRateLimits = map[string]ratelimit.Limit{
+	"auth_failures":   {Requests: 10, Per: time.Minute},
+	"create":          {Requests: 30, Per: time.Minute},
+	"search":          {Requests: 300, Per: time.Minute},
+	"retrieve_all":    {Requests: 60, Per: time.Minute},
+	"scrap_only":      {Requests: 10, Per: time.Hour},
+	"scrap_diff":      {Requests: 10, Per: time.Hour},
+	"scrap_and_store": {Requests: 2, Per: time.Hour},
+	"scrap_job":       {Requests: 300, Per: time.Minute},
+}
+
+// rateLimitRule names the rule limiting request, empty when it isn't limited. Scrapping is limited per working mode,
+// whether it's done right away or as a job
+func rateLimitRule(request events.APIGatewayProxyRequest) string {
+	segments := splitPath(request.Path)
+	mode := scrap.WorkingModeFromString(request.QueryStringParameters["mode"]).String()
+
+	switch {
+	case isPath("/book", segments) && request.HTTPMethod == "POST":
+		return "create"
+	case isPath("/book/{id}", segments):
+		return "search"
+	case isPath("/books", segments), isPath("/scrap/jobs", segments) && request.HTTPMethod == "POST":
+		return mode
+	case isPath("/scrap/jobs/{id}", segments):
+		return "scrap_job"
+	default:
+		return ""
+	}
+}
+
+func isPath(routePath string, segments []string) bool {
+	_, ok := matchPath(routePath, segments)
+	return ok
+}
+
+// RateLimits is the API's ratelimit.Rules, limiting each rule as limits say
+func RateLimits(limits map[string]ratelimit.Limit) ratelimit.Rules {
+	return func(request events.APIGatewayProxyRequest) (string, ratelimit.Limit, bool) {
+		name := rateLimitRule(request)
+		limit, ok := limits[name]
+
+		return name, limit, ok
+	}
+}
+
+// NewRateLimitsFromEnv returns the default limits overridden by RATE_LIMITS, a comma separated list of rule=limit,
+// e.g. "scrap_and_store=5/1h,search=off", see ratelimit.ParseLimit
+func NewRateLimitsFromEnv() (map[string]ratelimit.Limit, error) {
+	limits := make(map[string]ratelimit.Limit, len(defaultRateLimits))
+	for name, limit := range defaultRateLimits {
+		limits[name] = limit
+	}
+
+	for _, entry := range strings.Split(os.Getenv("RATE_LIMITS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if _, ok := defaultRateLimits[parts[0]]; !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("RATE_LIMITS must list rule=limit with known rules, got %q", entry)
+		}
+
+		limit, err := ratelimit.ParseLimit(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("RATE_LIMITS has an invalid limit for %s: %s", parts[0], err.Error())
+		}
+
+		limits[parts[0]] = limit
+	}
+
+	return limits, nil
+}
+
+// RateLimiting creates the middleware limiting requests to handlers, see NewRateLimitsFromEnv. Buckets are kept in
+// database or in memory, as RATE_LIMIT_STORE says, defaultStore otherwise, or memory when DB_DRIVER is. It must run
+// after authentication
+func RateLimiting(defaultStore string) (middleware.Middleware, error) {
+	limits, err := NewRateLimitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := newRateLimitStore(defaultStore)
+	if err != nil {
+		return nil, err
+	}
+
+	return ratelimit.Middleware(store, RateLimits(limits)), nil
+}
+
+// FailedAuthenticationLimiting creates the middleware limiting, per IP, requests answered with 401 under the
+// auth_failures limit, so that credentials can't be guessed. Buckets are kept as for RateLimiting. It must run before
+// authentication
+func FailedAuthenticationLimiting(defaultStore string) (middleware.Middleware, error) {
+	limits, err := NewRateLimitsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := newRateLimitStore(defaultStore)
+	if err != nil {
+		return nil, err
+	}
+
+	unauthorized := func(response events.APIGatewayProxyResponse) bool {
+		return response.StatusCode == 401
+	}
+
+	return ratelimit.LimitFailures(store, "auth_failures", limits["auth_failures"], unauthorized), nil
+}
+
+func newRateLimitStore(defaultStore string) (ratelimit.Store, error) {
+	storeName := os.Getenv("RATE_LIMIT_STORE")
+	if storeName == "" && utils.InMemory() {
+		// There's no database to keep buckets in, see utils.ErrNoDatabase
+		storeName = "memory"
+	} else if storeName == "" {
+		storeName = defaultStore
+	}
+
+	switch storeName {
+	case "db":
+		if utils.InMemory() {
+			return nil, fmt.Errorf("RATE_LIMIT_STORE can't be db when DB_DRIVER is memory, there's no database")
+		}
+
+		return ratelimit.NewDBStore(utils.GetDB), nil
+	case "memory":
+		return ratelimit.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("RATE_LIMIT_STORE must be db or memory, got %q", storeName)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitRule(t *testing.T) {
+	rules := map[string]events.APIGatewayProxyRequest{
+		"create":          {HTTPMethod: "POST", Path: "/book"},
+		"search":          {HTTPMethod: "GET", Path: "/book/1"},
+		"retrieve_all":    {HTTPMethod: "GET", Path: "/books"},
+		"scrap_and_store": {HTTPMethod: "GET", Path: "/books/", QueryStringParameters: map[string]string{"mode": "scrap_and_store"}},
+		"scrap_diff":      {HTTPMethod: "POST", Path: "/scrap/jobs", QueryStringParameters: map[string]string{"mode": "scrap_diff"}},
+		"scrap_job":       {HTTPMethod: "GET", Path: "/scrap/jobs/1"},
+		"":                {HTTPMethod: "GET", Path: "/health"},
+	}
+
+	for name, request := range rules {
+		assert.Equal(t, name, rateLimitRule(request), request.Path)
+	}
+}
+
+func TestNewRateLimitsFromEnv(t *testing.T) {
+	limits, err := NewRateLimitsFromEnv()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, defaultRateLimits, limits)
+
+	os.Setenv("RATE_LIMITS", "scrap_and_store=5/1h, search=off")
+	defer os.Unsetenv("RATE_LIMITS")
+
+	limits, err = NewRateLimitsFromEnv()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 5, Per: time.Hour}, limits["scrap_and_store"])
+	assert.Equal(t, ratelimit.Limit{}, limits["search"])
+	assert.Equal(t, defaultRateLimits["create"], limits["create"])
+	assert.Equal(t, ratelimit.Limit{Requests: 2, Per: time.Hour}, defaultRateLimits["scrap_and_store"])
+
+	for _, value := range []string{"delete=5/1m", "create", "create=lots"} {
+		os.Setenv("RATE_LIMITS", value)
+
+		_, err = NewRateLimitsFromEnv()
+		assert.NotEqual(t, nil, err, value)
+	}
+}
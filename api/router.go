@@ -0,0 +1,68 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+)
+
+// Router serves every route from a single Lambda function, so that there's one function to deploy and to cold start
+type Router struct {
+	routes []Route
+}
+
+// NewRouter creates a Router dispatching to routes
+func NewRouter(routes []Route) *Router {
+	return &Router{routes: routes}
+}
+
+// Handle dispatches request to the route named by its Resource and HTTPMethod. Requests coming through a catch-all
+// resource, like "/{proxy+}", or without resource, like those NewHTTPHandler makes, are matched on their path instead
+// and get the resource and path parameters of the route they match. Unknown paths are answered with 404 and known
+// paths with another method with 405. The route matched is recorded for outer middleware, see middleware.SetRoute
+func (r *Router) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	allowed := make([]string, 0)
+	for index := range r.routes {
+		if r.routes[index].Path != request.Resource {
+			continue
+		}
+
+		if r.routes[index].Method == request.HTTPMethod {
+			middleware.SetRoute(ctx, r.routes[index].Path)
+			return r.routes[index].Handler(ctx, request)
+		}
+
+		allowed = append(allowed, r.routes[index].Method)
+	}
+
+	if len(allowed) > 0 {
+		return notFoundOrNotAllowed(request, allowed), nil
+	}
+
+	route, parameters, allowed := match(r.routes, request.HTTPMethod, request.Path)
+	if route == nil {
+		return notFoundOrNotAllowed(request, allowed), nil
+	}
+
+	request.Resource = route.Path
+	request.RequestContext.ResourcePath = route.Path
+	request.PathParameters = parameters
+	middleware.SetRoute(ctx, route.Path)
+
+	return route.Handler(ctx, request)
+}
+
+// notFoundOrNotAllowed answers request with 404 when no method is allowed and 405 listing allowed ones otherwise
+func notFoundOrNotAllowed(request events.APIGatewayProxyRequest, allowed []string) events.APIGatewayProxyResponse {
+	if len(allowed) == 0 {
+		return apierror.New(404, apierror.RouteNotFound, fmt.Sprintf("No route for %s", request.Path)).Response(request)
+	}
+
+	return apierror.New(405, apierror.MethodNotAllowed, fmt.Sprintf("%s must be one of %s", request.HTTPMethod, strings.Join(allowed, ", "))).
+		WithHeader("Allow", strings.Join(allowed, ", ")).
+		Response(request)
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouterDispatchesOnResourceAndMethod(t *testing.T) {
+	routes, received := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200, Body: "{}"}, nil)
+	request := events.APIGatewayProxyRequest{
+		Resource:       "/book/{id}",
+		Path:           "/book/42",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "42"},
+	}
+
+	response, err := NewRouter(routes).Handle(context.Background(), request)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, events.APIGatewayProxyResponse{StatusCode: 200, Body: "{}"}, response)
+	assert.Equal(t, request, *received)
+}
+
+func TestRouterMatchesCatchAllRequestsOnPath(t *testing.T) {
+	routes, received := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	request := events.APIGatewayProxyRequest{
+		Resource:       "/{proxy+}",
+		Path:           "/book/42",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"proxy": "book/42"},
+	}
+
+	NewRouter(routes).Handle(context.Background(), request)
+
+	assert.Equal(t, "/book/{id}", received.Resource)
+	assert.Equal(t, "/book/{id}", received.RequestContext.ResourcePath)
+	assert.Equal(t, map[string]string{"id": "42"}, received.PathParameters)
+}
+
+func TestRouterRecordsRouteMatched(t *testing.T) {
+	routes, _ := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	request := events.APIGatewayProxyRequest{Resource: "/{proxy+}", Path: "/book/42", HTTPMethod: "GET"}
+	ctx := middleware.WithRequestState(context.Background())
+
+	NewRouter(routes).Handle(ctx, request)
+
+	assert.Equal(t, "/book/{id}", middleware.GetRoute(ctx, request))
+}
+
+func TestRouterAnswersUnknownRoutes(t *testing.T) {
+	routes, _ := recordingRoutes(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	router := NewRouter(routes)
+
+	response, err := router.Handle(context.Background(), events.APIGatewayProxyRequest{Resource: "/{proxy+}", Path: "/authors/1", HTTPMethod: "GET"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, events.APIGatewayProxyResponse{Body: `{"error":{"status":404,"code":"ROUTE_NOT_FOUND","message":"No route for /authors/1"}}`, StatusCode: 404}, response)
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":405,"code":"METHOD_NOT_ALLOWED","message":"PUT must be one of GET, DELETE"}}`,
+		StatusCode: 405,
+		Headers:    map[string]string{"Allow": "GET, DELETE"},
+	}
+
+	response, _ = router.Handle(context.Background(), events.APIGatewayProxyRequest{Resource: "/book/{id}", Path: "/book/1", HTTPMethod: "PUT"})
+	assert.Equal(t, expectedResponse, response)
+
+	response, _ = router.Handle(context.Background(), events.APIGatewayProxyRequest{Resource: "/{proxy+}", Path: "/book/1", HTTPMethod: "PUT"})
+	assert.Equal(t, expectedResponse, response)
+}
+
+func TestRouterServesHandlers(t *testing.T) {
+	books := model.NewInMemoryBookRepository(model.Book{Title: "Kotlin in Action"})
+	router := NewRouter(Routes(books))
+
+	response, _ := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		Resource:       "/book/{id}",
+		Path:           "/book/1",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "1"},
+	})
+
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, `{"id":1,"isbn":null,"title":"Kotlin in Action","description":"","language":""}`, response.Body)
+}
+
+func TestRouterAcceptsModesInAnyCase(t *testing.T) {
+	for _, name := range []string{"DB_DRIVER", "DATABASE_URL", "DB_HOST", "DB_NAME", "DB_USER", "DB_PSWD"} {
+		os.Unsetenv(name)
+	}
+
+	books := model.NewInMemoryBookRepository(model.Book{Title: "Kotlin in Action"})
+	router := NewRouter(Routes(books))
+
+	// Unknown modes retrieve stored books, like retrieve_all does
+	for _, mode := range []string{"RETRIEVE_ALL", "Retrieve_All", "everything"} {
+		response, _ := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+			Path:                  "/books",
+			HTTPMethod:            "GET",
+			QueryStringParameters: map[string]string{"mode": mode},
+		})
+
+		assert.Equal(t, 200, response.StatusCode, mode)
+		assert.Contains(t, response.Body, "Kotlin in Action", mode)
+	}
+
+	// Database isn't configured, so a valid job is only answered once it's tried to be stored
+	response, _ := router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		Path:                  "/scrap/jobs",
+		HTTPMethod:            "POST",
+		QueryStringParameters: map[string]string{"mode": "sCrAp_OnLy"},
+	})
+	assert.Equal(t, 503, response.StatusCode)
+
+	response, _ = router.Handle(context.Background(), events.APIGatewayProxyRequest{
+		Path:                  "/scrap/jobs",
+		HTTPMethod:            "POST",
+		QueryStringParameters: map[string]string{"mode": "everything"},
+	})
+	assert.Equal(t, 400, response.StatusCode)
+	assert.Contains(t, response.Body, "INVALID_PARAMETER")
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/felipefill/books/create"
+	"github.com/felipefill/books/health"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/openapi"
+	"github.com/felipefill/books/scrap"
+	"github.com/felipefill/books/search"
+	"github.com/felipefill/books/utils"
+)
+
+// HandlerFunc is what every handler looks like, see middleware.HandlerFunc
+type HandlerFunc = middleware.HandlerFunc
+
+// Route mounts a handler on a method and a path. Path is written the way API Gateway names resources, e.g.
+// "/book/{id}": each {name} segment matches any value, which becomes a path parameter
+type Route struct {
+	Method  string
+	Path    string
+	Handler HandlerFunc
+}
+
+// Routes returns the HTTP routes of serverless.yml, handled by the same handlers their functions run. Requests are
+// validated against Spec before reaching them
+func Routes(books model.BookRepository) []Route {
+	createHandler := create.NewHandler(books)
+	searchHandler := search.NewHandler(books)
+	scrapHandler := scrap.NewHandler(books)
+	healthHandler := health.NewHandler(utils.CheckDBHealth, utils.Version)
+	specHandler := openapi.NewHandler(Spec())
+	validate := Validation()
+
+	return []Route{
+		{Method: "POST", Path: "/book", Handler: validate(createHandler.Handle)},
+		{Method: "GET", Path: "/book/{id}", Handler: validate(searchHandler.Handle)},
+		{Method: "GET", Path: "/books", Handler: validate(scrapHandler.Handle)},
+		{Method: "POST", Path: "/scrap/jobs", Handler: validate(scrapHandler.Handle)},
+		{Method: "GET", Path: "/scrap/jobs/{id}", Handler: validate(scrapHandler.Handle)},
+		{Method: "GET", Path: "/health", Handler: healthHandler.Handle},
+		{Method: "GET", Path: "/openapi.json", Handler: specHandler.Handle},
+	}
+}
+
+// match finds the route for method and path and the path parameters it extracts. When path is known but method
+// isn't, route is nil and allowed lists the methods path accepts; both are empty when path is unknown
+func match(routes []Route, method string, path string) (route *Route, parameters map[string]string, allowed []string) {
+	segments := splitPath(path)
+
+	for index := range routes {
+		routeParameters, ok := matchPath(routes[index].Path, segments)
+		if !ok {
+			continue
+		}
+
+		if routes[index].Method != method {
+			allowed = append(allowed, routes[index].Method)
+			continue
+		}
+
+		return &routes[index], routeParameters, nil
+	}
+
+	return nil, nil, allowed
+}
+
+func matchPath(routePath string, segments []string) (map[string]string, bool) {
+	routeSegments := splitPath(routePath)
+	if len(routeSegments) != len(segments) {
+		return nil, false
+	}
+
+	var parameters map[string]string
+	for index, routeSegment := range routeSegments {
+		if strings.HasPrefix(routeSegment, "{") && strings.HasSuffix(routeSegment, "}") {
+			if segments[index] == "" {
+				return nil, false
+			}
+
+			if parameters == nil {
+				parameters = make(map[string]string)
+			}
+
+			parameters[strings.Trim(routeSegment, "{}")] = segments[index]
+			continue
+		}
+
+		if routeSegment != segments[index] {
+			return nil, false
+		}
+	}
+
+	return parameters, true
+}
+
+// splitPath splits path into its segments, a single trailing slash is ignored
+func splitPath(path string) []string {
+	return strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/"), "/")
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/openapi"
+	"github.com/felipefill/books/scrap"
+	"github.com/felipefill/books/utils"
+)
+
+// Validation checks requests against Spec before they reach handlers, see openapi.Validation
+func Validation() middleware.Middleware {
+	return openapi.Validation(Spec())
+}
+
+// Spec describes every route of Routes, api tests keep it in sync with handlers and what they answer
+func Spec() *openapi.Document {
+	return &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Books",
+			Description: "Create, search and scrap books",
+			Version:     utils.Version,
+		},
+		Paths: map[string]openapi.PathItem{
+			"/book": {"post": {
+				OperationID: "createBook",
+				Summary:     "Creates a book or merges it into the same stored one",
+				Parameters:  []openapi.Parameter{mergeParameter},
+				RequestBody: &openapi.RequestBody{
+					Required: true,
+					Content:  jsonContent(openapi.SchemaRef("CreateBookRequest")),
+				},
+				Responses: withErrors(map[string]openapi.Response{
+					"200": {Description: "Book was merged into the same stored one", Content: jsonContent(openapi.SchemaRef("CreatedBook"))},
+					"201": {Description: "Book was created", Content: jsonContent(openapi.SchemaRef("CreatedBook"))},
+				}, "400", "401", "429", "500", "503"),
+				Security: authenticationRequired,
+			}},
+			"/book/{id}": {"get": {
+				OperationID: "getBook",
+				Summary:     "Retrieves a book",
+				Parameters:  []openapi.Parameter{idParameter("Book's ID"), provenanceParameter},
+				Responses: withErrors(map[string]openapi.Response{
+					"200": {Description: "Book with given ID", Content: jsonContent(openapi.SchemaRef("Book"))},
+				}, "400", "401", "404", "429", "503"),
+				Security: authenticationOptional,
+			}},
+			"/books": {"get": {
+				OperationID: "listBooks",
+				Summary:     "Retrieves stored books or scraps Kotlin's website, as mode says",
+				Description: "Every mode but retrieve_all scraps and requires authentication, scrap_diff answers a BooksDiff",
+				Parameters: []openapi.Parameter{
+					workingModeParameter(false, "Unknown modes retrieve stored books", scrap.RetrieveAll, scrap.ScrapOnly, scrap.ScrapAndStore, scrap.ScrapDiff),
+					mergeParameter,
+					forceRefreshParameter,
+					provenanceParameter,
+				},
+				Responses: withErrors(map[string]openapi.Response{
+					"200": {Description: "Books, or what would change for scrap_diff", Content: jsonContent(scrapResultSchema())},
+				}, "400", "401", "429", "500", "503"),
+				Security: authenticationOptional,
+			}},
+			"/scrap/jobs": {"post": {
+				OperationID: "createScrapJob",
+				Summary:     "Enqueues a job running a scrapping mode asynchronously",
+				Parameters: []openapi.Parameter{
+					workingModeParameter(true, "Unknown modes are answered with 400", scrap.ScrapOnly, scrap.ScrapAndStore, scrap.ScrapDiff),
+					mergeParameter,
+					forceRefreshParameter,
+					provenanceParameter,
+				},
+				Responses: withErrors(map[string]openapi.Response{
+					"202": {
+						Description: "Job was enqueued",
+						Headers:     map[string]openapi.Header{"Location": {Description: "Where job's status is", Schema: str("")}},
+						Content:     jsonContent(openapi.SchemaRef("ScrapJobAccepted")),
+					},
+				}, "400", "401", "429", "500", "503"),
+				Security: authenticationRequired,
+			}},
+			"/scrap/jobs/{id}": {"get": {
+				OperationID: "getScrapJob",
+				Summary:     "Tells how a scrap job is going, and its result once it's done",
+				Parameters:  []openapi.Parameter{idParameter("Job's ID")},
+				Responses: withErrors(map[string]openapi.Response{
+					"200": {Description: "Job with given ID", Content: jsonContent(openapi.SchemaRef("ScrapJob"))},
+				}, "400", "401", "404", "429", "503"),
+				Security: authenticationOptional,
+			}},
+			"/health": {"get": {
+				OperationID: "getHealth",
+				Summary:     "Tells whether requests can be served",
+				Responses: map[string]openapi.Response{
+					"200": {Description: "Database is reachable and its schema is up to date", Content: jsonContent(openapi.SchemaRef("Health"))},
+					"503": {Description: "Database can't be used", Content: jsonContent(openapi.SchemaRef("Health"))},
+				},
+			}},
+			"/openapi.json": {"get": {
+				OperationID: "getOpenAPI",
+				Summary:     "Retrieves this document",
+				Responses: map[string]openapi.Response{
+					"200": {Description: "OpenAPI document", Content: jsonContent(&openapi.Schema{Type: "object"})},
+				},
+			}},
+		},
+		Components: openapi.Components{
+			Schemas: schemas(),
+			Responses: map[string]openapi.Response{
+				"Error": {
+					Description: "Request failed, code tells why",
+					Headers:     map[string]openapi.Header{"Retry-After": {Description: "Seconds to wait before retrying, for 429 and 503", Schema: integer("")}},
+					Content:     jsonContent(openapi.SchemaRef("Error")),
+				},
+			},
+			SecuritySchemes: map[string]openapi.SecurityScheme{
+				"apiKey": {Type: "apiKey", In: "header", Name: "X-Api-Key", Description: "Key created with cmd/apikeys"},
+				"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Token signed with the configured key"},
+			},
+		},
+	}
+}
+
+var authenticationRequired = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+
+// authenticationOptional is required unless AUTH_PUBLIC_READS is set, which a static document can't tell
+var authenticationOptional = []map[string][]string{{}, {"apiKey": {}}, {"bearer": {}}}
+
+var mergeParameter = openapi.Parameter{
+	Name:        "merge",
+	In:          "query",
+	Description: "How stored books are merged with new ones, MERGE_STRATEGY by default",
+	Schema: &openapi.Schema{Type: "string", Enum: []string{
+		string(model.KeepExisting), string(model.Overwrite), string(model.FillEmpty), string(model.PreferSource),
+	}},
+}
+
+var provenanceParameter = openapi.Parameter{
+	Name:        "provenance",
+	In:          "query",
+	Description: "Adds where books came from to each of them",
+	Schema:      &openapi.Schema{Type: "boolean"},
+}
+
+var forceRefreshParameter = openapi.Parameter{
+	Name:        "force_refresh",
+	In:          "query",
+	Description: "Ignores cached pages and downloads them again",
+	Schema:      &openapi.Schema{Type: "boolean"},
+}
+
+func idParameter(description string) openapi.Parameter {
+	return openapi.Parameter{Name: "id", In: "path", Required: true, Description: description, Schema: integer("")}
+}
+
+// workingModeParameter is the mode query parameter, it isn't an enum since modes are read whatever their case, see
+// scrap.WorkingModeFromString, and handlers decide what unknown ones do
+func workingModeParameter(required bool, unknown string, modes ...scrap.WorkingMode) openapi.Parameter {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, mode.String())
+	}
+
+	return openapi.Parameter{
+		Name:        "mode",
+		In:          "query",
+		Required:    required,
+		Description: fmt.Sprintf("One of %s, in any case. %s", strings.Join(names, ", "), unknown),
+		Schema:      str(""),
+	}
+}
+
+func workingModeSchema(modes ...scrap.WorkingMode) *openapi.Schema {
+	names := make([]string, 0, len(modes))
+	for _, mode := range modes {
+		names = append(names, mode.String())
+	}
+
+	return &openapi.Schema{Type: "string", Enum: names}
+}
+
+// scrapResultSchema is what scrapping modes answer, a BooksDiff for scrap_diff and a BooksResponse otherwise
+func scrapResultSchema() *openapi.Schema {
+	return &openapi.Schema{OneOf: []*openapi.Schema{openapi.SchemaRef("BooksResponse"), openapi.SchemaRef("BooksDiff")}}
+}
+
+func jsonContent(schema *openapi.Schema) map[string]openapi.MediaType {
+	return map[string]openapi.MediaType{"application/json": {Schema: schema}}
+}
+
+// withErrors adds the Error response to responses for each of statuses
+func withErrors(responses map[string]openapi.Response, statuses ...string) map[string]openapi.Response {
+	for _, status := range statuses {
+		responses[status] = openapi.ResponseRef("Error")
+	}
+
+	return responses
+}
+
+func schemas() map[string]*openapi.Schema {
+	return map[string]*openapi.Schema{
+		"Book": object([]string{"id", "isbn", "title", "description", "language"}, map[string]*openapi.Schema{
+			"id":          integer(""),
+			"isbn":        nullable(str("ISBN-10 or ISBN-13, Unavailable when it's unknown")),
+			"title":       str(""),
+			"description": str(""),
+			"language":    str("Two letter code"),
+			"provenance":  openapi.SchemaRef("Provenance"),
+		}),
+		"Provenance": object(nil, map[string]*openapi.Schema{
+			"sourceName":  nullable(str("api or kotlinlang.org")),
+			"sourceUrl":   nullable(str("Page book was listed on")),
+			"detailUrl":   nullable(str("Book's own page")),
+			"firstSeenAt": nullable(dateTime()),
+			"lastSeenAt":  nullable(dateTime()),
+			"contentHash": nullable(str("Changes whenever scrapped fields do")),
+		}),
+		"Books": object([]string{"numberBooks", "books"}, map[string]*openapi.Schema{
+			"numberBooks": integer(""),
+			"books":       arrayOf(openapi.SchemaRef("Book")),
+		}),
+		"BooksResponse": object([]string{"numberBooks", "books"}, map[string]*openapi.Schema{
+			"numberBooks": integer(""),
+			"books":       arrayOf(openapi.SchemaRef("Book")),
+			"failed":      arrayOf(openapi.SchemaRef("FailedBook")),
+			"skipped":     arrayOf(openapi.SchemaRef("SkippedLink")),
+			"summary":     openapi.SchemaRef("ScrapSummary"),
+			"stored":      openapi.SchemaRef("UpsertSummary"),
+		}),
+		"BooksDiff": object([]string{"new", "changed", "removed"}, map[string]*openapi.Schema{
+			"new":     arrayOf(openapi.SchemaRef("BookDiff")),
+			"changed": arrayOf(openapi.SchemaRef("BookDiff")),
+			"removed": arrayOf(openapi.SchemaRef("BookDiff")),
+			"failed":  arrayOf(openapi.SchemaRef("FailedBook")),
+			"skipped": arrayOf(openapi.SchemaRef("SkippedLink")),
+			"summary": openapi.SchemaRef("ScrapSummary"),
+		}),
+		"BookDiff": object([]string{"book", "diffs"}, map[string]*openapi.Schema{
+			"book": openapi.SchemaRef("Book"),
+			"diffs": nullable(arrayOf(object([]string{"field", "stored", "scrapped"}, map[string]*openapi.Schema{
+				"field":    str(""),
+				"stored":   str(""),
+				"scrapped": str(""),
+			}))),
+		}),
+		"FailedBook": object([]string{"book", "link", "error"}, map[string]*openapi.Schema{
+			"book":  openapi.SchemaRef("Book"),
+			"link":  str(""),
+			"error": str(""),
+		}),
+		"SkippedLink": object([]string{"title", "link", "reason"}, map[string]*openapi.Schema{
+			"title":  str(""),
+			"link":   str(""),
+			"reason": str(""),
+		}),
+		"ScrapSummary": object([]string{"succeeded", "failed", "skipped"}, map[string]*openapi.Schema{
+			"succeeded": integer(""),
+			"failed":    integer(""),
+			"skipped":   integer(""),
+		}),
+		"UpsertSummary": object([]string{"inserted", "updated", "unchanged", "duplicates"}, map[string]*openapi.Schema{
+			"inserted":   integer(""),
+			"updated":    integer(""),
+			"unchanged":  integer(""),
+			"duplicates": integer(""),
+			"changes": arrayOf(object([]string{"title", "fields"}, map[string]*openapi.Schema{
+				"title":  str(""),
+				"fields": arrayOf(openapi.SchemaRef("FieldChange")),
+			})),
+		}),
+		"FieldChange": object([]string{"field", "old", "new"}, map[string]*openapi.Schema{
+			"field": str(""),
+			"old":   str(""),
+			"new":   str(""),
+		}),
+		"CreateBookRequest": object([]string{"title", "description", "isbn", "language"}, map[string]*openapi.Schema{
+			"title":       {Type: "string", MinLength: 1, MaxLength: 100},
+			"description": {Type: "string", MinLength: 1},
+			"isbn":        {Type: "string", MinLength: 1, Description: "ISBN-10 or ISBN-13, separators are ignored"},
+			"language":    {Type: "string", MinLength: 1, MaxLength: 2, Description: "Two letter code"},
+		}),
+		"CreatedBook": object([]string{"book_id"}, map[string]*openapi.Schema{
+			"book_id": integer(""),
+			"changes": nullable(arrayOf(openapi.SchemaRef("FieldChange"))),
+		}),
+		"ScrapJobAccepted": object([]string{"job_id"}, map[string]*openapi.Schema{
+			"job_id": integer(""),
+		}),
+		"ScrapJob": object([]string{"id", "mode", "forceRefresh", "merge", "provenance", "status", "progress", "error", "createdAt", "startedAt", "finishedAt", "heartbeatAt"}, map[string]*openapi.Schema{
+			"id":           integer(""),
+			"mode":         workingModeSchema(scrap.ScrapOnly, scrap.ScrapAndStore, scrap.ScrapDiff),
+			"forceRefresh": {Type: "boolean"},
+			"merge":        mergeParameter.Schema,
+			"provenance":   {Type: "boolean"},
+			"status": {Type: "string", Enum: []string{
+				string(model.JobPending), string(model.JobRunning), string(model.JobSucceeded), string(model.JobFailed),
+			}},
+			"progress":    str(""),
+			"error":       nullable(str("")),
+			"createdAt":   dateTime(),
+			"startedAt":   nullable(dateTime()),
+			"finishedAt":  nullable(dateTime()),
+			"heartbeatAt": nullable(dateTime()),
+			"result":      scrapResultSchema(),
+		}),
+		"Health": object([]string{"status", "version", "database"}, map[string]*openapi.Schema{
+			"status":  {Type: "string", Enum: []string{"ok", "unavailable"}},
+			"version": str(""),
+			"database": object([]string{"driver", "reachable", "latencyMs"}, map[string]*openapi.Schema{
+				"driver":    str(""),
+				"reachable": {Type: "boolean"},
+				"latencyMs": {Type: "number"},
+				"migrations": object([]string{"current", "latest"}, map[string]*openapi.Schema{
+					"current": integer(""),
+					"latest":  integer(""),
+				}),
+				"error": str(""),
+			}),
+		}),
+		"Error": object([]string{"error"}, map[string]*openapi.Schema{
+			"error": object([]string{"status", "code", "message"}, map[string]*openapi.Schema{
+				"status":    integer(""),
+				"code":      str("Tells what went wrong, unlike message it never changes, e.g. BOOK_NOT_FOUND"),
+				"message":   str("Meant for humans"),
+				"requestId": str("X-Request-Id of the request, to find it in logs"),
+			}),
+		}),
+	}
+}
+
+func str(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "string", Description: description}
+}
+
+func integer(description string) *openapi.Schema {
+	return &openapi.Schema{Type: "integer", Description: description}
+}
+
+func dateTime() *openapi.Schema {
+	return &openapi.Schema{Type: "string", Format: "date-time"}
+}
+
+func nullable(schema *openapi.Schema) *openapi.Schema {
+	schema.Nullable = true
+	return schema
+}
+
+func arrayOf(items *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: "array", Items: items}
+}
+
+func object(required []string, properties map[string]*openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{Type: "object", Required: required, Properties: properties}
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/create"
+	"github.com/felipefill/books/health"
+	"github.com/felipefill/books/migrations"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/openapi"
+	"github.com/felipefill/books/scrap"
+	"github.com/felipefill/books/utils"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+var documentedBook = model.Book{
+	ID:          1,
+	ISBN:        null.StringFrom("9781617293290"),
+	Title:       "Kotlin in Action",
+	Description: "A book",
+	Language:    "EN",
+	Provenance: model.Provenance{
+		SourceName:  null.StringFrom("kotlinlang.org"),
+		SourceURL:   null.StringFrom("https://kotlinlang.org/docs/books.html"),
+		DetailURL:   null.StringFrom("https://www.manning.com/books/kotlin-in-action"),
+		FirstSeenAt: null.TimeFrom(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)),
+		LastSeenAt:  null.TimeFrom(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)),
+		ContentHash: null.StringFrom("abc"),
+	},
+	IncludeProvenance: true,
+}
+
+// properties lists the properties of value's JSON, sorted
+func properties(t *testing.T, value interface{}) []string {
+	encoded, _ := json.Marshal(value)
+	object := make(map[string]interface{})
+	assert.Equal(t, nil, json.Unmarshal(encoded, &object), string(encoded))
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func schemaProperties(schema *openapi.Schema) []string {
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// assertAnsweredAsSpecified checks response against what spec says method on resource answers with its status
+func assertAnsweredAsSpecified(t *testing.T, spec *openapi.Document, method string, resource string, response events.APIGatewayProxyResponse) {
+	operation := spec.Operation(method, resource)
+	if !assert.NotNil(t, operation, method+" "+resource) {
+		return
+	}
+
+	specified, ok := operation.Responses[strconv.Itoa(response.StatusCode)]
+	if !assert.True(t, ok, "%s %s doesn't answer %d", method, resource, response.StatusCode) {
+		return
+	}
+
+	if specified.Ref != "" {
+		specified = spec.Components.Responses[strings.TrimPrefix(specified.Ref, "#/components/responses/")]
+	}
+
+	var body interface{}
+	assert.Equal(t, nil, json.Unmarshal([]byte(response.Body), &body), response.Body)
+	assert.Empty(t, spec.Validate(specified.Content["application/json"].Schema, body, "Body"), response.Body)
+}
+
+func TestSpecDescribesEveryRoute(t *testing.T) {
+	spec := Spec()
+	routes := Routes(model.NewInMemoryBookRepository())
+
+	operations := 0
+	for _, pathItem := range spec.Paths {
+		operations += len(pathItem)
+	}
+
+	assert.Equal(t, len(routes), operations)
+	for _, route := range routes {
+		assert.NotNil(t, spec.Operation(route.Method, route.Path), route.Method+" "+route.Path)
+	}
+}
+
+func TestSpecSchemasHaveTheFieldsOfWhatHandlersAnswer(t *testing.T) {
+	spec := Spec()
+	schemas := spec.Components.Schemas
+	schema := func(name string, property string) *openapi.Schema {
+		return spec.Resolve(schemas[name].Properties[property])
+	}
+
+	described := map[*openapi.Schema]interface{}{
+		schemas["Book"]:                          documentedBook,
+		schemas["Provenance"]:                    documentedBook.Provenance,
+		schemas["Books"]:                         model.Books{},
+		schemas["FieldChange"]:                   model.FieldChange{},
+		schemas["UpsertSummary"]:                 model.UpsertSummary{Changes: []model.BookChanges{{}}},
+		schema("UpsertSummary", "changes").Items: model.BookChanges{},
+		schemas["CreateBookRequest"]:             create.CreateBookRequest{},
+		schemas["BooksResponse"]: scrap.BooksResponse{
+			Failed:  []scrap.FailedBook{{}},
+			Skipped: []scrap.SkippedLink{{}},
+			Summary: &scrap.ScrapSummary{},
+			Stored:  &model.UpsertSummary{},
+		},
+		schemas["BooksDiff"]:              scrap.BooksDiff{Failed: []scrap.FailedBook{{}}, Skipped: []scrap.SkippedLink{{}}, Summary: &scrap.ScrapSummary{}},
+		schemas["BookDiff"]:               scrap.BookDiff{},
+		schema("BookDiff", "diffs").Items: scrap.FieldDiff{},
+		schemas["FailedBook"]:             scrap.FailedBook{},
+		schemas["SkippedLink"]:            scrap.SkippedLink{},
+		schemas["ScrapSummary"]:           scrap.ScrapSummary{},
+		schema("Health", "database"):      utils.DBHealth{Migrations: &migrations.Status{}, Error: "Boom"},
+		schema("Error", "error"):          apierror.Error{RequestID: "abc"},
+	}
+
+	for schema, value := range described {
+		assert.Equal(t, schemaProperties(schema), properties(t, value), "%T", value)
+	}
+
+	// Jobs are answered along with their result, see scrap.retrieveScrapJob
+	jobProperties := append(properties(t, model.ScrapJob{}), "result")
+	sort.Strings(jobProperties)
+	assert.Equal(t, schemaProperties(schemas["ScrapJob"]), jobProperties)
+}
+
+func TestSpecEnumsHaveWhatHandlersAccept(t *testing.T) {
+	spec := Spec()
+
+	for _, mode := range spec.Operation("GET", "/books").Parameters[0].Schema.Enum {
+		assert.Equal(t, mode, scrap.WorkingModeFromString(mode).String())
+	}
+
+	strategies := spec.Operation("POST", "/book").Parameters[0].Schema.Enum
+	for _, strategy := range strategies {
+		_, err := model.ParseMergeStrategy(strategy)
+		assert.Equal(t, nil, err, strategy)
+	}
+
+	_, err := model.ParseMergeStrategy("unknown")
+	last := len(strategies) - 1
+	assert.Equal(t, "Merge strategy must be one of "+strings.Join(strategies[:last], ", ")+" or "+strategies[last], err.Error())
+}
+
+func TestHandlersAnswerAsSpecified(t *testing.T) {
+	spec := Spec()
+	router := NewRouter(Routes(model.NewInMemoryBookRepository()))
+	request := func(method string, path string, body string) events.APIGatewayProxyResponse {
+		query := map[string]string{"provenance": "true"}
+		response, _ := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: method, Resource: "/{proxy+}", Path: path, Body: body, QueryStringParameters: query})
+
+		return response
+	}
+
+	book := `{"title": "Kotlin in Action", "description": "A book", "isbn": "9781617293290", "language": "EN"}`
+	created := request("POST", "/book", book)
+	merged := request("POST", "/book", book)
+	assert.Equal(t, 201, created.StatusCode)
+	assert.Equal(t, 200, merged.StatusCode)
+
+	assertAnsweredAsSpecified(t, spec, "POST", "/book", created)
+	assertAnsweredAsSpecified(t, spec, "POST", "/book", merged)
+	assertAnsweredAsSpecified(t, spec, "POST", "/book", request("POST", "/book", `{"title": "Kotlin in Action"}`))
+	assertAnsweredAsSpecified(t, spec, "GET", "/book/{id}", request("GET", "/book/1", ""))
+	assertAnsweredAsSpecified(t, spec, "GET", "/book/{id}", request("GET", "/book/2", ""))
+	assertAnsweredAsSpecified(t, spec, "GET", "/books", request("GET", "/books", ""))
+
+	healthHandler := health.NewHandler(func() utils.DBHealth {
+		return utils.DBHealth{Driver: "sqlite3", Reachable: true, Migrations: &migrations.Status{Current: 5, Latest: 5}}
+	}, "1.0.0")
+	healthy, _ := healthHandler.Handle(context.Background(), events.APIGatewayProxyRequest{})
+	assertAnsweredAsSpecified(t, spec, "GET", "/health", healthy)
+}
+
+func TestRoutesValidateRequests(t *testing.T) {
+	router := NewRouter(Routes(model.NewInMemoryBookRepository()))
+
+	response, _ := router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/book", Body: `{"title": "Kotlin in Action", "language": "ENG"}`})
+	assert.Equal(t, `{"error":{"status":400,"code":"VALIDATION_FAILED","message":"description is required; isbn is required; language must be at most 2 characters long"}}`, response.Body)
+
+	response, _ = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Path: "/scrap/jobs"})
+	assert.Equal(t, `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"mode\" is required"}}`, response.Body)
+
+	response, _ = router.Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/books", QueryStringParameters: map[string]string{"merge": "newest"}})
+	assert.Equal(t, `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"merge\" must be one of keep_existing, overwrite, fill_empty, prefer_source"}}`, response.Body)
+}
+
+func TestRoutesServeSpec(t *testing.T) {
+	response, _ := NewRouter(Routes(model.NewInMemoryBookRepository())).Handle(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/openapi.json"})
+
+	expected, _ := json.Marshal(Spec())
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, string(expected), response.Body)
+	assert.Equal(t, "application/json", response.Headers["Content-Type"])
+}
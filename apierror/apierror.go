@@ -0,0 +1,92 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Codes tell clients what went wrong, unlike messages they never change
+const (
+	InvalidRequest         = "INVALID_REQUEST"
+	InvalidParameter       = "INVALID_PARAMETER"
+	ValidationFailed       = "VALIDATION_FAILED"
+	AuthenticationRequired = "AUTHENTICATION_REQUIRED"
+	InvalidCredentials     = "INVALID_CREDENTIALS"
+	OriginNotAllowed       = "ORIGIN_NOT_ALLOWED"
+	BookNotFound           = "BOOK_NOT_FOUND"
+	ScrapJobNotFound       = "SCRAP_JOB_NOT_FOUND"
+	RouteNotFound          = "ROUTE_NOT_FOUND"
+	MethodNotAllowed       = "METHOD_NOT_ALLOWED"
+	RequestTooLarge        = "REQUEST_TOO_LARGE"
+	RateLimited            = "RATE_LIMITED"
+	ScrapFailed            = "SCRAP_FAILED"
+	DatabaseUnavailable    = "DATABASE_UNAVAILABLE"
+	InternalError          = "INTERNAL_ERROR"
+)
+
+// requestIDHeader is middleware.RequestIDHeader, middleware can't be imported since it answers with Errors too
+const requestIDHeader = "X-Request-Id"
+
+// Error is what's answered when a request fails. Its body is an envelope:
+//
+// {"error": {"status": 404, "code": "BOOK_NOT_FOUND", "message": "No book with ID 42", "requestId": "..."}}
+type Error struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// Headers are answered along with the error, e.g. Retry-After
+	Headers map[string]string `json:"-"`
+}
+
+type envelope struct {
+	Error *Error `json:"error"`
+}
+
+// New creates an Error answered with status, code is one of the codes above
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Internal creates the Error answered when something unexpected happens, details are left out of message on purpose
+func Internal() *Error {
+	return New(500, InternalError, "Sorry, something went wrong on our side")
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WithHeader returns a copy of e answered along with header
+func (e *Error) WithHeader(name string, value string) *Error {
+	copied := *e
+	copied.Headers = make(map[string]string, len(e.Headers)+1)
+	for headerName, headerValue := range e.Headers {
+		copied.Headers[headerName] = headerValue
+	}
+
+	copied.Headers[name] = value
+	return &copied
+}
+
+// Response answers request with e, the envelope carries request's ID when it has one
+func (e *Error) Response(request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	answered := *e
+	answered.RequestID = requestID(request)
+
+	body, _ := json.Marshal(envelope{Error: &answered})
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: e.Status, Headers: e.Headers}
+}
+
+func requestID(request events.APIGatewayProxyRequest) string {
+	for name, value := range request.Headers {
+		if http.CanonicalHeaderKey(name) == requestIDHeader {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,35 @@
+package apierror
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCarriesRequestID(t *testing.T) {
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"x-request-id": "abc"}}
+
+	response := New(404, BookNotFound, "No book with ID 42").Response(request)
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":404,"code":"BOOK_NOT_FOUND","message":"No book with ID 42","requestId":"abc"}}`,
+		StatusCode: 404,
+	}
+	assert.Equal(t, expectedResponse, response)
+}
+
+func TestResponseEscapesMessage(t *testing.T) {
+	response := New(400, InvalidParameter, `"id" parameter must be an integer`).Response(events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"id\" parameter must be an integer"}}`, response.Body)
+}
+
+func TestWithHeaderLeavesOriginalAlone(t *testing.T) {
+	original := New(503, DatabaseUnavailable, "Database is unavailable")
+
+	withHeader := original.WithHeader("Retry-After", "5")
+
+	assert.Nil(t, original.Headers)
+	assert.Equal(t, map[string]string{"Retry-After": "5"}, withHeader.Response(events.APIGatewayProxyRequest{}).Headers)
+}
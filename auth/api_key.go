@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+)
+
+// APIKeyHeader carries API keys, see model.NewAPIKey
+const APIKeyHeader = "X-Api-Key"
+
+// APIKeyFinder retrieves the API key with given hash, nil when there's none
+type APIKeyFinder func(hash string) (*model.APIKey, error)
+
+// APIKeyAuthenticator authenticates requests carrying a stored API key that wasn't revoked
+type APIKeyAuthenticator struct {
+	find APIKeyFinder
+}
+
+// NewAPIKeyAuthenticator creates an APIKeyAuthenticator looking keys up with find
+func NewAPIKeyAuthenticator(find APIKeyFinder) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{find: find}
+}
+
+// Authenticate implements Authenticator
+func (a *APIKeyAuthenticator) Authenticate(request events.APIGatewayProxyRequest) (*Principal, error) {
+	key := middleware.GetHeader(request, APIKeyHeader)
+	if key == "" {
+		return nil, nil
+	}
+
+	apiKey, err := a.find(model.HashAPIKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	if apiKey == nil || apiKey.Revoked() {
+		return nil, invalidCredentials("API key is invalid or was revoked")
+	}
+
+	return &Principal{ID: fmt.Sprintf("api_key:%d", apiKey.ID), Name: apiKey.Name, Method: "api_key"}, nil
+}
+
+// FindAPIKeyInDB is an APIKeyFinder looking keys up in the database handlers use
+func FindAPIKeyInDB(hash string) (*model.APIKey, error) {
+	db, err := utils.GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return model.FindAPIKeyByHash(db, hash)
+}
+
+// noAPIKeys is the APIKeyFinder used when DB_DRIVER is memory, it rejects every key
+func noAPIKeys(hash string) (*model.APIKey, error) {
+	return nil, invalidCredentials("API keys are not available, DB_DRIVER is memory")
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// findIn looks keys up in keys, by hash
+func findIn(keys ...model.APIKey) APIKeyFinder {
+	return func(hash string) (*model.APIKey, error) {
+		for _, key := range keys {
+			if key.Hash == hash {
+				return &key, nil
+			}
+		}
+
+		return nil, nil
+	}
+}
+
+func withAPIKey(key string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{Headers: map[string]string{"x-api-key": key}}
+}
+
+func TestAPIKeyAuthenticatorAcceptsStoredKeys(t *testing.T) {
+	key, apiKey, _ := model.NewAPIKey("catalog")
+	apiKey.ID = 7
+
+	principal, err := NewAPIKeyAuthenticator(findIn(apiKey)).Authenticate(withAPIKey(key))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &Principal{ID: "api_key:7", Name: "catalog", Method: "api_key"}, principal)
+}
+
+func TestAPIKeyAuthenticatorRejectsUnknownAndRevokedKeys(t *testing.T) {
+	revokedKey, revoked, _ := model.NewAPIKey("catalog")
+	revoked.RevokedAt = null.TimeFrom(testNow)
+	authenticator := NewAPIKeyAuthenticator(findIn(revoked))
+
+	for _, key := range []string{revokedKey, "bk_unknown"} {
+		principal, err := authenticator.Authenticate(withAPIKey(key))
+
+		assert.Nil(t, principal)
+		assert.Equal(t, &InvalidCredentialsError{Reason: "API key is invalid or was revoked"}, err)
+	}
+}
+
+func TestAPIKeyAuthenticatorIgnoresRequestsWithoutKey(t *testing.T) {
+	failing := func(hash string) (*model.APIKey, error) {
+		return nil, errors.New("Should not be called")
+	}
+
+	principal, err := NewAPIKeyAuthenticator(failing).Authenticate(events.APIGatewayProxyRequest{})
+
+	assert.Nil(t, principal)
+	assert.Equal(t, nil, err)
+}
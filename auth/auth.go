@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/utils"
+)
+
+// Principal is who a request was made by
+type Principal struct {
+	// ID is "api_key:" followed by the API key's ID, or the token's subject
+	ID string
+
+	// Name is the API key's name or the token's name claim, it may be empty
+	Name string
+
+	// Method tells how principal authenticated, either "api_key" or "jwt"
+	Method string
+}
+
+// Authenticator finds out who made a request. It returns no principal and no error when request doesn't carry the
+// credentials it checks, and an *InvalidCredentialsError when it does but they are wrong
+type Authenticator interface {
+	Authenticate(request events.APIGatewayProxyRequest) (*Principal, error)
+}
+
+// NewAuthenticatorFromEnv creates the Authenticator handlers use: stored API keys are always accepted, tokens are
+// when AUTH_JWT_* environment variables configure a key, see NewJWTConfigFromEnv. When DB_DRIVER is memory there's no
+// database to store API keys in, so every key is rejected
+func NewAuthenticatorFromEnv() (Authenticator, error) {
+	findAPIKey := FindAPIKeyInDB
+	if utils.InMemory() {
+		findAPIKey = noAPIKeys
+	}
+
+	authenticators := Authenticators{NewAPIKeyAuthenticator(findAPIKey)}
+
+	jwtConfig, err := NewJWTConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if jwtConfig != nil {
+		authenticators = append(authenticators, NewJWTAuthenticator(*jwtConfig))
+	}
+
+	return authenticators, nil
+}
+
+// Authenticators tries each authenticator in turn, the first one that finds a principal or fails wins
+type Authenticators []Authenticator
+
+// Authenticate implements Authenticator
+func (a Authenticators) Authenticate(request events.APIGatewayProxyRequest) (*Principal, error) {
+	for _, authenticator := range a {
+		principal, err := authenticator.Authenticate(request)
+		if principal != nil || err != nil {
+			return principal, err
+		}
+	}
+
+	return nil, nil
+}
+
+// InvalidCredentialsError tells that a request's credentials were rejected, Reason is safe to tell clients
+type InvalidCredentialsError struct {
+	Reason string
+}
+
+func (e *InvalidCredentialsError) Error() string {
+	return e.Reason
+}
+
+func invalidCredentials(format string, args ...interface{}) error {
+	return &InvalidCredentialsError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// IsInvalidCredentials tells whether err comes from credentials being rejected
+func IsInvalidCredentials(err error) bool {
+	_, ok := err.(*InvalidCredentialsError)
+	return ok
+}
+
+// Policy tells whether request must be authenticated
+type Policy func(request events.APIGatewayProxyRequest) bool
+
+// Always requires every request to be authenticated
+func Always(request events.APIGatewayProxyRequest) bool {
+	return true
+}
+
+// Middleware authenticates requests with authenticator. Requests with wrong credentials are answered with 401
+// INVALID_CREDENTIALS, and so are requests without any when required says they must be authenticated. Requests
+// that may stay anonymous reach handlers without a principal
+func Middleware(authenticator Authenticator, required Policy) middleware.Middleware {
+	return func(next middleware.HandlerFunc) middleware.HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			principal, err := authenticator.Authenticate(request)
+			if IsInvalidCredentials(err) {
+				return apierror.New(401, apierror.InvalidCredentials, err.Error()).Response(request), nil
+			}
+
+			if utils.IsUnavailable(err) {
+				return utils.UnavailableResponse(request, err), nil
+			}
+
+			if err != nil {
+				return apierror.Internal().Response(request), nil
+			}
+
+			if principal == nil && required(request) {
+				return apierror.New(401, apierror.AuthenticationRequired, "Authentication is required, send an API key in X-Api-Key or a bearer token").Response(request), nil
+			}
+
+			if principal != nil {
+				ctx = withPrincipal(ctx, *principal)
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+type principalKey struct{}
+
+// withPrincipal records principal in ctx for handlers and inner middleware, and its ID for Logging. It's never read
+// from the request: API Gateway authorizers may have put anything in its authorizer context
+func withPrincipal(ctx context.Context, principal Principal) context.Context {
+	middleware.SetPrincipalID(ctx, principal.ID)
+	return context.WithValue(ctx, principalKey{}, &principal)
+}
+
+// GetPrincipal returns who the request ctx belongs to was made by, nil when it's anonymous
+func GetPrincipal(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
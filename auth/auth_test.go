@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorded is the last request a recording handler got, along with its context
+type recorded struct {
+	ctx     context.Context
+	request events.APIGatewayProxyRequest
+}
+
+// recording answers every request with 200 and keeps the last request it got
+func recording() (middleware.HandlerFunc, *recorded) {
+	received := &recorded{}
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received.ctx, received.request = ctx, request
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}, received
+}
+
+func never(request events.APIGatewayProxyRequest) bool {
+	return false
+}
+
+func testAuthenticator() (Authenticator, string) {
+	key, apiKey, _ := model.NewAPIKey("catalog")
+	apiKey.ID = 7
+
+	return Authenticators{NewAPIKeyAuthenticator(findIn(apiKey)), testJWTAuthenticator(JWTConfig{HS256Secret: testSecret})}, key
+}
+
+func TestMiddlewareRecordsPrincipal(t *testing.T) {
+	authenticator, key := testAuthenticator()
+	handler, received := recording()
+
+	response, _ := Middleware(authenticator, Always)(handler)(context.Background(), withAPIKey(key))
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, &Principal{ID: "api_key:7", Name: "catalog", Method: "api_key"}, GetPrincipal(received.ctx))
+
+	response, _ = Middleware(authenticator, Always)(handler)(context.Background(), bearer(signedToken("HS256", testSecret, map[string]interface{}{"sub": "user-7", "exp": 4102444800})))
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, &Principal{ID: "user-7", Method: "jwt"}, GetPrincipal(received.ctx))
+}
+
+func TestMiddlewareSharesPrincipalWithOuterMiddleware(t *testing.T) {
+	authenticator, key := testAuthenticator()
+	handler, _ := recording()
+	output := &bytes.Buffer{}
+
+	middleware.Chain(handler, middleware.Logging(middleware.NewLogger(output)), Middleware(authenticator, Always))(context.Background(), withAPIKey(key))
+
+	assert.Contains(t, output.String(), `"principalId":"api_key:7"`)
+}
+
+func TestMiddlewareIgnoresPrincipalsInAuthorizerContext(t *testing.T) {
+	authenticator, _ := testAuthenticator()
+	handler, received := recording()
+	request := events.APIGatewayProxyRequest{HTTPMethod: "GET"}
+	request.RequestContext.Authorizer = map[string]interface{}{"principalId": "api_key:1", "authMethod": "api_key"}
+
+	response, _ := Middleware(authenticator, never)(handler)(context.Background(), request)
+
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Nil(t, GetPrincipal(received.ctx))
+	assert.Equal(t, request.RequestContext.Authorizer, received.request.RequestContext.Authorizer)
+}
+
+func TestMiddlewareRequiresAuthenticationWhenPolicySaysSo(t *testing.T) {
+	authenticator, _ := testAuthenticator()
+	handler, received := recording()
+
+	response, _ := Middleware(authenticator, Always)(handler)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, 401, response.StatusCode)
+	assert.Contains(t, response.Body, `"code":"AUTHENTICATION_REQUIRED"`)
+
+	response, _ = Middleware(authenticator, never)(handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.Equal(t, 200, response.StatusCode)
+	assert.Equal(t, "GET", received.request.HTTPMethod)
+	assert.Nil(t, GetPrincipal(received.ctx))
+}
+
+func TestMiddlewareRejectsInvalidCredentialsEvenWhenOptional(t *testing.T) {
+	authenticator, _ := testAuthenticator()
+	handler, _ := recording()
+
+	response, _ := Middleware(authenticator, never)(handler)(context.Background(), withAPIKey("bk_unknown"))
+
+	assert.Equal(t, events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":401,"code":"INVALID_CREDENTIALS","message":"API key is invalid or was revoked"}}`,
+		StatusCode: 401,
+	}, response)
+}
+
+func TestMiddlewareAnswersWhenCredentialsCannotBeChecked(t *testing.T) {
+	handler, _ := recording()
+	unavailable := NewAPIKeyAuthenticator(func(hash string) (*model.APIKey, error) {
+		return nil, &utils.UnavailableError{Reason: "could not connect"}
+	})
+	failing := NewAPIKeyAuthenticator(func(hash string) (*model.APIKey, error) {
+		return nil, errors.New("database error")
+	})
+
+	response, _ := Middleware(unavailable, Always)(handler)(context.Background(), withAPIKey("bk_key"))
+	assert.Equal(t, 503, response.StatusCode)
+
+	response, _ = Middleware(failing, Always)(handler)(context.Background(), withAPIKey("bk_key"))
+	assert.Equal(t, 500, response.StatusCode)
+}
+
+func TestNewAuthenticatorFromEnvRejectsAPIKeysWithoutDatabase(t *testing.T) {
+	os.Setenv("DB_DRIVER", "memory")
+	defer os.Unsetenv("DB_DRIVER")
+
+	authenticator, err := NewAuthenticatorFromEnv()
+	assert.Equal(t, nil, err)
+
+	principal, err := authenticator.Authenticate(withAPIKey("bk_key"))
+	assert.Nil(t, principal)
+	assert.Equal(t, &InvalidCredentialsError{Reason: "API keys are not available, DB_DRIVER is memory"}, err)
+}
@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/middleware"
+)
+
+var defaultJWTLeeway = time.Minute
+
+// JWTConfig tells which tokens are accepted. Tokens must be signed with HS256 or RS256 by one of the configured keys
+// and have an expiry and a subject
+type JWTConfig struct {
+	// HS256Secret verifies HS256 tokens, they are rejected when it's empty
+	HS256Secret []byte
+
+	// RS256PublicKey verifies RS256 tokens, they are rejected when it's nil
+	RS256PublicKey *rsa.PublicKey
+
+	// Issuer and Audience must be the token's iss and one of its aud when set
+	Issuer   string
+	Audience string
+
+	// Leeway tolerates clocks being slightly off when checking exp and nbf
+	Leeway time.Duration
+}
+
+// NewJWTConfigFromEnv creates a JWTConfig from AUTH_JWT_* environment variables, AUTH_JWT_RS256_PUBLIC_KEY is PEM
+// encoded. It returns nil when neither key is set, i.e. when tokens aren't accepted at all
+func NewJWTConfigFromEnv() (*JWTConfig, error) {
+	config := JWTConfig{
+		HS256Secret: []byte(os.Getenv("AUTH_JWT_HS256_SECRET")),
+		Issuer:      os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:    os.Getenv("AUTH_JWT_AUDIENCE"),
+		Leeway:      defaultJWTLeeway,
+	}
+
+	if publicKey := os.Getenv("AUTH_JWT_RS256_PUBLIC_KEY"); publicKey != "" {
+		key, err := ParseRSAPublicKey([]byte(publicKey))
+		if err != nil {
+			return nil, fmt.Errorf("AUTH_JWT_RS256_PUBLIC_KEY is invalid: %s", err.Error())
+		}
+
+		config.RS256PublicKey = key
+	}
+
+	if len(config.HS256Secret) == 0 && config.RS256PublicKey == nil {
+		return nil, nil
+	}
+
+	if leeway, err := time.ParseDuration(os.Getenv("AUTH_JWT_LEEWAY")); err == nil && leeway >= 0 {
+		config.Leeway = leeway
+	}
+
+	return &config, nil
+}
+
+// JWTAuthenticator authenticates requests carrying a valid token in an "Authorization: Bearer" header
+type JWTAuthenticator struct {
+	config JWTConfig
+	now    func() time.Time
+}
+
+// NewJWTAuthenticator creates a JWTAuthenticator accepting tokens as config tells
+func NewJWTAuthenticator(config JWTConfig) *JWTAuthenticator {
+	return &JWTAuthenticator{config: config, now: time.Now}
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+type jwtClaims struct {
+	Subject   string   `json:"sub"`
+	Name      string   `json:"name"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt *float64 `json:"exp"`
+	NotBefore *float64 `json:"nbf"`
+}
+
+// audience is a token's aud claim, which is either a string or a list of them
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud must be a string or a list of strings")
+	}
+
+	*a = list
+	return nil
+}
+
+// Authenticate implements Authenticator
+func (a *JWTAuthenticator) Authenticate(request events.APIGatewayProxyRequest) (*Principal, error) {
+	authorization := middleware.GetHeader(request, "Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return nil, nil
+	}
+
+	claims, err := a.verify(strings.TrimSpace(authorization[7:]))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Principal{ID: claims.Subject, Name: claims.Name, Method: "jwt"}, nil
+}
+
+// verify checks token's signature and then its claims, see JWTConfig
+func (a *JWTAuthenticator) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidCredentials("Token is malformed")
+	}
+
+	header := jwtHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidCredentials("Token header is malformed")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidCredentials("Token signature is malformed")
+	}
+
+	if err := a.verifySignature(header.Algorithm, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	claims := jwtClaims{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, invalidCredentials("Token claims are malformed")
+	}
+
+	if err := a.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return &claims, nil
+}
+
+func (a *JWTAuthenticator) verifySignature(algorithm string, signed string, signature []byte) error {
+	switch {
+	case algorithm == "HS256" && len(a.config.HS256Secret) > 0:
+		mac := hmac.New(sha256.New, a.config.HS256Secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return invalidCredentials("Token signature is invalid")
+		}
+	case algorithm == "RS256" && a.config.RS256PublicKey != nil:
+		hash := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(a.config.RS256PublicKey, crypto.SHA256, hash[:], signature) != nil {
+			return invalidCredentials("Token signature is invalid")
+		}
+	default:
+		return invalidCredentials("Tokens signed with %q are not accepted", algorithm)
+	}
+
+	return nil
+}
+
+func (a *JWTAuthenticator) verifyClaims(claims jwtClaims) error {
+	now := a.now()
+
+	if claims.ExpiresAt == nil {
+		return invalidCredentials("Token must have an expiry")
+	}
+
+	if now.Add(-a.config.Leeway).After(unixTime(*claims.ExpiresAt)) {
+		return invalidCredentials("Token has expired")
+	}
+
+	if claims.NotBefore != nil && now.Add(a.config.Leeway).Before(unixTime(*claims.NotBefore)) {
+		return invalidCredentials("Token is not valid yet")
+	}
+
+	if a.config.Issuer != "" && claims.Issuer != a.config.Issuer {
+		return invalidCredentials("Token was not issued by %s", a.config.Issuer)
+	}
+
+	if a.config.Audience != "" && !claims.Audience.contains(a.config.Audience) {
+		return invalidCredentials("Token is not meant for %s", a.config.Audience)
+	}
+
+	if claims.Subject == "" {
+		return invalidCredentials("Token must have a subject")
+	}
+
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, item := range a {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func unixTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// ParseRSAPublicKey reads a PEM encoded RSA public key, either PKIX ("BEGIN PUBLIC KEY") or PKCS #1 ("BEGIN RSA
+// PUBLIC KEY")
+func ParseRSAPublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("RSA public key must be PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("Public key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
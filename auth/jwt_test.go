@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSecret = []byte("not so secret")
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+// signedToken creates a token signed with algorithm, key is a secret for HS256 and a private key for RS256
+func signedToken(algorithm string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": algorithm, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch algorithm {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		hash := sha256.Sum256([]byte(signed))
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, hash[:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":  "user-7",
+		"name": "Catalog editor",
+		"iss":  "https://auth.example.com",
+		"aud":  []string{"books", "authors"},
+		"exp":  testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func bearer(token string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{Headers: map[string]string{"authorization": "Bearer " + token}}
+}
+
+func testJWTAuthenticator(config JWTConfig) *JWTAuthenticator {
+	authenticator := NewJWTAuthenticator(config)
+	authenticator.now = func() time.Time { return testNow }
+
+	return authenticator
+}
+
+func TestJWTAuthenticatorAcceptsHS256Tokens(t *testing.T) {
+	authenticator := testJWTAuthenticator(JWTConfig{HS256Secret: testSecret, Issuer: "https://auth.example.com", Audience: "books"})
+
+	principal, err := authenticator.Authenticate(bearer(signedToken("HS256", testSecret, validClaims())))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &Principal{ID: "user-7", Name: "Catalog editor", Method: "jwt"}, principal)
+}
+
+func TestJWTAuthenticatorAcceptsRS256Tokens(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	authenticator := testJWTAuthenticator(JWTConfig{RS256PublicKey: &privateKey.PublicKey})
+
+	principal, err := authenticator.Authenticate(bearer(signedToken("RS256", privateKey, validClaims())))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "user-7", principal.ID)
+
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, err = authenticator.Authenticate(bearer(signedToken("RS256", otherKey, validClaims())))
+	assert.Equal(t, &InvalidCredentialsError{Reason: "Token signature is invalid"}, err)
+}
+
+func TestJWTAuthenticatorIgnoresRequestsWithoutToken(t *testing.T) {
+	authenticator := testJWTAuthenticator(JWTConfig{HS256Secret: testSecret})
+
+	for _, request := range []events.APIGatewayProxyRequest{{}, {Headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}}} {
+		principal, err := authenticator.Authenticate(request)
+
+		assert.Nil(t, principal)
+		assert.Equal(t, nil, err)
+	}
+}
+
+func TestJWTAuthenticatorRejectsInvalidTokens(t *testing.T) {
+	authenticator := testJWTAuthenticator(JWTConfig{HS256Secret: testSecret, Issuer: "https://auth.example.com", Audience: "books"})
+
+	withClaim := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+
+		return claims
+	}
+
+	unsigned := signedToken("HS256", testSecret, validClaims())
+	unsigned = unsigned[:len(unsigned)-43] + base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+
+	tokens := map[string]string{
+		"not.a.token":   "Token header is malformed",
+		"only-one-part": "Token is malformed",
+		unsigned:        "Token signature is invalid",
+		signedToken("HS256", []byte("other secret"), validClaims()):                            "Token signature is invalid",
+		signedToken("none", nil, validClaims()):                                                `Tokens signed with "none" are not accepted`,
+		signedToken("HS256", testSecret, withClaim("exp", nil)):                                "Token must have an expiry",
+		signedToken("HS256", testSecret, withClaim("exp", testNow.Add(-2*time.Minute).Unix())): "Token has expired",
+		signedToken("HS256", testSecret, withClaim("nbf", testNow.Add(2*time.Minute).Unix())):  "Token is not valid yet",
+		signedToken("HS256", testSecret, withClaim("iss", "https://evil.example.com")):         "Token was not issued by https://auth.example.com",
+		signedToken("HS256", testSecret, withClaim("aud", "authors")):                          "Token is not meant for books",
+		signedToken("HS256", testSecret, withClaim("sub", nil)):                                "Token must have a subject",
+	}
+
+	for token, reason := range tokens {
+		principal, err := authenticator.Authenticate(bearer(token))
+
+		assert.Nil(t, principal, reason)
+		assert.Equal(t, &InvalidCredentialsError{Reason: reason}, err, reason)
+	}
+}
+
+func TestJWTAuthenticatorToleratesLeeway(t *testing.T) {
+	authenticator := testJWTAuthenticator(JWTConfig{HS256Secret: testSecret, Leeway: time.Minute})
+	claims := validClaims()
+	claims["exp"] = testNow.Add(-30 * time.Second).Unix()
+
+	principal, err := authenticator.Authenticate(bearer(signedToken("HS256", testSecret, claims)))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "user-7", principal.ID)
+}
+
+func TestNewJWTConfigFromEnv(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	publicKey, _ := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+
+	config, err := NewJWTConfigFromEnv()
+	assert.Equal(t, nil, err)
+	assert.Nil(t, config)
+
+	os.Setenv("AUTH_JWT_RS256_PUBLIC_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})))
+	os.Setenv("AUTH_JWT_ISSUER", "https://auth.example.com")
+	os.Setenv("AUTH_JWT_LEEWAY", "30s")
+	defer os.Unsetenv("AUTH_JWT_RS256_PUBLIC_KEY")
+	defer os.Unsetenv("AUTH_JWT_ISSUER")
+	defer os.Unsetenv("AUTH_JWT_LEEWAY")
+
+	config, err = NewJWTConfigFromEnv()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &privateKey.PublicKey, config.RS256PublicKey)
+	assert.Equal(t, "https://auth.example.com", config.Issuer)
+	assert.Equal(t, 30*time.Second, config.Leeway)
+	assert.Equal(t, 0, len(config.HS256Secret))
+
+	os.Setenv("AUTH_JWT_RS256_PUBLIC_KEY", "not a key")
+	_, err = NewJWTConfigFromEnv()
+	assert.Equal(t, "AUTH_JWT_RS256_PUBLIC_KEY is invalid: RSA public key must be PEM encoded", err.Error())
+}
+
+func TestParseRSAPublicKeyReadsPKCS1Keys(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey)})
+
+	key, err := ParseRSAPublicKey(encoded)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &privateKey.PublicKey, key)
+}
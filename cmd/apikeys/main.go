@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+	"github.com/jinzhu/gorm"
+)
+
+const usage = `Usage:
+  apikeys create NAME
+  apikeys list
+  apikeys revoke ID`
+
+// createdKey is what's printed when a key is created, the only time the key itself is shown
+type createdKey struct {
+	model.APIKey
+	Key string `json:"key"`
+}
+
+// run executes command line args against db and returns the exit code: 0 on success, 1 when something failed and 2
+// when args are wrong
+func run(args []string, db *gorm.DB, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+
+	switch {
+	case args[0] == "create" && len(args) == 2:
+		return runCreate(args[1], db, stdout, stderr)
+	case args[0] == "list" && len(args) == 1:
+		return runList(db, stdout, stderr)
+	case args[0] == "revoke" && len(args) == 2:
+		return runRevoke(args[1], db, stdout, stderr)
+	default:
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+}
+
+func runCreate(name string, db *gorm.DB, stdout io.Writer, stderr io.Writer) int {
+	key, apiKey, err := model.NewAPIKey(name)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not generate key: %s\n", err.Error())
+		return 1
+	}
+
+	if err := apiKey.Create(db); err != nil {
+		fmt.Fprintf(stderr, "Could not store key: %s\n", err.Error())
+		return 1
+	}
+
+	output, _ := json.MarshalIndent(createdKey{APIKey: apiKey, Key: key}, "", "  ")
+	fmt.Fprintln(stdout, string(output))
+	fmt.Fprintln(stderr, "Keep the key somewhere safe, it can't be shown again")
+
+	return 0
+}
+
+func runList(db *gorm.DB, stdout io.Writer, stderr io.Writer) int {
+	keys, err := model.ListAPIKeys(db)
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not list keys: %s\n", err.Error())
+		return 1
+	}
+
+	output, _ := json.MarshalIndent(keys, "", "  ")
+	fmt.Fprintln(stdout, string(output))
+
+	return 0
+}
+
+func runRevoke(idArg string, db *gorm.DB, stdout io.Writer, stderr io.Writer) int {
+	id, err := strconv.ParseUint(idArg, 10, 32)
+	if err != nil {
+		fmt.Fprintf(stderr, "ID must be an integer, got %s\n", idArg)
+		return 2
+	}
+
+	revoked, err := model.RevokeAPIKey(db, uint(id))
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not revoke key: %s\n", err.Error())
+		return 1
+	}
+
+	if !revoked {
+		fmt.Fprintf(stderr, "There's no key with ID %d or it was revoked already\n", id)
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "Revoked key %d\n", id)
+	return 0
+}
+
+func main() {
+	db, err := utils.GetDB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	os.Exit(run(os.Args[1:], db, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/felipefill/books/migrations"
+	"github.com/felipefill/books/model"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // SQLite dialect for GORM
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database
+	db.DB().SetMaxOpenConns(1)
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestRunCreatesListsAndRevokesKeys(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	assert.Equal(t, 0, run([]string{"create", "catalog"}, db, stdout, stderr))
+
+	created := createdKey{}
+	assert.Equal(t, nil, json.Unmarshal(stdout.Bytes(), &created))
+	assert.Equal(t, "catalog", created.Name)
+
+	stored, _ := model.FindAPIKeyByHash(db, model.HashAPIKey(created.Key))
+	assert.Equal(t, created.ID, stored.ID)
+
+	stdout.Reset()
+	assert.Equal(t, 0, run([]string{"list"}, db, stdout, stderr))
+	assert.NotContains(t, stdout.String(), created.Key)
+	assert.NotContains(t, stdout.String(), stored.Hash)
+	assert.Contains(t, stdout.String(), created.Prefix)
+
+	assert.Equal(t, 0, run([]string{"revoke", "1"}, db, stdout, stderr))
+	assert.Equal(t, 1, run([]string{"revoke", "1"}, db, stdout, stderr))
+
+	stored, _ = model.FindAPIKeyByHash(db, model.HashAPIKey(created.Key))
+	assert.True(t, stored.Revoked())
+}
+
+func TestRunRejectsWrongArgs(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	for _, args := range [][]string{{}, {"create"}, {"list", "all"}, {"revoke", "first"}, {"rotate"}} {
+		assert.Equal(t, 2, run(args, nil, stdout, stderr), args)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io"
+
+	"github.com/felipefill/books/model"
+)
+
+// Export writes every book in books to w, provenance included, and returns how many were written. Books are
+// streamed so that the whole catalog is never in memory at once
+func Export(books model.BookRepository, format Format, w io.Writer) (int, error) {
+	writer := newBookWriter(format, w)
+
+	exported := 0
+	err := books.Each(func(book model.Book) error {
+		if err := writer.Write(book); err != nil {
+			return err
+		}
+
+		exported++
+		return nil
+	})
+	if err != nil {
+		return exported, err
+	}
+
+	return exported, writer.Flush()
+}
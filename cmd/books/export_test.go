@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportWritesNDJSON(t *testing.T) {
+	output := bytes.Buffer{}
+
+	exported, err := Export(model.NewInMemoryBookRepository(sampleBooks...), NDJSON, &output)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, exported)
+	assert.Equal(t, sampleBooksAsNDJSON, output.String())
+}
+
+func TestExportWritesCSV(t *testing.T) {
+	output := bytes.Buffer{}
+
+	exported, err := Export(model.NewInMemoryBookRepository(sampleBooks...), CSV, &output)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, exported)
+	assert.Equal(t, sampleBooksAsCSV, output.String())
+}
+
+func TestExportWritesCSVHeaderWhenThereAreNoBooks(t *testing.T) {
+	output := bytes.Buffer{}
+
+	exported, err := Export(model.NewInMemoryBookRepository(), CSV, &output)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, exported)
+	assert.Equal(t, "id,isbn,title,description,language,source_name,source_url,detail_url,first_seen_at,last_seen_at,content_hash\n", output.String())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("Disk is full")
+}
+
+func TestExportFailsWhenOutputCantBeWritten(t *testing.T) {
+	_, err := Export(model.NewInMemoryBookRepository(sampleBooks...), CSV, failingWriter{})
+
+	assert.EqualError(t, err, "Disk is full")
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/felipefill/books/model"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// Format is how books are written to a file
+type Format string
+
+const (
+	// NDJSON writes one book per line as JSON, the same JSON search answers with provenance included
+	NDJSON Format = "ndjson"
+
+	// CSV writes one book per row after a header naming csvColumns
+	CSV Format = "csv"
+)
+
+// csvColumns are the columns of an exported CSV file, in order. Imported files may have them in any order and may
+// leave out any of them
+var csvColumns = []string{
+	"id", "isbn", "title", "description", "language",
+	"source_name", "source_url", "detail_url", "first_seen_at", "last_seen_at", "content_hash",
+}
+
+// ParseFormat converts value into a Format, it fails when there's no such format
+func ParseFormat(value string) (Format, error) {
+	switch format := Format(value); format {
+	case NDJSON, CSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("Format must be ndjson or csv, got %s", value)
+	}
+}
+
+// formatFor returns format named by value or, when it's empty, the one path's extension tells (NDJSON by default)
+func formatFor(value string, path string) (Format, error) {
+	if value != "" {
+		return ParseFormat(value)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return CSV, nil
+	}
+
+	return NDJSON, nil
+}
+
+// invalidRecordError tells that a single record couldn't be read, the ones after it still can
+type invalidRecordError struct {
+	reason string
+}
+
+func (e *invalidRecordError) Error() string {
+	return e.reason
+}
+
+// bookWriter writes books one at a time, Flush must be called once every book was written
+type bookWriter interface {
+	Write(book model.Book) error
+	Flush() error
+}
+
+// bookReader reads books one at a time, it returns io.EOF once there are no more books and an invalidRecordError
+// for records that can't be read
+type bookReader interface {
+	Read() (model.Book, error)
+}
+
+func newBookWriter(format Format, w io.Writer) bookWriter {
+	if format == CSV {
+		return &csvBookWriter{writer: csv.NewWriter(w)}
+	}
+
+	return &ndjsonBookWriter{writer: bufio.NewWriter(w)}
+}
+
+func newBookReader(format Format, r io.Reader) bookReader {
+	if format == CSV {
+		return &csvBookReader{reader: csv.NewReader(r)}
+	}
+
+	return &ndjsonBookReader{reader: bufio.NewReader(r)}
+}
+
+type ndjsonBookWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *ndjsonBookWriter) Write(book model.Book) error {
+	book.IncludeProvenance = true
+
+	line, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.writer.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (w *ndjsonBookWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// ndjsonBook is how a book is written by ndjsonBookWriter, see model.Book.MarshalJSON
+type ndjsonBook struct {
+	ID          uint             `json:"id"`
+	ISBN        null.String      `json:"isbn"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Language    string           `json:"language"`
+	Provenance  model.Provenance `json:"provenance"`
+}
+
+type ndjsonBookReader struct {
+	reader *bufio.Reader
+}
+
+func (r *ndjsonBookReader) Read() (model.Book, error) {
+	for {
+		// ReadBytes, unlike bufio.Scanner, has no limit on how long a line can be
+		line, err := r.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return model.Book{}, err
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		record := ndjsonBook{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			return model.Book{}, &invalidRecordError{reason: "Failed to parse JSON line into a book"}
+		}
+
+		return model.Book{
+			ID:          record.ID,
+			ISBN:        record.ISBN,
+			Title:       record.Title,
+			Description: record.Description,
+			Language:    record.Language,
+			Provenance:  record.Provenance,
+		}, nil
+	}
+}
+
+type csvBookWriter struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (w *csvBookWriter) Write(book model.Book) error {
+	if !w.wroteHeader {
+		if err := w.writer.Write(csvColumns); err != nil {
+			return err
+		}
+
+		w.wroteHeader = true
+	}
+
+	return w.writer.Write([]string{
+		strconv.FormatUint(uint64(book.ID), 10),
+		book.ISBN.String,
+		book.Title,
+		book.Description,
+		book.Language,
+		book.SourceName.String,
+		book.SourceURL.String,
+		book.DetailURL.String,
+		formatTime(book.FirstSeenAt),
+		formatTime(book.LastSeenAt),
+		book.ContentHash.String,
+	})
+}
+
+// Flush writes the header even when there were no books, so that the file can be imported back
+func (w *csvBookWriter) Flush() error {
+	if !w.wroteHeader {
+		if err := w.writer.Write(csvColumns); err != nil {
+			return err
+		}
+
+		w.wroteHeader = true
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type csvBookReader struct {
+	reader *csv.Reader
+
+	// columns maps each column of the file to its position, it's read from the header on first Read
+	columns map[string]int
+}
+
+func (r *csvBookReader) Read() (model.Book, error) {
+	if r.columns == nil {
+		if err := r.readHeader(); err != nil {
+			return model.Book{}, err
+		}
+	}
+
+	row, err := r.reader.Read()
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		return model.Book{}, &invalidRecordError{reason: parseErr.Err.Error()}
+	} else if err != nil {
+		return model.Book{}, err
+	}
+
+	get := func(column string) string {
+		if index, ok := r.columns[column]; ok {
+			return row[index]
+		}
+
+		return ""
+	}
+
+	book := model.Book{
+		ISBN:        nullString(get("isbn")),
+		Title:       get("title"),
+		Description: get("description"),
+		Language:    get("language"),
+		Provenance: model.Provenance{
+			SourceName:  nullString(get("source_name")),
+			SourceURL:   nullString(get("source_url")),
+			DetailURL:   nullString(get("detail_url")),
+			ContentHash: nullString(get("content_hash")),
+		},
+	}
+
+	if id := get("id"); id != "" {
+		parsedID, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			return book, &invalidRecordError{reason: fmt.Sprintf("ID must be a number, got %s", id)}
+		}
+
+		book.ID = uint(parsedID)
+	}
+
+	if book.FirstSeenAt, err = parseTime("first_seen_at", get("first_seen_at")); err != nil {
+		return book, err
+	}
+
+	if book.LastSeenAt, err = parseTime("last_seen_at", get("last_seen_at")); err != nil {
+		return book, err
+	}
+
+	return book, nil
+}
+
+func (r *csvBookReader) readHeader() error {
+	header, err := r.reader.Read()
+	if err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, column := range csvColumns {
+		known[column] = true
+	}
+
+	r.columns = make(map[string]int)
+	for index, column := range header {
+		column = strings.TrimSpace(column)
+		if !known[column] {
+			return fmt.Errorf("Unknown CSV column %s, columns must be %s", column, strings.Join(csvColumns, ", "))
+		}
+
+		r.columns[column] = index
+	}
+
+	return nil
+}
+
+func nullString(value string) null.String {
+	return null.NewString(value, value != "")
+}
+
+func formatTime(value null.Time) string {
+	if !value.Valid {
+		return ""
+	}
+
+	return value.Time.Format(time.RFC3339Nano)
+}
+
+func parseTime(column string, value string) (null.Time, error) {
+	if value == "" {
+		return null.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return null.Time{}, &invalidRecordError{reason: fmt.Sprintf("%s must be an RFC 3339 time, got %s", column, value)}
+	}
+
+	return null.TimeFrom(parsed), nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+)
+
+// ImportOptions tells how books are imported
+type ImportOptions struct {
+	Format Format
+	Policy model.MergePolicy
+
+	// DryRun tells what importing would do without storing anything
+	DryRun bool
+}
+
+// ImportSummary counts what happened to the records that were read, see Failure for those that failed
+type ImportSummary struct {
+	Read      int  `json:"read"`
+	Created   int  `json:"created"`
+	Updated   int  `json:"updated"`
+	Unchanged int  `json:"unchanged"`
+	Failed    int  `json:"failed"`
+	DryRun    bool `json:"dryRun"`
+}
+
+// Failure is a record that couldn't be imported, record 1 is the first book of the file
+type Failure struct {
+	Record int    `json:"record"`
+	Title  string `json:"title,omitempty"`
+	Error  string `json:"error"`
+}
+
+// Import reads books from r and stores them the way create does: each one is validated and then stored or merged
+// into the same stored book according to policy. IDs are ignored since they differ between environments, books are
+// matched by identity instead. Records that fail are given to report and skipped, Import only stops when no further
+// record could be imported either: r can't be read or database is unavailable
+func Import(books model.BookRepository, r io.Reader, options ImportOptions, report func(Failure)) (ImportSummary, error) {
+	summary := ImportSummary{DryRun: options.DryRun}
+
+	if options.DryRun {
+		// Merging into a copy of stored books tells what would change while leaving them untouched
+		stored, err := books.GetAll()
+		if err != nil {
+			return summary, err
+		}
+
+		books = model.NewInMemoryBookRepository(stored.Books...)
+	}
+
+	reader := newBookReader(options.Format, r)
+	for {
+		book, err := reader.Read()
+		if err == io.EOF {
+			return summary, nil
+		}
+
+		if _, ok := err.(*invalidRecordError); !ok && err != nil {
+			return summary, err
+		}
+
+		summary.Read++
+
+		if err == nil {
+			err = importBook(books, &book, options.Policy, &summary)
+		}
+
+		if utils.IsUnavailable(err) {
+			return summary, err
+		}
+
+		if err != nil {
+			summary.Failed++
+			if report != nil {
+				report(Failure{Record: summary.Read, Title: book.Title, Error: err.Error()})
+			}
+		}
+	}
+}
+
+func importBook(books model.BookRepository, book *model.Book, policy model.MergePolicy, summary *ImportSummary) error {
+	if err := book.Validate(); err != nil {
+		return err
+	}
+
+	book.ID = 0
+
+	result, err := books.StoreOrMerge(book, policy)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Created:
+		summary.Created++
+	case len(result.Changes) > 0:
+		summary.Updated++
+	default:
+		summary.Unchanged++
+	}
+
+	return nil
+}
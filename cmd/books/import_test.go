@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestImportRestoresExportedBooks(t *testing.T) {
+	for _, format := range []Format{NDJSON, CSV} {
+		exported := bytes.Buffer{}
+		Export(model.NewInMemoryBookRepository(sampleBooks...), format, &exported)
+
+		books := model.NewInMemoryBookRepository()
+		summary, err := Import(books, &exported, ImportOptions{Format: format, Policy: keepExisting}, nil)
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, ImportSummary{Read: 2, Created: 2}, summary)
+
+		imported, _ := books.GetAll()
+		assert.Equal(t, sampleBooks, imported.Books, string(format))
+	}
+}
+
+func TestImportMergesStoredBooksAndReportsFailures(t *testing.T) {
+	stored := sampleBooks[0]
+	stored.Language = ""
+
+	books := model.NewInMemoryBookRepository(stored)
+	input := strings.Join([]string{
+		`{"id":7,"isbn":"978-1-61729-329-0","title":"Kotlin In Action!","description":"Another description","language":"PT"}`,
+		`{"id":8,"isbn":"9781617293290","title":"Kotlin in Action","description":"Another description","language":"PT"}`,
+		`{"title": "Broken"`,
+		``,
+		`{"title":"No description","isbn":"9780000000000","language":"EN"}`,
+		`{"title":"Brand new","description":"A new book","isbn":"9780804429573","language":"EN"}`,
+	}, "\n")
+
+	failures := make([]Failure, 0)
+	summary, err := Import(books, strings.NewReader(input), ImportOptions{Format: NDJSON, Policy: model.MergePolicy{Strategy: model.FillEmpty}}, func(failure Failure) {
+		failures = append(failures, failure)
+	})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ImportSummary{Read: 5, Created: 1, Updated: 1, Unchanged: 1, Failed: 2}, summary)
+	assert.Equal(t, []Failure{
+		Failure{Record: 3, Error: "Failed to parse JSON line into a book"},
+		Failure{Record: 4, Title: "No description", Error: "Description cannot be null nor empty"},
+	}, failures)
+
+	merged, _ := books.FindByID(1)
+	assert.Equal(t, "Kotlin in Action", merged.Title)
+	assert.Equal(t, stored.Description, merged.Description)
+	assert.Equal(t, "PT", merged.Language)
+
+	created, _ := books.FindByID(2)
+	assert.Equal(t, "Brand new", created.Title)
+}
+
+func TestImportDryRunStoresNothing(t *testing.T) {
+	books := model.NewInMemoryBookRepository(sampleBooks[0])
+
+	summary, err := Import(books, strings.NewReader(sampleBooksAsNDJSON), ImportOptions{Format: NDJSON, Policy: keepExisting, DryRun: true}, nil)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ImportSummary{Read: 2, Created: 1, Unchanged: 1, DryRun: true}, summary)
+
+	stored, _ := books.GetAll()
+	assert.Equal(t, []model.Book{sampleBooks[0]}, stored.Books)
+}
+
+func TestImportReportsInvalidCSVRecords(t *testing.T) {
+	input := `title,description,isbn,language,first_seen_at
+Kotlin in Action,A book,9781617293290,EN,yesterday
+Kotlin Cookbook,A book
+Brand new,A new book,9780804429573,EN,2019-03-10T12:30:00Z
+`
+
+	books := model.NewInMemoryBookRepository()
+	failures := make([]Failure, 0)
+	summary, err := Import(books, strings.NewReader(input), ImportOptions{Format: CSV, Policy: keepExisting}, func(failure Failure) {
+		failures = append(failures, failure)
+	})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, ImportSummary{Read: 3, Created: 1, Failed: 2}, summary)
+	assert.Equal(t, []Failure{
+		Failure{Record: 1, Title: "Kotlin in Action", Error: "first_seen_at must be an RFC 3339 time, got yesterday"},
+		Failure{Record: 2, Error: "wrong number of fields"},
+	}, failures)
+
+	created, _ := books.FindByID(1)
+	assert.Equal(t, model.Book{
+		ID:          1,
+		Title:       "Brand new",
+		Description: "A new book",
+		ISBN:        null.StringFrom("9780804429573"),
+		Language:    "EN",
+		Provenance:  model.Provenance{FirstSeenAt: null.TimeFrom(seenAt)},
+	}, *created)
+}
+
+func TestImportFailsOnUnknownCSVColumn(t *testing.T) {
+	_, err := Import(model.NewInMemoryBookRepository(), strings.NewReader("title,author\n"), ImportOptions{Format: CSV}, nil)
+
+	assert.EqualError(t, err, "Unknown CSV column author, columns must be id, isbn, title, description, language, source_name, source_url, detail_url, first_seen_at, last_seen_at, content_hash")
+}
+
+type unreadable struct{}
+
+func (unreadable) Read(p []byte) (int, error) {
+	return 0, errors.New("Connection reset")
+}
+
+func TestImportStopsWhenInputCantBeRead(t *testing.T) {
+	_, err := Import(model.NewInMemoryBookRepository(), unreadable{}, ImportOptions{Format: NDJSON}, nil)
+
+	assert.EqualError(t, err, "Connection reset")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+)
+
+const usage = `Usage:
+  books export [-format ndjson|csv] [-output FILE]
+  books import [-format ndjson|csv] [-merge STRATEGY] [-dry-run] [-error-report FILE] [FILE]`
+
+// run executes command line args against books and returns the exit code: 0 on success, 1 when something failed
+// and 2 when args are wrong
+func run(args []string, books model.BookRepository, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+
+	switch args[0] {
+	case "export":
+		return runExport(args[1:], books, stdout, stderr)
+	case "import":
+		return runImport(args[1:], books, stdin, stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "Unknown command %s\n%s\n", args[0], usage)
+		return 2
+	}
+}
+
+// runExport writes books to -output, or to stdout when it's not set, and tells how many were exported on stderr
+func runExport(args []string, books model.BookRepository, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	formatName := flags.String("format", "", "ndjson or csv, by default it's csv when -output ends with .csv and ndjson otherwise")
+	output := flags.String("output", "", "file books are written to, stdout by default")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	format, err := formatFor(*formatName, *output)
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+
+	w := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+		defer file.Close()
+
+		w = file
+	}
+
+	exported, err := Export(books, format, w)
+	if err != nil {
+		fmt.Fprintf(stderr, "Export failed after %d books: %s\n", exported, err.Error())
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "Exported %d books\n", exported)
+	return 0
+}
+
+// runImport reads books from the file given as argument, or from stdin when there's none, and prints an
+// ImportSummary on stdout. Failed records are listed on stderr or, as JSON lines, in -error-report
+func runImport(args []string, books model.BookRepository, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	formatName := flags.String("format", "", "ndjson or csv, by default it's csv when FILE ends with .csv and ndjson otherwise")
+	merge := flags.String("merge", "", "merge strategy for books that are stored already, MERGE_STRATEGY by default")
+	dryRun := flags.Bool("dry-run", false, "tell what would be imported without storing anything")
+	errorReport := flags.String("error-report", "", "file failed records are written to as JSON lines, stderr by default")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() > 1 {
+		fmt.Fprintln(stderr, usage)
+		return 2
+	}
+
+	options := ImportOptions{Policy: model.NewMergePolicyFromEnv(), DryRun: *dryRun}
+
+	var err error
+	if options.Format, err = formatFor(*formatName, flags.Arg(0)); err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return 2
+	}
+
+	if *merge != "" {
+		if options.Policy.Strategy, err = model.ParseMergeStrategy(*merge); err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 2
+		}
+	}
+
+	r := stdin
+	if path := flags.Arg(0); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+		defer file.Close()
+
+		r = file
+	}
+
+	report := func(failure Failure) {
+		if failure.Title == "" {
+			fmt.Fprintf(stderr, "Record %d failed: %s\n", failure.Record, failure.Error)
+			return
+		}
+
+		fmt.Fprintf(stderr, "Record %d (%s) failed: %s\n", failure.Record, failure.Title, failure.Error)
+	}
+
+	if *errorReport != "" {
+		file, err := os.Create(*errorReport)
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+		defer file.Close()
+
+		encoder := json.NewEncoder(file)
+		report = func(failure Failure) {
+			encoder.Encode(failure)
+		}
+	}
+
+	summary, err := Import(books, r, options, report)
+
+	output, _ := json.MarshalIndent(summary, "", "  ")
+	fmt.Fprintln(stdout, string(output))
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Import stopped: %s\n", err.Error())
+		return 1
+	}
+
+	if summary.Failed > 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], utils.NewBookRepository(), os.Stdin, os.Stdout, os.Stderr))
+}
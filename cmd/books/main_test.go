@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunExportsToFileInFormatOfItsExtension(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "books")
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "books.csv")
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+
+	code := run([]string{"export", "-output", output}, model.NewInMemoryBookRepository(sampleBooks...), nil, &stdout, &stderr)
+
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "Exported 2 books\n", stderr.String())
+
+	written, _ := ioutil.ReadFile(output)
+	assert.Equal(t, sampleBooksAsCSV, string(written))
+}
+
+func TestRunImportsFromStdinAndWritesErrorReport(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "books")
+	defer os.RemoveAll(dir)
+
+	errorReport := filepath.Join(dir, "errors.ndjson")
+	stdin := strings.NewReader(sampleBooksAsNDJSON + `{"title":"No description","isbn":"9780000000000","language":"EN"}` + "\n")
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	books := model.NewInMemoryBookRepository()
+
+	code := run([]string{"import", "-error-report", errorReport, "-merge", "overwrite"}, books, stdin, &stdout, &stderr)
+
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "{\n  \"read\": 3,\n  \"created\": 2,\n  \"updated\": 0,\n  \"unchanged\": 0,\n  \"failed\": 1,\n  \"dryRun\": false\n}\n", stdout.String())
+	assert.Equal(t, "", stderr.String())
+
+	report, _ := ioutil.ReadFile(errorReport)
+	assert.Equal(t, `{"record":3,"title":"No description","error":"Description cannot be null nor empty"}`+"\n", string(report))
+
+	imported, _ := books.GetAll()
+	assert.Equal(t, uint(2), imported.NumberBooks)
+}
+
+func TestRunListsFailuresOnStderrWithoutErrorReport(t *testing.T) {
+	stdin := strings.NewReader("not json\n")
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+
+	code := run([]string{"import", "-dry-run"}, model.NewInMemoryBookRepository(), stdin, &stdout, &stderr)
+
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "Record 1 failed: Failed to parse JSON line into a book\n", stderr.String())
+}
+
+func TestRunRejectsWrongArgs(t *testing.T) {
+	books := model.NewInMemoryBookRepository()
+
+	for _, args := range [][]string{
+		{},
+		{"delete"},
+		{"export", "-format", "xml"},
+		{"import", "-merge", "replace"},
+		{"import", "a.csv", "b.csv"},
+	} {
+		stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+		assert.Equal(t, 2, run(args, books, nil, &stdout, &stderr), strings.Join(args, " "))
+		assert.NotEqual(t, "", stderr.String())
+	}
+}
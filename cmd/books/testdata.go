@@ -0,0 +1,48 @@
+package main
+
+import (
+	"time"
+
+	"github.com/felipefill/books/model"
+	null "gopkg.in/guregu/null.v3"
+)
+
+var keepExisting = model.MergePolicy{Strategy: model.KeepExisting}
+
+var seenAt = time.Date(2019, 3, 10, 12, 30, 0, 0, time.UTC)
+
+var sampleBooks = []model.Book{
+	model.Book{
+		ID:          1,
+		Title:       "Kotlin in Action",
+		Description: "Kotlin in Action guides experienced Java developers from the language basics of Kotlin all the way through building applications",
+		ISBN:        null.StringFrom("9781617293290"),
+		Language:    "EN",
+		Provenance: model.Provenance{
+			SourceName:  null.StringFrom("kotlinlang"),
+			SourceURL:   null.StringFrom("https://kotlinlang.org/docs/books.html"),
+			DetailURL:   null.StringFrom("https://www.manning.com/books/kotlin-in-action"),
+			FirstSeenAt: null.TimeFrom(seenAt),
+			LastSeenAt:  null.TimeFrom(seenAt),
+			ContentHash: null.StringFrom("0f1e2d3c"),
+		},
+	},
+	model.Book{
+		ID:          2,
+		Title:       "Kotlin Cookbook, \"Solutions\"",
+		Description: "Kotlin Cookbook, line one\nand line two",
+		ISBN:        null.StringFrom("Unavailable"),
+		Language:    "EN",
+		Provenance:  model.Provenance{SourceName: null.StringFrom(model.SourceAPI)},
+	},
+}
+
+var sampleBooksAsNDJSON = `{"id":1,"isbn":"9781617293290","title":"Kotlin in Action","description":"Kotlin in Action guides experienced Java developers from the language basics of Kotlin all the way through building applications","language":"EN","provenance":{"sourceName":"kotlinlang","sourceUrl":"https://kotlinlang.org/docs/books.html","detailUrl":"https://www.manning.com/books/kotlin-in-action","firstSeenAt":"2019-03-10T12:30:00Z","lastSeenAt":"2019-03-10T12:30:00Z","contentHash":"0f1e2d3c"}}
+{"id":2,"isbn":"Unavailable","title":"Kotlin Cookbook, \"Solutions\"","description":"Kotlin Cookbook, line one\nand line two","language":"EN","provenance":{"sourceName":"api","sourceUrl":null,"detailUrl":null,"firstSeenAt":null,"lastSeenAt":null,"contentHash":null}}
+`
+
+var sampleBooksAsCSV = `id,isbn,title,description,language,source_name,source_url,detail_url,first_seen_at,last_seen_at,content_hash
+1,9781617293290,Kotlin in Action,Kotlin in Action guides experienced Java developers from the language basics of Kotlin all the way through building applications,EN,kotlinlang,https://kotlinlang.org/docs/books.html,https://www.manning.com/books/kotlin-in-action,2019-03-10T12:30:00Z,2019-03-10T12:30:00Z,0f1e2d3c
+2,Unavailable,"Kotlin Cookbook, ""Solutions""","Kotlin Cookbook, line one
+and line two",EN,api,,,,,
+`
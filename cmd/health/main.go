@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/felipefill/books/health"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/tracing"
+	"github.com/felipefill/books/utils"
+)
+
+func main() {
+	if err := tracing.Configure(); err != nil {
+		log.Fatalf("Could not configure tracing: %s", err.Error())
+	}
+
+	lambda.Start(middleware.Standard(health.NewHandler(utils.CheckDBHealth, utils.Version).Handle))
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/felipefill/books/migrations"
+	"github.com/felipefill/books/utils"
+	"github.com/jinzhu/gorm"
+)
+
+// Command tells which migrations to run, Steps is only used by "down" and defaults to 1
+type Command struct {
+	Command string `json:"command"`
+	Steps   int    `json:"steps"`
+}
+
+// Result lists migrations that were applied or rolled back and the status database was left in
+type Result struct {
+	Applied    []migrations.Migration `json:"applied,omitempty"`
+	RolledBack []migrations.Migration `json:"rolledBack,omitempty"`
+	Status     migrations.Status      `json:"status"`
+}
+
+// Handle runs command against database chosen by DB_DRIVER
+func Handle(command Command) (Result, error) {
+	config, err := utils.NewDBConfigFromEnv()
+	if err != nil {
+		return Result{}, err
+	}
+
+	db, err := utils.OpenDB(config)
+	if err != nil {
+		return Result{}, fmt.Errorf("Could not connect to database: %s", err.Error())
+	}
+	defer db.Close()
+
+	return run(db, command)
+}
+
+func run(db *gorm.DB, command Command) (Result, error) {
+	result := Result{}
+	var err error
+
+	switch command.Command {
+	case "up":
+		result.Applied, err = migrations.Up(db)
+	case "down":
+		steps := command.Steps
+		if steps == 0 {
+			steps = 1
+		}
+
+		if steps < 0 {
+			return result, fmt.Errorf("Steps must be positive, got %d", steps)
+		}
+
+		result.RolledBack, err = migrations.Down(db, steps)
+	case "status":
+	default:
+		return result, fmt.Errorf("Unknown command %s, it must be up, down or status", command.Command)
+	}
+
+	if err != nil {
+		return result, err
+	}
+
+	result.Status, err = migrations.CurrentStatus(db)
+	return result, err
+}
+
+// parseArgs reads a Command from command line: up, down [steps] or status
+func parseArgs(args []string) (Command, error) {
+	if len(args) == 0 {
+		return Command{}, fmt.Errorf("Usage: migrate up|down [steps]|status")
+	}
+
+	command := Command{Command: args[0]}
+	if len(args) > 1 {
+		steps, err := strconv.Atoi(args[1])
+		if err != nil {
+			return command, fmt.Errorf("Steps must be a number, got %s", args[1])
+		}
+
+		command.Steps = steps
+	}
+
+	return command, nil
+}
+
+func main() {
+	// Deployed as a Lambda it's invoked with a Command, e.g. sls invoke -f migrate -d '{"command":"up"}'
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		lambda.Start(Handle)
+		return
+	}
+
+	command, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(2)
+	}
+
+	result, err := Handle(command)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+}
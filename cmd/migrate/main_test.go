@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/felipefill/books/migrations"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // SQLite dialect for GORM
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database
+	db.DB().SetMaxOpenConns(1)
+
+	return db
+}
+
+func TestRunUpDownAndStatus(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	result, err := run(db, Command{Command: "status"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, migrations.Status{Current: 0, Latest: migrations.Latest()}, result.Status)
+
+	result, err = run(db, Command{Command: "up"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int(migrations.Latest()), len(result.Applied))
+	assert.True(t, result.Status.UpToDate())
+
+	result, err = run(db, Command{Command: "down"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, len(result.RolledBack))
+	assert.Equal(t, migrations.Latest()-1, result.Status.Current)
+}
+
+func TestRunFailsOnUnknownCommand(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	_, err := run(db, Command{Command: "sideways"})
+	assert.EqualError(t, err, "Unknown command sideways, it must be up, down or status")
+
+	_, err = run(db, Command{Command: "down", Steps: -1})
+	assert.EqualError(t, err, "Steps must be positive, got -1")
+}
+
+func TestParseArgs(t *testing.T) {
+	command, err := parseArgs([]string{"down", "2"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Command{Command: "down", Steps: 2}, command)
+
+	command, err = parseArgs([]string{"up"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Command{Command: "up"}, command)
+
+	_, err = parseArgs([]string{})
+	assert.NotEqual(t, nil, err)
+
+	_, err = parseArgs([]string{"down", "two"})
+	assert.EqualError(t, err, "Steps must be a number, got two")
+}
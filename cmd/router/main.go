@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/felipefill/books/api"
+	"github.com/felipefill/books/utils"
+)
+
+// One function serving every route, see the router layout in serverless.yml
+func main() {
+	serve, err := api.Bootstrap("db")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	lambda.Start(serve(api.NewRouter(api.Routes(utils.NewBookRepository())).Handle))
+}
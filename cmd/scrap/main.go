@@ -0,0 +1,39 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/felipefill/books/api"
+	"github.com/felipefill/books/metrics"
+	"github.com/felipefill/books/scrap"
+	"github.com/felipefill/books/tracing"
+	"github.com/felipefill/books/utils"
+)
+
+func main() {
+	handler := scrap.NewHandler(utils.NewBookRepository())
+
+	// The same binary is deployed as the scrap jobs worker, see serverless.yml. Its metrics are flushed after each
+	// run since no request goes through FlushMetrics
+	if os.Getenv("SCRAP_WORKER") == "true" {
+		if err := tracing.Configure(); err != nil {
+			log.Fatalf("Could not configure tracing: %s", err.Error())
+		}
+
+		lambda.Start(func() error {
+			defer metrics.Flush()
+			return handler.Work()
+		})
+		return
+	}
+
+	serve, err := api.Bootstrap("db")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	validate := api.Validation()
+	lambda.Start(serve(validate(handler.Handle)))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"log"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/felipefill/books/api"
+	"github.com/felipefill/books/search"
+	"github.com/felipefill/books/utils"
+)
+
+func main() {
+	serve, err := api.Bootstrap("db")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	validate := api.Validation()
+	lambda.Start(serve(validate(search.NewHandler(utils.NewBookRepository()).Handle)))
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/felipefill/books/api"
+	"github.com/felipefill/books/metrics"
+	"github.com/felipefill/books/utils"
+)
+
+var defaultPort = "8080"
+var defaultShutdownTimeout = 30 * time.Second
+
+// serve serves requests on listener until ctx is done, then stops accepting new ones and waits up to shutdownTimeout
+// for those in flight to finish
+func serve(ctx context.Context, server *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+
+	if err := <-served; err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// newMux serves metrics at /metrics in Prometheus text format and everything else with handler
+func newMux(handler http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Default.Handler())
+	mux.Handle("/", handler)
+
+	return mux
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		log.Printf("Got %s, shutting down", <-signals)
+		cancel()
+	}()
+
+	serveAPI, err := api.Bootstrap("memory")
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	addr := ":" + getEnvOrDefault("PORT", defaultPort)
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("Could not listen on %s: %s", addr, err.Error())
+	}
+
+	router := api.NewRouter(api.Routes(utils.NewBookRepository()))
+	server := &http.Server{
+		Handler:           newMux(api.NewHTTPHandler(serveAPI(router.Handle))),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Serving on %s", addr)
+	if err := serve(ctx, server, listener, getDurationEnvOrDefault("SHUTDOWN_TIMEOUT", defaultShutdownTimeout)); err != nil {
+		log.Fatalf("Server failed: %s", err.Error())
+	}
+
+	log.Printf("Server stopped")
+}
+
+func getEnvOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+func getDurationEnvOrDefault(name string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeFinishesRequestsInFlightBeforeStopping(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	started := make(chan bool)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("done"))
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- serve(ctx, server, listener, time.Second)
+	}()
+
+	answered := make(chan string)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			answered <- err.Error()
+			return
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		answered <- string(body)
+	}()
+
+	<-started
+	cancel()
+
+	assert.Equal(t, "done", <-answered)
+	assert.Equal(t, nil, <-stopped)
+
+	_, err := http.Get("http://" + listener.Addr().String())
+	assert.NotEqual(t, nil, err)
+}
+
+func TestServeGivesUpOnRequestsThatTakeTooLong(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	started := make(chan bool)
+	release := make(chan bool)
+	defer close(release)
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error)
+	go func() {
+		stopped <- serve(ctx, server, listener, 50*time.Millisecond)
+	}()
+
+	go http.Get("http://" + listener.Addr().String())
+
+	<-started
+	cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, <-stopped)
+}
+
+func TestNewMuxServesMetricsBesideHandler(t *testing.T) {
+	mux := newMux(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("api"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, recorder.Body.String(), "# TYPE books_http_requests_total counter")
+
+	recorder = httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/books", nil))
+	assert.Equal(t, "api", recorder.Body.String())
+}
@@ -1,11 +1,10 @@
-package main
+package create
 
 import (
 	"encoding/json"
 	"errors"
 
 	"github.com/felipefill/books/model"
-	"github.com/felipefill/books/utils"
 
 	null "gopkg.in/guregu/null.v3"
 )
@@ -29,18 +28,20 @@ func NewCreateBookRequestFromJSONString(jsonString string) (*CreateBookRequest,
 	return request, nil
 }
 
-// StoreInDatabase stores request content in database as a new book
-func (request *CreateBookRequest) StoreInDatabase() (*model.Book, error) {
+// StoreInDatabase stores request content in given repository as a new book or, when there's one with the same title,
+// merges it into that one according to policy
+func (request *CreateBookRequest) StoreInDatabase(books model.BookRepository, policy model.MergePolicy) (*model.Book, *model.MergeResult, error) {
 	book, err := request.ToBook()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if err = book.StoreOrRetrieveByTitle(utils.GetDB()); err != nil {
-		return nil, err
+	result, err := books.StoreOrMerge(book, policy)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return book, nil
+	return book, &result, nil
 }
 
 // ToBook converts CreateBookRequest into a Book, runs validation before doing so
@@ -49,50 +50,22 @@ func (request *CreateBookRequest) ToBook() (*model.Book, error) {
 		return nil, err
 	}
 
-	book := model.Book{
+	book := request.book()
+	return &book, nil
+}
+
+func (request *CreateBookRequest) book() model.Book {
+	return model.Book{
 		Title:       request.Title.String,
 		Description: request.Description.String,
 		ISBN:        request.ISBN,
 		Language:    request.Language.String,
+		Provenance:  model.Provenance{SourceName: null.StringFrom(model.SourceAPI)},
 	}
-
-	return &book, nil
 }
 
+// validate checks request the same way every book is checked, see model.Book.Validate
 func (request *CreateBookRequest) validate() error {
-	errorString := ""
-
-	if !request.Title.Valid || request.Title.String == "" {
-		errorString = "Title cannot be null nor empty"
-	}
-
-	if !request.Description.Valid || request.Description.String == "" {
-		if errorString != "" {
-			errorString += "; "
-		}
-
-		errorString += "Description cannot be null nor empty"
-	}
-
-	if !request.ISBN.Valid || request.ISBN.String == "" {
-		if errorString != "" {
-			errorString += "; "
-		}
-
-		errorString += "ISBN cannot be null nor empty"
-	}
-
-	if !request.Language.Valid || request.Language.String == "" {
-		if errorString != "" {
-			errorString += "; "
-		}
-
-		errorString += "Language cannot be null nor empty"
-	}
-
-	if errorString != "" {
-		return errors.New(errorString)
-	}
-
-	return nil
+	book := request.book()
+	return book.Validate()
 }
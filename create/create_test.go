@@ -1,4 +1,4 @@
-package main
+package create
 
 import (
 	"errors"
@@ -7,10 +7,9 @@ import (
 	"github.com/felipefill/books/model"
 	"github.com/jinzhu/gorm"
 
-	"github.com/felipefill/books/utils"
-
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func TestCreateBookRequestStoreInDatabaseFailsInvalidRequest(t *testing.T) {
@@ -19,7 +18,7 @@ func TestCreateBookRequestStoreInDatabaseFailsInvalidRequest(t *testing.T) {
 	var expectedBook *model.Book
 	expectedError := errors.New("Title cannot be null nor empty; Description cannot be null nor empty")
 
-	actualBook, actualError := request.StoreInDatabase()
+	actualBook, _, actualError := request.StoreInDatabase(model.NewInMemoryBookRepository(), keepExisting)
 
 	assert.Equal(t, expectedError, actualError)
 	assert.Equal(t, expectedBook, actualBook)
@@ -32,19 +31,27 @@ func TestCreateBookRequestStoreInDatabaseFailsDatabaseError(t *testing.T) {
 	expectedError := errors.New("some database error")
 
 	db, mock, _ := sqlmock.New()
-	utils.InjectDB(db)
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
 
 	mock.
 		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(sampleBook.Title).
+		WithArgs(sampleBook.ISBN.String).
 		WillReturnError(gorm.ErrRecordNotFound)
 
 	mock.
-		ExpectQuery("INSERT INTO \"books\" \\(\"isbn\",\"title\",\"description\",\"language\"\\)").
-		WithArgs(sampleBook.ISBN.String, sampleBook.Title, sampleBook.Description, sampleBook.Language).
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs("book title example").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.
+		ExpectQuery("INSERT INTO \"books\" \\(\"isbn\",\"title\",\"description\",\"language\",\"source_name\",(.+)\\)").
+		WithArgs(sampleBook.ISBN.String, sampleBook.Title, sampleBook.Description, sampleBook.Language, model.SourceAPI, nil, nil, nil, nil, nil,
+			sampleBook.ISBN.String, "book title example").
 		WillReturnError(errors.New("some database error"))
 
-	actualBook, actualError := request.StoreInDatabase()
+	actualBook, _, actualError := request.StoreInDatabase(model.NewGormBookRepository(gormDB), keepExisting)
 
 	assert.Equal(t, expectedError, actualError)
 	assert.Equal(t, expectedBook, actualBook)
@@ -58,23 +65,15 @@ func TestCreateBookRequestStoreInDatabaseSucceeds(t *testing.T) {
 
 	var expectedError error
 
-	db, mock, _ := sqlmock.New()
-	utils.InjectDB(db)
-
-	mock.
-		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(sampleBook.Title).
-		WillReturnError(gorm.ErrRecordNotFound)
+	books := model.NewInMemoryBookRepository()
 
-	mock.
-		ExpectQuery("INSERT INTO \"books\" \\(\"isbn\",\"title\",\"description\",\"language\"\\)").
-		WithArgs(sampleBook.ISBN.String, sampleBook.Title, sampleBook.Description, sampleBook.Language).
-		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
-
-	actualBook, actualError := request.StoreInDatabase()
+	actualBook, actualResult, actualError := request.StoreInDatabase(books, keepExisting)
+	storedBook, _ := books.FindByID(1)
 
 	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, &model.MergeResult{Created: true, Changes: []model.FieldChange{}}, actualResult)
 	assert.Equal(t, &expectedBook, actualBook)
+	assert.Equal(t, &expectedBook, storedBook)
 }
 
 func TestCreateBookRequestStoreInDatabaseSucceedsAlreadyInDatabase(t *testing.T) {
@@ -83,23 +82,37 @@ func TestCreateBookRequestStoreInDatabaseSucceedsAlreadyInDatabase(t *testing.T)
 	expectedBook := sampleBook
 	expectedBook.ID = 1
 
-	var expectedError error
+	expectedBook.Description = "Description stored earlier"
 
-	db, mock, _ := sqlmock.New()
-	utils.InjectDB(db)
+	var expectedError error
 
-	mock.
-		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(sampleBook.Title).
-		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "title", "description", "isbn", "language"}).
-				AddRow(expectedBook.ID, expectedBook.Title, expectedBook.Description, expectedBook.ISBN.String, expectedBook.Language),
-		)
+	books := model.NewInMemoryBookRepository(expectedBook)
 
-	actualBook, actualError := request.StoreInDatabase()
+	actualBook, actualResult, actualError := request.StoreInDatabase(books, keepExisting)
 
 	assert.Equal(t, expectedError, actualError)
 	assert.Equal(t, &expectedBook, actualBook)
+	assert.Equal(t, &model.MergeResult{Changes: []model.FieldChange{}}, actualResult)
+}
+
+func TestCreateBookRequestStoreInDatabaseMergesIntoStoredBook(t *testing.T) {
+	request := validCreateBookRequest
+
+	storedBook := sampleBook
+	storedBook.ID = 1
+	storedBook.Description = "Description stored earlier"
+	storedBook.ISBN = null.StringFrom(model.UnavailableISBN)
+
+	expectedBook := storedBook
+	expectedBook.ISBN = sampleBook.ISBN
+
+	books := model.NewInMemoryBookRepository(storedBook)
+
+	actualBook, actualResult, actualError := request.StoreInDatabase(books, model.MergePolicy{Strategy: model.FillEmpty})
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, &expectedBook, actualBook)
+	assert.Equal(t, []model.FieldChange{{Field: "isbn", Old: model.UnavailableISBN, New: sampleBook.ISBN.String}}, actualResult.Changes)
 }
 
 func TestCreateBookRequestToBook(t *testing.T) {
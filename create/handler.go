@@ -0,0 +1,66 @@
+package create
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+)
+
+// Handler creates books in its repository
+type Handler struct {
+	books model.BookRepository
+}
+
+// NewHandler creates a Handler that stores books in given repository
+func NewHandler(books model.BookRepository) *Handler {
+	return &Handler{books: books}
+}
+
+// Handle is our lambda handler, it's invoked by `lambda.Start` (see cmd/create) or by the HTTP server (see cmd/server)
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if request.Body == "" {
+		return apierror.New(400, apierror.InvalidRequest, "Body cannot be empty").Response(request), nil
+	}
+
+	createBookRequest, err := NewCreateBookRequestFromJSONString(request.Body)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidRequest, err.Error()).Response(request), nil
+	}
+
+	if err := createBookRequest.validate(); err != nil {
+		return apierror.New(400, apierror.ValidationFailed, err.Error()).Response(request), nil
+	}
+
+	policy, err := model.MergePolicyFromQuery(request.QueryStringParameters)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidParameter, err.Error()).Response(request), nil
+	}
+
+	book, result, err := createBookRequest.StoreInDatabase(utils.TraceBookRepository(h.books, middleware.GetSpan(ctx)), policy)
+	if utils.IsUnavailable(err) {
+		return utils.UnavailableResponse(request, err), nil
+	}
+
+	if err != nil {
+		return apierror.New(500, apierror.InternalError, "Failed to store book").Response(request), nil
+	}
+
+	if !result.Created {
+		json, _ := json.Marshal(mergedBookResponse{BookID: book.ID, Changes: result.Changes})
+		return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: fmt.Sprintf(`{"book_id": %d}`, book.ID), StatusCode: 201}, nil
+}
+
+// mergedBookResponse is what's answered when there was a book with the same title already
+type mergedBookResponse struct {
+	BookID  uint                `json:"book_id"`
+	Changes []model.FieldChange `json:"changes"`
+}
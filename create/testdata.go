@@ -1,4 +1,4 @@
-package main
+package create
 
 import (
 	"github.com/felipefill/books/model"
@@ -26,9 +26,12 @@ var invalidCreateBookRequest = CreateBookRequest{
 	Language:    null.StringFrom("BR"),
 }
 
+var keepExisting = model.MergePolicy{Strategy: model.KeepExisting}
+
 var sampleBook = model.Book{
 	Title:       "Book title example",
 	Description: "Book description example",
 	ISBN:        null.StringFrom("9781617293290"),
 	Language:    "BR",
+	Provenance:  model.Provenance{SourceName: null.StringFrom(model.SourceAPI)},
 }
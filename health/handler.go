@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/utils"
+)
+
+// Handler reports whether functions are able to serve requests, uptime checks and load balancers poll it
+type Handler struct {
+	checkDB func() utils.DBHealth
+	version string
+}
+
+// NewHandler creates a Handler that reports given version and checks database with checkDB
+func NewHandler(checkDB func() utils.DBHealth, version string) *Handler {
+	return &Handler{checkDB: checkDB, version: version}
+}
+
+// healthResponse is what's answered, Status is "ok" or "unavailable"
+type healthResponse struct {
+	Status   string         `json:"status"`
+	Version  string         `json:"version"`
+	Database utils.DBHealth `json:"database"`
+}
+
+// Handle answers 200 when database is reachable and its schema is up to date and 503 otherwise
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	response := healthResponse{Status: "ok", Version: h.version, Database: h.checkDB()}
+
+	statusCode := 200
+	if !response.Database.Healthy() {
+		response.Status = "unavailable"
+		statusCode = 503
+	}
+
+	body, _ := json.Marshal(response)
+
+	// Health must be checked every time, a cached answer would hide outages
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: statusCode, Headers: map[string]string{"Cache-Control": "no-store"}}, nil
+}
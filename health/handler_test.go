@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/migrations"
+	"github.com/felipefill/books/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var noCache = map[string]string{"Cache-Control": "no-store"}
+
+func TestHealthHandlerReportsHealthyDatabase(t *testing.T) {
+	checkDB := func() utils.DBHealth {
+		return utils.DBHealth{Driver: "postgres", Reachable: true, LatencyMs: 1.5, Migrations: &migrations.Status{Current: 3, Latest: 3}}
+	}
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"status":"ok","version":"1.2.0","database":{"driver":"postgres","reachable":true,"latencyMs":1.5,"migrations":{"current":3,"latest":3}}}`,
+		StatusCode: 200,
+		Headers:    noCache,
+	}
+
+	actualResponse, actualError := NewHandler(checkDB, "1.2.0").Handle(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHealthHandlerReportsPendingMigrations(t *testing.T) {
+	checkDB := func() utils.DBHealth {
+		return utils.DBHealth{
+			Driver:     "postgres",
+			Reachable:  true,
+			LatencyMs:  2,
+			Migrations: &migrations.Status{Current: 2, Latest: 3},
+			Error:      "Database schema is at version 2 but 3 is required, migrations must be applied first",
+		}
+	}
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"status":"unavailable","version":"dev","database":{"driver":"postgres","reachable":true,"latencyMs":2,"migrations":{"current":2,"latest":3},"error":"Database schema is at version 2 but 3 is required, migrations must be applied first"}}`,
+		StatusCode: 503,
+		Headers:    noCache,
+	}
+
+	actualResponse, _ := NewHandler(checkDB, "dev").Handle(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHealthHandlerReportsUnreachableDatabase(t *testing.T) {
+	os.Unsetenv("DB_DRIVER")
+	os.Unsetenv("DATABASE_URL")
+	os.Unsetenv("DB_HOST")
+	os.Unsetenv("DB_NAME")
+	os.Unsetenv("DB_USER")
+	os.Unsetenv("DB_PSWD")
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"status":"unavailable","version":"dev","database":{"driver":"postgres","reachable":false,"latencyMs":0,"error":"Database is unavailable: DATABASE_URL or DB_HOST, DB_NAME, DB_USER and DB_PSWD must be set, missing DB_HOST, DB_NAME, DB_USER, DB_PSWD"}}`,
+		StatusCode: 503,
+		Headers:    noCache,
+	}
+
+	actualResponse, _ := NewHandler(utils.CheckDBHealth, "dev").Handle(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHealthHandlerNeedsNoDatabaseForBooksInMemory(t *testing.T) {
+	os.Setenv("DB_DRIVER", "memory")
+	defer os.Unsetenv("DB_DRIVER")
+
+	actualResponse, _ := NewHandler(utils.CheckDBHealth, "dev").Handle(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, 200, actualResponse.StatusCode)
+	assert.Equal(t, `{"status":"ok","version":"dev","database":{"driver":"memory","reachable":true,"latencyMs":0}}`, actualResponse.Body)
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// maxEMFValues is how many values CloudWatch accepts for a metric in a single EMF line
+const maxEMFValues = 100
+
+var defaultNamespace = "Books"
+
+// WriteEMF writes what was recorded since the last time it was called as CloudWatch Embedded Metric Format lines,
+// one per series with label values as dimensions. Lambda sends them to CloudWatch Logs, which extracts the metrics:
+//
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html
+//
+// Counters are written as their increase, histograms as the values they observed
+func (r *Registry) WriteEMF(w io.Writer, namespace string, now time.Time) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, m := range r.metrics {
+		for _, s := range m.sortedSeries() {
+			if m.kind == "counter" {
+				if s.pending == 0 {
+					continue
+				}
+
+				if err := writeEMFLine(w, namespace, now, m, s, s.pending); err != nil {
+					return err
+				}
+
+				s.pending = 0
+				continue
+			}
+
+			for start := 0; start < len(s.pendingValues); start += maxEMFValues {
+				end := start + maxEMFValues
+				if end > len(s.pendingValues) {
+					end = len(s.pendingValues)
+				}
+
+				if err := writeEMFLine(w, namespace, now, m, s, s.pendingValues[start:end]); err != nil {
+					return err
+				}
+			}
+
+			s.pendingValues = nil
+		}
+	}
+
+	return nil
+}
+
+func writeEMFLine(w io.Writer, namespace string, now time.Time, m *metric, s *series, value interface{}) error {
+	dimensions := [][]string{}
+	if len(m.labelNames) > 0 {
+		dimensions = append(dimensions, m.labelNames)
+	}
+
+	line := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": now.UnixNano() / int64(time.Millisecond),
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  namespace,
+				"Dimensions": dimensions,
+				"Metrics":    []map[string]string{{"Name": m.name, "Unit": m.unit}},
+			}},
+		},
+		m.name: value,
+	}
+
+	for index, name := range m.labelNames {
+		line[name] = s.labelValues[index]
+	}
+
+	encoded, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(encoded, '\n'))
+	return err
+}
+
+// InLambda tells whether this process runs on Lambda, where metrics are written as EMF rather than scrapped
+func InLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
+
+// Namespace is the CloudWatch namespace metrics are written to, METRICS_NAMESPACE or Books
+func Namespace() string {
+	if namespace := os.Getenv("METRICS_NAMESPACE"); namespace != "" {
+		return namespace
+	}
+
+	return defaultNamespace
+}
+
+// Flush writes what Default recorded since it last did to stdout as EMF, on Lambda only. Functions call it once
+// they've handled each event, see middleware.Standard
+func Flush() {
+	if InLambda() {
+		Default.WriteEMF(os.Stdout, Namespace(), time.Now())
+	}
+}
@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeEMF(t *testing.T, output string) []map[string]interface{} {
+	lines := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line == "" {
+			continue
+		}
+
+		decoded := map[string]interface{}{}
+		assert.Equal(t, nil, json.Unmarshal([]byte(line), &decoded))
+		lines = append(lines, decoded)
+	}
+
+	return lines
+}
+
+func TestWriteEMFWritesCounterIncreasesSinceLastWrite(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("requests_total", "Requests answered", "status")
+	now := time.Unix(1500000000, 0)
+
+	requests.Add(2, "200")
+
+	var output bytes.Buffer
+	assert.Equal(t, nil, registry.WriteEMF(&output, "Books", now))
+
+	lines := decodeEMF(t, output.String())
+	assert.Equal(t, 1, len(lines))
+	assert.Equal(t, 2.0, lines[0]["requests_total"])
+	assert.Equal(t, "200", lines[0]["status"])
+
+	aws := lines[0]["_aws"].(map[string]interface{})
+	assert.Equal(t, 1500000000000.0, aws["Timestamp"])
+
+	directive := aws["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Books", directive["Namespace"])
+	assert.Equal(t, []interface{}{[]interface{}{"status"}}, directive["Dimensions"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"Name": "requests_total", "Unit": "Count"}}, directive["Metrics"])
+
+	output.Reset()
+	assert.Equal(t, nil, registry.WriteEMF(&output, "Books", now))
+	assert.Equal(t, "", output.String())
+
+	requests.Inc("200")
+	assert.Equal(t, nil, registry.WriteEMF(&output, "Books", now))
+	assert.Equal(t, 1.0, decodeEMF(t, output.String())[0]["requests_total"])
+	assert.Equal(t, 3.0, requests.Value("200"))
+}
+
+func TestWriteEMFWritesHistogramValuesInChunks(t *testing.T) {
+	registry := NewRegistry()
+	durations := registry.NewHistogram("duration_seconds", "Durations", Seconds, DurationBuckets)
+	for index := 0; index < maxEMFValues+1; index++ {
+		durations.Observe(0.5)
+	}
+
+	var output bytes.Buffer
+	assert.Equal(t, nil, registry.WriteEMF(&output, "Books", time.Now()))
+
+	lines := decodeEMF(t, output.String())
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, maxEMFValues, len(lines[0]["duration_seconds"].([]interface{})))
+	assert.Equal(t, 1, len(lines[1]["duration_seconds"].([]interface{})))
+
+	directive := lines[0]["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{}, directive["Dimensions"])
+
+	output.Reset()
+	assert.Equal(t, nil, registry.WriteEMF(&output, "Books", time.Now()))
+	assert.Equal(t, "", output.String())
+}
+
+func TestNamespaceDefaultsToBooks(t *testing.T) {
+	os.Unsetenv("METRICS_NAMESPACE")
+	assert.Equal(t, "Books", Namespace())
+
+	os.Setenv("METRICS_NAMESPACE", "BooksStaging")
+	defer os.Unsetenv("METRICS_NAMESPACE")
+	assert.Equal(t, "BooksStaging", Namespace())
+}
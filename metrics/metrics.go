@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Units tell CloudWatch what values are, see WriteEMF
+const (
+	Count   = "Count"
+	Seconds = "Seconds"
+)
+
+// DurationBuckets are the default histogram buckets for durations in seconds, from 5ms to 30s
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// maxPendingValues bounds the values a histogram series keeps for the next WriteEMF, so that processes which never
+// write EMF, like the HTTP server, don't keep every observation
+var maxPendingValues = 1000
+
+// Default is where metrics created by NewCounter and NewHistogram are registered
+var Default = NewRegistry()
+
+// Registry keeps metrics and the values of their series, it's safe for concurrent use
+type Registry struct {
+	lock    sync.Mutex
+	metrics []*metric
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// metric is a counter or a histogram, its series are told apart by label values
+type metric struct {
+	name       string
+	help       string
+	kind       string
+	unit       string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*series
+}
+
+// series holds a metric's values for some label values. Pending values were recorded since the last WriteEMF: a
+// counter's increase, a histogram's observations
+type series struct {
+	labelValues []string
+
+	value        float64
+	bucketCounts []uint64
+	count        uint64
+
+	pending       float64
+	pendingValues []float64
+}
+
+// Counter is a value that only goes up, e.g. requests answered
+type Counter struct {
+	registry *Registry
+	metric   *metric
+}
+
+// Histogram counts observations, e.g. durations, in buckets
+type Histogram struct {
+	registry *Registry
+	metric   *metric
+}
+
+// NewCounter creates a counter in Default, see Registry.NewCounter
+func NewCounter(name string, help string, labelNames ...string) *Counter {
+	return Default.NewCounter(name, help, labelNames...)
+}
+
+// NewHistogram creates a histogram in Default, see Registry.NewHistogram
+func NewHistogram(name string, help string, unit string, buckets []float64, labelNames ...string) *Histogram {
+	return Default.NewHistogram(name, help, unit, buckets, labelNames...)
+}
+
+// NewCounter creates a counter named name, its series are told apart by labelNames
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	return &Counter{registry: r, metric: r.register(&metric{name: name, help: help, kind: "counter", unit: Count, labelNames: labelNames})}
+}
+
+// NewHistogram creates a histogram named name, counting values in unit in buckets given by their upper bounds
+func (r *Registry) NewHistogram(name string, help string, unit string, buckets []float64, labelNames ...string) *Histogram {
+	return &Histogram{registry: r, metric: r.register(&metric{name: name, help: help, kind: "histogram", unit: unit, labelNames: labelNames, buckets: buckets})}
+}
+
+func (r *Registry) register(m *metric) *metric {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, registered := range r.metrics {
+		if registered.name == m.name {
+			panic(fmt.Sprintf("Metric %s is registered already", m.name))
+		}
+	}
+
+	m.series = make(map[string]*series)
+	r.metrics = append(r.metrics, m)
+
+	return m
+}
+
+// seriesFor returns m's series for labelValues, creating it when needed. Registry's lock must be held
+func (m *metric) seriesFor(labelValues []string) *series {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Sprintf("Metric %s has labels %v, got values %v", m.name, m.labelNames, labelValues))
+	}
+
+	key := seriesKey(labelValues)
+	s, ok := m.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...), bucketCounts: make([]uint64, len(m.buckets))}
+		m.series[key] = s
+	}
+
+	return s
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\x00")
+}
+
+// Add increases c's series for labelValues by value
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.registry.lock.Lock()
+	defer c.registry.lock.Unlock()
+
+	s := c.metric.seriesFor(labelValues)
+	s.value += value
+	s.pending += value
+}
+
+// Inc increases c's series for labelValues by one
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns c's series for labelValues, 0 when nothing was recorded in it
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.registry.lock.Lock()
+	defer c.registry.lock.Unlock()
+
+	if s, ok := c.metric.series[seriesKey(labelValues)]; ok {
+		return s.value
+	}
+
+	return 0
+}
+
+// Observe records value in h's series for labelValues
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.registry.lock.Lock()
+	defer h.registry.lock.Unlock()
+
+	s := h.metric.seriesFor(labelValues)
+	for index, bound := range h.metric.buckets {
+		if value <= bound {
+			s.bucketCounts[index]++
+		}
+	}
+
+	s.count++
+	s.value += value
+
+	if len(s.pendingValues) < maxPendingValues {
+		s.pendingValues = append(s.pendingValues, value)
+	}
+}
+
+// ObserveSince records the seconds elapsed since start, for histograms of durations
+func (h *Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns how many values h's series for labelValues observed
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.registry.lock.Lock()
+	defer h.registry.lock.Unlock()
+
+	if s, ok := h.metric.series[seriesKey(labelValues)]; ok {
+		return s.count
+	}
+
+	return 0
+}
+
+// sortedSeries lists m's series by label values, so that they're always written in the same order. Registry's lock
+// must be held
+func (m *metric) sortedSeries() []*series {
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*series, 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, m.series[key])
+	}
+
+	return sorted
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterAddsUpPerLabelValues(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("requests_total", "Requests", "status")
+
+	requests.Inc("200")
+	requests.Inc("200")
+	requests.Add(3, "404")
+
+	assert.Equal(t, 2.0, requests.Value("200"))
+	assert.Equal(t, 3.0, requests.Value("404"))
+	assert.Equal(t, 0.0, requests.Value("500"))
+}
+
+func TestHistogramCountsObservationsInBuckets(t *testing.T) {
+	registry := NewRegistry()
+	durations := registry.NewHistogram("duration_seconds", "Durations", Seconds, []float64{0.1, 1})
+
+	durations.Observe(0.05)
+	durations.Observe(0.5)
+	durations.ObserveSince(time.Now().Add(-2 * time.Second))
+
+	assert.Equal(t, uint64(3), durations.Count())
+
+	series := durations.metric.series[seriesKey(nil)]
+	assert.Equal(t, []uint64{1, 2}, series.bucketCounts)
+	assert.True(t, series.value > 2.55)
+}
+
+func TestHistogramBoundsPendingValues(t *testing.T) {
+	defer func(previous int) { maxPendingValues = previous }(maxPendingValues)
+	maxPendingValues = 2
+
+	registry := NewRegistry()
+	durations := registry.NewHistogram("duration_seconds", "Durations", Seconds, DurationBuckets)
+	for index := 0; index < 5; index++ {
+		durations.Observe(1)
+	}
+
+	assert.Equal(t, uint64(5), durations.Count())
+	assert.Equal(t, 2, len(durations.metric.series[seriesKey(nil)].pendingValues))
+}
+
+func TestRegistryPanicsOnDuplicateNamesAndWrongLabels(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("requests_total", "Requests", "status")
+
+	assert.Panics(t, func() { registry.NewHistogram("requests_total", "Requests", Seconds, DurationBuckets) })
+	assert.Panics(t, func() { requests.Inc() })
+	assert.Panics(t, func() { requests.Inc("200", "GET") })
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WritePrometheus writes every metric in Prometheus' text format:
+//
+// https://prometheus.io/docs/instrumenting/exposition_formats/#text-based-format
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		fmt.Fprintf(buffered, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(buffered, "# TYPE %s %s\n", m.name, m.kind)
+
+		for _, s := range m.sortedSeries() {
+			if m.kind == "counter" {
+				fmt.Fprintf(buffered, "%s%s %s\n", m.name, labels(m.labelNames, s.labelValues), formatFloat(s.value))
+				continue
+			}
+
+			bucketNames := with(m.labelNames, "le")
+			for index, bound := range m.buckets {
+				fmt.Fprintf(buffered, "%s_bucket%s %d\n", m.name, labels(bucketNames, with(s.labelValues, formatFloat(bound))), s.bucketCounts[index])
+			}
+
+			fmt.Fprintf(buffered, "%s_bucket%s %d\n", m.name, labels(bucketNames, with(s.labelValues, "+Inf")), s.count)
+			fmt.Fprintf(buffered, "%s_sum%s %s\n", m.name, labels(m.labelNames, s.labelValues), formatFloat(s.value))
+			fmt.Fprintf(buffered, "%s_count%s %d\n", m.name, labels(m.labelNames, s.labelValues), s.count)
+		}
+	}
+
+	return buffered.Flush()
+}
+
+// Handler serves r's metrics to Prometheus, see cmd/server
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WritePrometheus(w)
+	})
+}
+
+func labels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for index, name := range names {
+		pairs[index] = name + `="` + labelValueEscaper.Replace(values[index]) + `"`
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// with returns a copy of values with value appended, values are never appended to in place since they're shared
+func with(values []string, value string) []string {
+	return append(append(make([]string, 0, len(values)+1), values...), value)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheusWritesTextFormat(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounter("requests_total", "Requests answered", "route", "status")
+	durations := registry.NewHistogram("duration_seconds", "How long\nrequests took", Seconds, []float64{0.1, 1}, "route")
+	registry.NewCounter("unused_total", "Never increased")
+
+	requests.Inc("/books", "200")
+	requests.Inc(`/book/"{id}"`, "404")
+	durations.Observe(0.05, "/books")
+	durations.Observe(0.5, "/books")
+
+	var output bytes.Buffer
+	assert.Equal(t, nil, registry.WritePrometheus(&output))
+
+	expected := `# HELP requests_total Requests answered
+# TYPE requests_total counter
+requests_total{route="/book/\"{id}\"",status="404"} 1
+requests_total{route="/books",status="200"} 1
+# HELP duration_seconds How long\nrequests took
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/books",le="0.1"} 1
+duration_seconds_bucket{route="/books",le="1"} 2
+duration_seconds_bucket{route="/books",le="+Inf"} 2
+duration_seconds_sum{route="/books"} 0.55
+duration_seconds_count{route="/books"} 2
+# HELP unused_total Never increased
+# TYPE unused_total counter
+`
+	assert.Equal(t, expected, output.String())
+}
+
+func TestHandlerServesPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("requests_total", "Requests answered").Inc()
+
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, 200, recorder.Code)
+	assert.Equal(t, "text/plain; version=0.0.4", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "requests_total 1\n")
+}
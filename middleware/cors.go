@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+)
+
+var defaultCORSAllowedMethods = []string{"GET", "POST"}
+var defaultCORSAllowedHeaders = []string{"Content-Type", "Authorization", "X-Api-Key", RequestIDHeader}
+var defaultCORSExposedHeaders = []string{RequestIDHeader, "Location", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"}
+var defaultCORSMaxAge = 10 * time.Minute
+
+// CORSConfig tells which browser origins may call the API and how, see
+// https://developer.mozilla.org/en-US/docs/Web/HTTP/CORS
+type CORSConfig struct {
+	// AllowedOrigins may call the API, e.g. https://catalog.example.com. "*" allows any origin, none disables CORS
+	AllowedOrigins []string
+
+	// AllowedMethods and AllowedHeaders are what preflight requests are told cross-origin requests may use
+	AllowedMethods []string
+	AllowedHeaders []string
+
+	// ExposedHeaders are response headers browsers let scripts read
+	ExposedHeaders []string
+
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// NewCORSConfigFromEnv creates a CORSConfig from CORS_* environment variables, lists are comma separated. CORS is
+// disabled unless CORS_ALLOWED_ORIGINS is set
+func NewCORSConfigFromEnv() CORSConfig {
+	maxAge, err := time.ParseDuration(os.Getenv("CORS_MAX_AGE"))
+	if err != nil || maxAge < 0 {
+		maxAge = defaultCORSMaxAge
+	}
+
+	return CORSConfig{
+		AllowedOrigins: getListEnvOrDefault("CORS_ALLOWED_ORIGINS", nil),
+		AllowedMethods: getListEnvOrDefault("CORS_ALLOWED_METHODS", defaultCORSAllowedMethods),
+		AllowedHeaders: getListEnvOrDefault("CORS_ALLOWED_HEADERS", defaultCORSAllowedHeaders),
+		ExposedHeaders: getListEnvOrDefault("CORS_EXPOSED_HEADERS", defaultCORSExposedHeaders),
+		MaxAge:         maxAge,
+	}
+}
+
+// allowOrigin tells whether origin is allowed and what Access-Control-Allow-Origin answers it with
+func (c CORSConfig) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*", true
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// CORS lets browsers on config's origins call handlers. Preflight requests are answered right away, without reaching
+// handlers: 204 when their origin is allowed, 403 otherwise. Other requests reach handlers either way, but only
+// responses to allowed origins carry CORS headers, so browsers don't let scripts on other origins read them
+func CORS(config CORSConfig) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			origin := GetHeader(request, "Origin")
+			if len(config.AllowedOrigins) == 0 || origin == "" {
+				return next(ctx, request)
+			}
+
+			allowedOrigin, allowed := config.allowOrigin(origin)
+
+			if isPreflight(request) {
+				if !allowed {
+					return apierror.New(403, apierror.OriginNotAllowed, fmt.Sprintf("Origin %s is not allowed", origin)).Response(request), nil
+				}
+
+				headers := corsHeaders(nil, allowedOrigin)
+				headers["Access-Control-Allow-Methods"] = strings.Join(config.AllowedMethods, ", ")
+				headers["Access-Control-Allow-Headers"] = strings.Join(config.AllowedHeaders, ", ")
+				headers["Access-Control-Max-Age"] = strconv.Itoa(int(config.MaxAge / time.Second))
+
+				return events.APIGatewayProxyResponse{StatusCode: 204, Headers: headers}, nil
+			}
+
+			response, err := next(ctx, request)
+			if err != nil || !allowed {
+				return response, err
+			}
+
+			response.Headers = corsHeaders(response.Headers, allowedOrigin)
+			if len(config.ExposedHeaders) > 0 {
+				response.Headers["Access-Control-Expose-Headers"] = strings.Join(config.ExposedHeaders, ", ")
+			}
+
+			return response, nil
+		}
+	}
+}
+
+// isPreflight tells whether request is a browser asking whether it may send a cross-origin request
+func isPreflight(request events.APIGatewayProxyRequest) bool {
+	return request.HTTPMethod == "OPTIONS" && GetHeader(request, "Access-Control-Request-Method") != ""
+}
+
+// corsHeaders returns a copy of headers allowing origin. Responses vary with Origin unless any origin is allowed
+func corsHeaders(headers map[string]string, origin string) map[string]string {
+	headers = copyHeaders(headers)
+	headers["Access-Control-Allow-Origin"] = origin
+
+	if origin != "*" {
+		if vary := headers["Vary"]; vary != "" {
+			headers["Vary"] = vary + ", Origin"
+		} else {
+			headers["Vary"] = "Origin"
+		}
+	}
+
+	return headers
+}
+
+func getListEnvOrDefault(name string, defaultValue []string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	return values
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+var catalogCORSConfig = CORSConfig{
+	AllowedOrigins: []string{"https://catalog.example.com"},
+	AllowedMethods: []string{"GET", "POST"},
+	AllowedHeaders: []string{"Content-Type"},
+	ExposedHeaders: []string{RequestIDHeader},
+	MaxAge:         10 * time.Minute,
+}
+
+func preflight(origin string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: "OPTIONS",
+		Path:       "/book",
+		Headers:    map[string]string{"origin": origin, "access-control-request-method": "POST"},
+	}
+}
+
+func TestCORSAnswersPreflightFromAllowedOrigin(t *testing.T) {
+	handler, received := recording(events.APIGatewayProxyResponse{StatusCode: 405}, nil)
+
+	response, err := CORS(catalogCORSConfig)(handler)(context.Background(), preflight("https://catalog.example.com"))
+
+	expectedResponse := events.APIGatewayProxyResponse{
+		StatusCode: 204,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin":  "https://catalog.example.com",
+			"Access-Control-Allow-Methods": "GET, POST",
+			"Access-Control-Allow-Headers": "Content-Type",
+			"Access-Control-Max-Age":       "600",
+			"Vary":                         "Origin",
+		},
+	}
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, expectedResponse, response)
+	assert.Equal(t, "", received.HTTPMethod)
+}
+
+func TestCORSRejectsPreflightFromOtherOrigins(t *testing.T) {
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 405}, nil)
+
+	response, _ := CORS(catalogCORSConfig)(handler)(context.Background(), preflight("https://evil.example.com"))
+
+	assert.Equal(t, 403, response.StatusCode)
+	assert.Equal(t, `{"error":{"status":403,"code":"ORIGIN_NOT_ALLOWED","message":"Origin https://evil.example.com is not allowed"}}`, response.Body)
+	assert.NotContains(t, response.Headers, "Access-Control-Allow-Origin")
+}
+
+func TestCORSAllowsRequestsFromAllowedOrigin(t *testing.T) {
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 201, Headers: map[string]string{"Location": "/book/7"}}, nil)
+	request := events.APIGatewayProxyRequest{HTTPMethod: "POST", Headers: map[string]string{"Origin": "https://catalog.example.com"}}
+
+	response, _ := CORS(catalogCORSConfig)(handler)(context.Background(), request)
+
+	expectedHeaders := map[string]string{
+		"Location":                      "/book/7",
+		"Access-Control-Allow-Origin":   "https://catalog.example.com",
+		"Access-Control-Expose-Headers": RequestIDHeader,
+		"Vary":                          "Origin",
+	}
+	assert.Equal(t, expectedHeaders, response.Headers)
+}
+
+func TestCORSLeavesOtherRequestsAlone(t *testing.T) {
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	expectedResponse := events.APIGatewayProxyResponse{StatusCode: 200}
+
+	fromOtherOrigin := events.APIGatewayProxyRequest{HTTPMethod: "GET", Headers: map[string]string{"Origin": "https://evil.example.com"}}
+	response, _ := CORS(catalogCORSConfig)(handler)(context.Background(), fromOtherOrigin)
+	assert.Equal(t, expectedResponse, response)
+
+	response, _ = CORS(catalogCORSConfig)(handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.Equal(t, expectedResponse, response)
+
+	response, _ = CORS(CORSConfig{})(handler)(context.Background(), preflight("https://catalog.example.com"))
+	assert.Equal(t, expectedResponse, response)
+}
+
+func TestCORSAllowsAnyOrigin(t *testing.T) {
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	config := catalogCORSConfig
+	config.AllowedOrigins = []string{"*"}
+
+	response, _ := CORS(config)(handler)(context.Background(), events.APIGatewayProxyRequest{Headers: map[string]string{"Origin": "https://anywhere.example.com"}})
+
+	assert.Equal(t, "*", response.Headers["Access-Control-Allow-Origin"])
+	assert.NotContains(t, response.Headers, "Vary")
+}
+
+func TestNewCORSConfigFromEnv(t *testing.T) {
+	os.Setenv("CORS_ALLOWED_ORIGINS", "https://catalog.example.com, http://localhost:3000")
+	os.Setenv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization")
+	os.Setenv("CORS_MAX_AGE", "not a duration")
+	defer os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	defer os.Unsetenv("CORS_ALLOWED_HEADERS")
+	defer os.Unsetenv("CORS_MAX_AGE")
+
+	expectedConfig := CORSConfig{
+		AllowedOrigins: []string{"https://catalog.example.com", "http://localhost:3000"},
+		AllowedMethods: defaultCORSAllowedMethods,
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: defaultCORSExposedHeaders,
+		MaxAge:         defaultCORSMaxAge,
+	}
+	assert.Equal(t, expectedConfig, NewCORSConfigFromEnv())
+}
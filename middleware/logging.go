@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Logger writes structured logs, one JSON object per line, it's safe for concurrent use
+type Logger struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// NewLogger creates a Logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Log writes fields along with the time and level, fields that can't be converted to JSON are dropped
+func (l *Logger) Log(level string, fields map[string]interface{}) {
+	entry := map[string]interface{}{"time": time.Now().UTC().Format(time.RFC3339Nano), "level": level}
+	for name, value := range fields {
+		entry[name] = value
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{"time": entry["time"], "level": level, "error": "Failed to log entry: " + err.Error()})
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.w.Write(append(line, '\n'))
+}
+
+// requestState is what inner middleware and routers record about a request for outer middleware to find: the route
+// they matched and who made it. It's kept in the request's context rather than in the request, which is API Gateway's
+type requestState struct {
+	route       string
+	principalID string
+}
+
+type requestStateKey struct{}
+
+// WithRequestState makes sure ctx carries a request state, the outermost middleware needing one creates it so that
+// inner ones and routers record into the same, see SetRoute and SetPrincipalID
+func WithRequestState(ctx context.Context) context.Context {
+	if getRequestState(ctx) != nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, requestStateKey{}, &requestState{})
+}
+
+func getRequestState(ctx context.Context) *requestState {
+	state, _ := ctx.Value(requestStateKey{}).(*requestState)
+	return state
+}
+
+// SetPrincipalID records id, who made the request ctx belongs to, for Logging. Middleware authenticating requests call
+// it, see auth.Middleware
+func SetPrincipalID(ctx context.Context, id string) {
+	if state := getRequestState(ctx); state != nil {
+		state.principalID = id
+	}
+}
+
+// Logging logs every request once it's answered: its ID, method, path, status, how long it took, who made it and its
+// trace when it's traced. Requests answered with a 5xx or failing are logged as errors
+func Logging(logger *Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			ctx = WithRequestState(ctx)
+
+			start := time.Now()
+			response, err := next(ctx, request)
+
+			fields := map[string]interface{}{
+				"requestId": GetRequestID(request),
+				"method":    request.HTTPMethod,
+				"path":      request.Path,
+				"status":    response.StatusCode,
+				"latencyMs": float64(time.Since(start)) / float64(time.Millisecond),
+			}
+
+			if request.Resource != "" {
+				fields["resource"] = request.Resource
+			}
+
+			if sourceIP := request.RequestContext.Identity.SourceIP; sourceIP != "" {
+				fields["sourceIp"] = sourceIP
+			}
+
+			if principalID := getRequestState(ctx).principalID; principalID != "" {
+				fields["principalId"] = principalID
+			}
+
+			if span := GetSpan(ctx); span != nil {
+				fields["traceId"] = span.SpanContext().TraceID().String()
+			}
+
+			level := "info"
+			if err != nil {
+				fields["error"] = err.Error()
+				level = "error"
+			} else if response.StatusCode >= 500 {
+				level = "error"
+			}
+
+			logger.Log(level, fields)
+
+			return response, err
+		}
+	}
+}
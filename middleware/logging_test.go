@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// logged parses every line of output
+func logged(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n")) {
+		entry := make(map[string]interface{})
+		assert.Equal(t, nil, json.Unmarshal(line, &entry), string(line))
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestLoggingLogsEveryRequest(t *testing.T) {
+	output := &bytes.Buffer{}
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 201}, nil)
+
+	Logging(NewLogger(output))(handler)(context.Background(), events.APIGatewayProxyRequest{
+		Resource:   "/book",
+		Path:       "/book",
+		HTTPMethod: "POST",
+		Headers:    map[string]string{RequestIDHeader: "abc"},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: "192.0.2.1"},
+		},
+	})
+
+	entries := logged(t, output)
+	assert.Len(t, entries, 1)
+
+	entry := entries[0]
+	assert.Equal(t, "info", entry["level"])
+	assert.Equal(t, "abc", entry["requestId"])
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/book", entry["path"])
+	assert.Equal(t, "/book", entry["resource"])
+	assert.Equal(t, "192.0.2.1", entry["sourceIp"])
+	assert.Equal(t, float64(201), entry["status"])
+	assert.Contains(t, entry, "latencyMs")
+	assert.Contains(t, entry, "time")
+	assert.NotContains(t, entry, "error")
+}
+
+func TestLoggingLogsFailuresAsErrors(t *testing.T) {
+	output := &bytes.Buffer{}
+	failing, _ := recording(events.APIGatewayProxyResponse{}, errors.New("Boom"))
+	serverError, _ := recording(events.APIGatewayProxyResponse{StatusCode: 500}, nil)
+	clientError, _ := recording(events.APIGatewayProxyResponse{StatusCode: 404}, nil)
+
+	logger := NewLogger(output)
+	for _, handler := range []HandlerFunc{failing, serverError, clientError} {
+		Logging(logger)(handler)(context.Background(), events.APIGatewayProxyRequest{Path: "/book/1", HTTPMethod: "GET"})
+	}
+
+	entries := logged(t, output)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "error", entries[0]["level"])
+	assert.Equal(t, "Boom", entries[0]["error"])
+	assert.Equal(t, "error", entries[1]["level"])
+	assert.Equal(t, "info", entries[2]["level"])
+	assert.NotContains(t, entries[2], "resource")
+}
+
+func TestLoggingLogsPrincipalSetByInnerMiddleware(t *testing.T) {
+	output := &bytes.Buffer{}
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		SetPrincipalID(ctx, "api_key:7")
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	anonymous, _ := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+
+	Logging(NewLogger(output))(handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/book/1"})
+	Logging(NewLogger(output))(anonymous)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/book/1"})
+
+	entries := logged(t, output)
+	assert.Equal(t, "api_key:7", entries[0]["principalId"])
+	assert.NotContains(t, entries[1], "principalId")
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/metrics"
+)
+
+var requestsTotal = metrics.NewCounter("books_http_requests_total", "Requests answered, by route, method and status", "route", "method", "status")
+var requestDuration = metrics.NewHistogram("books_http_request_duration_seconds", "How long requests took to be answered, by route, method and status", metrics.Seconds, metrics.DurationBuckets, "route", "method", "status")
+
+// unmatchedRoute is the route of requests no route matched, so that unknown paths don't each get series of their own
+const unmatchedRoute = "unmatched"
+
+// SetRoute records route, the path a router matched the request ctx belongs to with, e.g. "/book/{id}", for outer
+// middleware to find. It's recorded in the request state they share through ctx, see WithRequestState
+func SetRoute(ctx context.Context, route string) {
+	if state := getRequestState(ctx); state != nil {
+		state.route = route
+	}
+}
+
+// GetRoute returns the route request was matched with: the one a router recorded in ctx or else API Gateway's
+// resource, unless it's a catch-all one
+func GetRoute(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	if state := getRequestState(ctx); state != nil && state.route != "" {
+		return state.route
+	}
+
+	if request.Resource == "" || strings.Contains(request.Resource, "+}") {
+		return unmatchedRoute
+	}
+
+	return request.Resource
+}
+
+// Metrics counts requests and how long they took, by route, method and status. Failed requests count as 502s, which
+// is what API Gateway answers them with
+func Metrics(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx = WithRequestState(ctx)
+
+		start := time.Now()
+		response, err := next(ctx, request)
+
+		status := strconv.Itoa(response.StatusCode)
+		if err != nil || response.StatusCode == 0 {
+			status = "502"
+		}
+
+		route := GetRoute(ctx, request)
+		requestsTotal.Inc(route, request.HTTPMethod, status)
+		requestDuration.ObserveSince(start, route, request.HTTPMethod, status)
+
+		return response, err
+	}
+}
+
+// FlushMetrics writes metrics once each request is answered, on Lambda only, see metrics.Flush
+func FlushMetrics(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		defer metrics.Flush()
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCountsRequestsByRouteSetByInnerHandler(t *testing.T) {
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		SetRoute(ctx, "/metrics-test/{id}")
+		return events.APIGatewayProxyResponse{StatusCode: 404}, nil
+	}
+
+	before := requestsTotal.Value("/metrics-test/{id}", "GET", "404")
+	Metrics(handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/metrics-test/1", Resource: "/{proxy+}"})
+
+	assert.Equal(t, before+1, requestsTotal.Value("/metrics-test/{id}", "GET", "404"))
+	assert.True(t, requestDuration.Count("/metrics-test/{id}", "GET", "404") > 0)
+}
+
+func TestMetricsCountsFailuresAs502(t *testing.T) {
+	failing, _ := recording(events.APIGatewayProxyResponse{}, errors.New("boom"))
+
+	before := requestsTotal.Value("/metrics-test-failing", "POST", "502")
+	Metrics(failing)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST", Resource: "/metrics-test-failing"})
+
+	assert.Equal(t, before+1, requestsTotal.Value("/metrics-test-failing", "POST", "502"))
+}
+
+func TestGetRoute(t *testing.T) {
+	withRoute := WithRequestState(context.Background())
+	SetRoute(withRoute, "/book/{id}")
+
+	assert.Equal(t, "/book/{id}", GetRoute(withRoute, events.APIGatewayProxyRequest{Resource: "/{proxy+}"}))
+	assert.Equal(t, "/books", GetRoute(context.Background(), events.APIGatewayProxyRequest{Resource: "/books"}))
+	assert.Equal(t, "unmatched", GetRoute(context.Background(), events.APIGatewayProxyRequest{Resource: "/{proxy+}"}))
+	assert.Equal(t, "unmatched", GetRoute(context.Background(), events.APIGatewayProxyRequest{Path: "/nowhere"}))
+}
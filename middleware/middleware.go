@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc is what every handler looks like, it's what `lambda.Start` is given. Requests and responses are
+// those of API Gateway's Lambda Proxy integration (serverless' default):
+//
+// https://serverless.com/framework/docs/providers/aws/events/apigateway/#lambda-proxy-integration
+//
+// What middleware find out about a request, like its span, route or principal, travels in ctx, requests are left as API
+// Gateway sent them
+type HandlerFunc func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps a handler to do something before and after it runs
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps handler with middleware, the first one being the outermost
+func Chain(handler HandlerFunc, middleware ...Middleware) HandlerFunc {
+	for index := len(middleware) - 1; index >= 0; index-- {
+		handler = middleware[index](handler)
+	}
+
+	return handler
+}
+
+// Standard wraps handler with what every handler needs: a request ID, a span, a log line and metrics for each request,
+// CORS configured from environment and panics turned into 500s. Logs are written to stdout, which Lambda sends to
+// CloudWatch, and so are metrics on Lambda
+func Standard(handler HandlerFunc) HandlerFunc {
+	logger := NewLogger(os.Stdout)
+	return Chain(handler, FlushMetrics, RequestID, Tracing, Logging(logger), Metrics, CORS(NewCORSConfigFromEnv()), Recover(logger))
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+// recording answers every request with response and keeps the last request it got
+func recording(response events.APIGatewayProxyResponse, err error) (HandlerFunc, *events.APIGatewayProxyRequest) {
+	received := &events.APIGatewayProxyRequest{}
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		*received = request
+		return response, err
+	}, received
+}
+
+func TestChainRunsFirstMiddlewareOutermost(t *testing.T) {
+	var calls []string
+	tracing := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, name+" before")
+				response, err := next(ctx, request)
+				calls = append(calls, name+" after")
+				return response, err
+			}
+		}
+	}
+
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	Chain(handler, tracing("first"), tracing("second"))(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, []string{"first before", "second before", "second after", "first after"}, calls)
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+)
+
+// Recover turns a panicking handler into a 500 INTERNAL_ERROR, the panic and its stack trace are logged
+func Recover(logger *Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					logger.Log("error", map[string]interface{}{
+						"requestId": GetRequestID(request),
+						"panic":     fmt.Sprint(recovered),
+						"stack":     string(debug.Stack()),
+					})
+
+					response = apierror.Internal().Response(request)
+					err = nil
+				}
+			}()
+
+			return next(ctx, request)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverTurnsPanicsIntoServerErrors(t *testing.T) {
+	output := &bytes.Buffer{}
+	panicking := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("Could not connect to database")
+	}
+
+	response, err := Recover(NewLogger(output))(panicking)(context.Background(), events.APIGatewayProxyRequest{Headers: map[string]string{RequestIDHeader: "abc"}})
+
+	assert.Equal(t, nil, err)
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":500,"code":"INTERNAL_ERROR","message":"Sorry, something went wrong on our side","requestId":"abc"}}`,
+		StatusCode: 500,
+	}
+	assert.Equal(t, expectedResponse, response)
+
+	entries := logged(t, output)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "error", entries[0]["level"])
+	assert.Equal(t, "abc", entries[0]["requestId"])
+	assert.Equal(t, "Could not connect to database", entries[0]["panic"])
+	assert.Contains(t, entries[0]["stack"], "recover_test.go")
+}
+
+func TestRecoveredPanicsAreLoggedWithTheirRequestID(t *testing.T) {
+	output := &bytes.Buffer{}
+	panicking := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		panic("Boom")
+	}
+
+	logger := NewLogger(output)
+	response, _ := Chain(panicking, RequestID, Logging(logger), Recover(logger))(context.Background(), events.APIGatewayProxyRequest{Path: "/book/1", HTTPMethod: "GET"})
+
+	assert.Equal(t, 500, response.StatusCode)
+	assert.NotEqual(t, "", response.Headers[RequestIDHeader])
+
+	entries := logged(t, output)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "Boom", entries[0]["panic"])
+	assert.Equal(t, float64(500), entries[1]["status"])
+	assert.Equal(t, response.Headers[RequestIDHeader], entries[0]["requestId"])
+	assert.Equal(t, response.Headers[RequestIDHeader], entries[1]["requestId"])
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RequestIDHeader carries the ID of a request, it's propagated when given and generated otherwise
+const RequestIDHeader = "X-Request-Id"
+
+// RequestID makes sure every request has an ID under RequestIDHeader and answers with it. The client's ID is kept
+// when there's one, API Gateway's is used otherwise, and a new one is generated when there's neither
+func RequestID(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		id := GetRequestID(request)
+		if id == "" {
+			id = request.RequestContext.RequestID
+		}
+
+		if id == "" {
+			id = newRequestID()
+		}
+
+		request = withHeader(request, RequestIDHeader, id)
+
+		response, err := next(ctx, request)
+		if err == nil {
+			response.Headers = copyHeaders(response.Headers)
+			response.Headers[RequestIDHeader] = id
+		}
+
+		return response, err
+	}
+}
+
+// GetRequestID returns request's ID, see RequestID
+func GetRequestID(request events.APIGatewayProxyRequest) string {
+	return GetHeader(request, RequestIDHeader)
+}
+
+// GetHeader returns request's header called name, whatever its case. API Gateway passes headers as clients send them
+func GetHeader(request events.APIGatewayProxyRequest, name string) string {
+	for headerName, value := range request.Headers {
+		if http.CanonicalHeaderKey(headerName) == http.CanonicalHeaderKey(name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(id)
+}
+
+// withHeader returns request with header set, request's own headers are left untouched
+func withHeader(request events.APIGatewayProxyRequest, name string, value string) events.APIGatewayProxyRequest {
+	headers := make(map[string]string, len(request.Headers)+1)
+	for headerName, headerValue := range request.Headers {
+		if http.CanonicalHeaderKey(headerName) != http.CanonicalHeaderKey(name) {
+			headers[headerName] = headerValue
+		}
+	}
+
+	headers[name] = value
+	request.Headers = headers
+
+	return request
+}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers)+1)
+	for name, value := range headers {
+		copied[name] = value
+	}
+
+	return copied
+}
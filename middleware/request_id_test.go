@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDPropagatesClientID(t *testing.T) {
+	handler, received := recording(events.APIGatewayProxyResponse{StatusCode: 200, Headers: map[string]string{"Location": "/book/7"}}, nil)
+	request := events.APIGatewayProxyRequest{
+		Headers:        map[string]string{"x-request-id": "from-client"},
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "from-gateway"},
+	}
+
+	response, err := RequestID(handler)(context.Background(), request)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, map[string]string{RequestIDHeader: "from-client"}, received.Headers)
+	assert.Equal(t, map[string]string{"Location": "/book/7", RequestIDHeader: "from-client"}, response.Headers)
+	assert.Equal(t, map[string]string{"x-request-id": "from-client"}, request.Headers)
+}
+
+func TestRequestIDUsesAPIGatewayID(t *testing.T) {
+	handler, received := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+
+	response, _ := RequestID(handler)(context.Background(), events.APIGatewayProxyRequest{
+		RequestContext: events.APIGatewayProxyRequestContext{RequestID: "from-gateway"},
+	})
+
+	assert.Equal(t, "from-gateway", GetRequestID(*received))
+	assert.Equal(t, "from-gateway", response.Headers[RequestIDHeader])
+}
+
+func TestRequestIDGeneratesMissingID(t *testing.T) {
+	handler, received := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+
+	response, _ := RequestID(handler)(context.Background(), events.APIGatewayProxyRequest{})
+	id := GetRequestID(*received)
+
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, response.Headers[RequestIDHeader])
+
+	RequestID(handler)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.NotEqual(t, id, GetRequestID(*received))
+}
+
+func TestRequestIDLeavesFailuresAlone(t *testing.T) {
+	handler, _ := recording(events.APIGatewayProxyResponse{}, errors.New("Boom"))
+
+	response, err := RequestID(handler)(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, errors.New("Boom"), err)
+	assert.Nil(t, response.Headers)
+}
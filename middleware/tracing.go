@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/tracing"
+)
+
+type spanKey struct{}
+
+// GetSpan returns the span Tracing started for the request ctx belongs to, nil when it isn't traced. Handlers start
+// spans for what they do within it
+func GetSpan(ctx context.Context) *tracing.Span {
+	span, _ := ctx.Value(spanKey{}).(*tracing.Span)
+	return span
+}
+
+// Tracing traces each request with tracing.Default, within the trace of the traceparent header when there's one.
+// The span is named after the request's route and exported once the request is answered
+func Tracing(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		tracer := tracing.Default
+		parent := tracing.Extract(func(name string) string { return GetHeader(request, name) })
+
+		span := tracer.Start(request.HTTPMethod+" "+request.Path, tracing.KindServer, parent)
+		if span == nil {
+			return next(ctx, request)
+		}
+
+		ctx = context.WithValue(WithRequestState(ctx), spanKey{}, span)
+
+		response, err := next(ctx, request)
+
+		route := GetRoute(ctx, request)
+		span.SetName(request.HTTPMethod + " " + route)
+		span.SetAttribute("http.method", request.HTTPMethod)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", request.Path)
+		span.SetAttribute("http.status_code", response.StatusCode)
+		span.SetAttribute("request.id", GetRequestID(request))
+		span.SetError(err)
+
+		if err == nil && response.StatusCode >= 500 {
+			span.SetErrorStatus(http.StatusText(response.StatusCode))
+		}
+
+		span.End()
+
+		if flushErr := tracer.Flush(); flushErr != nil {
+			log.Printf("Could not export spans: %s", flushErr.Error())
+		}
+
+		return response, err
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// withTracer makes tracing.Default export to an in memory exporter until the returned function is called
+func withTracer() (*tracetest.InMemoryExporter, func()) {
+	previous := tracing.Default
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Default = tracing.NewTracer(exporter, "books")
+
+	return exporter, func() { tracing.Default = previous }
+}
+
+func TestTracingContinuesTraceOfTraceparent(t *testing.T) {
+	exporter, restore := withTracer()
+	defer restore()
+
+	var child *tracing.Span
+	var received events.APIGatewayProxyRequest
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received = request
+		SetRoute(ctx, "/book/{id}")
+		child = GetSpan(ctx).StartChild("query books", tracing.KindClient)
+		child.End()
+		return events.APIGatewayProxyResponse{StatusCode: 503}, nil
+	}
+
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/book/1",
+		Headers:    map[string]string{"Traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", RequestIDHeader: "abc"},
+	}
+
+	response, err := Tracing(handler)(context.Background(), request)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 503, response.StatusCode)
+	assert.Equal(t, request, received)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	span := spans[1]
+	assert.Equal(t, "GET /book/{id}", span.Name)
+	assert.Equal(t, tracing.KindServer, span.SpanKind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+	assert.Equal(t, span.SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, child.SpanContext(), spans[0].SpanContext)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+		attribute.String("http.route", "/book/{id}"),
+		attribute.String("http.target", "/book/1"),
+		attribute.Int("http.status_code", 503),
+		attribute.String("request.id", "abc"),
+	}, span.Attributes)
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Equal(t, "Service Unavailable", span.Status.Description)
+}
+
+func TestTracingLeavesRequestsAloneWhenDisabled(t *testing.T) {
+	var received events.APIGatewayProxyRequest
+	var span *tracing.Span
+	handler := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received, span = request, GetSpan(ctx)
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	request := events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/books"}
+	Tracing(handler)(context.Background(), request)
+
+	assert.Equal(t, request, received)
+	assert.Nil(t, span)
+}
+
+func TestLoggingLogsTraceID(t *testing.T) {
+	_, restore := withTracer()
+	defer restore()
+
+	output := &bytes.Buffer{}
+	handler, _ := recording(events.APIGatewayProxyResponse{StatusCode: 200}, nil)
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/books",
+		Headers:    map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+	}
+
+	Chain(handler, Tracing, Logging(NewLogger(output)))(context.Background(), request)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logged(t, output)[0]["traceId"])
+}
@@ -0,0 +1,121 @@
+package migrations
+
+import (
+	"fmt"
+
+	"github.com/felipefill/books/normalize"
+	"github.com/jinzhu/gorm"
+)
+
+// backfillBookIdentity fills normalized ISBN and title of books stored before they existed. It fails when two books
+// turn out to be the same one, they have to be merged by hand before unique indexes can be created
+func backfillBookIdentity(tx *gorm.DB) error {
+	rows, err := tx.Raw("SELECT id, isbn, title FROM books ORDER BY id").Rows()
+	if err != nil {
+		return err
+	}
+
+	type identity struct {
+		id    uint
+		isbn  string
+		title string
+	}
+
+	identities := make([]identity, 0)
+	for rows.Next() {
+		var id uint
+		var isbn, title *string
+		if err := rows.Scan(&id, &isbn, &title); err != nil {
+			rows.Close()
+			return err
+		}
+
+		book := identity{id: id}
+		if isbn != nil {
+			book.isbn = normalize.ISBN(*isbn)
+		}
+
+		if title != nil {
+			book.title = normalize.Title(*title)
+		}
+
+		identities = append(identities, book)
+	}
+	rows.Close()
+
+	byISBN := make(map[string]uint)
+	byTitle := make(map[string]uint)
+
+	for _, book := range identities {
+		if id, ok := byISBN[book.isbn]; ok && book.isbn != "" {
+			return fmt.Errorf("Books %d and %d have the same ISBN %s, merge them before migrating", id, book.id, book.isbn)
+		}
+
+		if id, ok := byTitle[book.title]; ok {
+			return fmt.Errorf("Books %d and %d have the same title %q, merge them before migrating", id, book.id, book.title)
+		}
+
+		byISBN[book.isbn] = book.id
+		byTitle[book.title] = book.id
+
+		var isbn interface{}
+		if book.isbn != "" {
+			isbn = book.isbn
+		}
+
+		if err := tx.Exec("UPDATE books SET normalized_isbn = ?, normalized_title = ? WHERE id = ?", isbn, book.title, book.id).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sqliteBookColumns are columns of books the baseline schema, created by GORM's AutoMigrate, didn't have
+var sqliteBookColumns = []struct{ name, definition string }{
+	{"source_name", "varchar(50)"},
+	{"source_url", "text"},
+	{"detail_url", "text"},
+	{"first_seen_at", "datetime"},
+	{"last_seen_at", "datetime"},
+	{"content_hash", "varchar(64)"},
+}
+
+// addMissingBookColumns adds to a SQLite books table created before migrations existed the columns it lacks, Postgres
+// adds them with ADD COLUMN IF NOT EXISTS instead
+func addMissingBookColumns(tx *gorm.DB) error {
+	if tx.Dialect().GetName() != "sqlite3" {
+		return nil
+	}
+
+	rows, err := tx.Raw("PRAGMA table_info(books)").Rows()
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, kind string
+		var defaultValue *string
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &primaryKey); err != nil {
+			rows.Close()
+			return err
+		}
+
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, column := range sqliteBookColumns {
+		if existing[column.name] {
+			continue
+		}
+
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE books ADD COLUMN %s %s", column.name, column.definition)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
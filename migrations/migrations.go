@@ -0,0 +1,226 @@
+package migrations
+
+// all lists every migration in the order they are applied, versions must be sequential starting from 1.
+// Never edit a migration that was already released, add a new one instead
+var all = []Migration{
+	{
+		Version: 1,
+		Name:    "create_books_and_scrap_jobs",
+		Up: SQL{
+			// IF NOT EXISTS keeps databases created by GORM's AutoMigrate working, their schema is the same
+			Postgres: `
+				CREATE TABLE IF NOT EXISTS books (
+					id serial PRIMARY KEY,
+					isbn varchar(13),
+					title varchar(100),
+					description text,
+					language varchar(2),
+					source_name varchar(50),
+					source_url text,
+					detail_url text,
+					first_seen_at timestamp with time zone,
+					last_seen_at timestamp with time zone,
+					content_hash varchar(64)
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS uix_books_title ON books (title);
+
+				CREATE TABLE IF NOT EXISTS scrap_jobs (
+					id serial PRIMARY KEY,
+					mode varchar(20),
+					force_refresh boolean,
+					status varchar(10),
+					progress text,
+					result text,
+					error text,
+					created_at timestamp with time zone,
+					started_at timestamp with time zone,
+					finished_at timestamp with time zone
+				);
+				CREATE INDEX IF NOT EXISTS idx_scrap_jobs_status ON scrap_jobs (status);`,
+			SQLite: `
+				CREATE TABLE IF NOT EXISTS books (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					isbn varchar(13),
+					title varchar(100),
+					description text,
+					language varchar(2),
+					source_name varchar(50),
+					source_url text,
+					detail_url text,
+					first_seen_at datetime,
+					last_seen_at datetime,
+					content_hash varchar(64)
+				);
+				CREATE UNIQUE INDEX IF NOT EXISTS uix_books_title ON books (title);
+
+				CREATE TABLE IF NOT EXISTS scrap_jobs (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					mode varchar(20),
+					force_refresh bool,
+					status varchar(10),
+					progress text,
+					result text,
+					error text,
+					created_at datetime,
+					started_at datetime,
+					finished_at datetime
+				);
+				CREATE INDEX IF NOT EXISTS idx_scrap_jobs_status ON scrap_jobs (status);`,
+		},
+		Down: SQL{
+			Postgres: `DROP TABLE scrap_jobs; DROP TABLE books;`,
+			SQLite:   `DROP TABLE scrap_jobs; DROP TABLE books;`,
+		},
+	}, {
+		Version: 2,
+		Name:    "add_book_identity_columns",
+		Up: SQL{
+			Postgres: `
+				ALTER TABLE books ADD COLUMN normalized_isbn varchar(13);
+				ALTER TABLE books ADD COLUMN normalized_title varchar(100);`,
+			SQLite: `
+				ALTER TABLE books ADD COLUMN normalized_isbn varchar(13);
+				ALTER TABLE books ADD COLUMN normalized_title varchar(100);`,
+		},
+		Data: backfillBookIdentity,
+		Down: SQL{
+			Postgres: `ALTER TABLE books DROP COLUMN normalized_isbn; ALTER TABLE books DROP COLUMN normalized_title;`,
+			SQLite:   `ALTER TABLE books DROP COLUMN normalized_isbn; ALTER TABLE books DROP COLUMN normalized_title;`,
+		},
+	},
+	{
+		Version: 3,
+		Name:    "index_book_identity",
+		Up: SQL{
+			Postgres: `
+				CREATE UNIQUE INDEX uix_books_normalized_isbn ON books (normalized_isbn) WHERE normalized_isbn IS NOT NULL;
+				CREATE UNIQUE INDEX uix_books_normalized_title ON books (normalized_title);`,
+			SQLite: `
+				CREATE UNIQUE INDEX uix_books_normalized_isbn ON books (normalized_isbn) WHERE normalized_isbn IS NOT NULL;
+				CREATE UNIQUE INDEX uix_books_normalized_title ON books (normalized_title);`,
+		},
+		Down: SQL{
+			Postgres: `DROP INDEX uix_books_normalized_isbn; DROP INDEX uix_books_normalized_title;`,
+			SQLite:   `DROP INDEX uix_books_normalized_isbn; DROP INDEX uix_books_normalized_title;`,
+		},
+	},
+	{
+		Version: 4,
+		Name:    "create_api_keys",
+		Up: SQL{
+			Postgres: `
+				CREATE TABLE api_keys (
+					id serial PRIMARY KEY,
+					name varchar(100),
+					prefix varchar(12),
+					hash varchar(64) NOT NULL,
+					created_at timestamp with time zone,
+					revoked_at timestamp with time zone
+				);
+				CREATE UNIQUE INDEX uix_api_keys_hash ON api_keys (hash);`,
+			SQLite: `
+				CREATE TABLE api_keys (
+					id integer PRIMARY KEY AUTOINCREMENT,
+					name varchar(100),
+					prefix varchar(12),
+					hash varchar(64) NOT NULL,
+					created_at datetime,
+					revoked_at datetime
+				);
+				CREATE UNIQUE INDEX uix_api_keys_hash ON api_keys (hash);`,
+		},
+		Down: SQL{
+			Postgres: `DROP TABLE api_keys;`,
+			SQLite:   `DROP TABLE api_keys;`,
+		},
+	},
+	{
+		Version: 5,
+		Name:    "create_rate_limit_buckets",
+		Up: SQL{
+			Postgres: `
+				CREATE TABLE rate_limit_buckets (
+					key varchar(200) PRIMARY KEY,
+					tokens double precision,
+					refilled_at timestamp with time zone,
+					version integer
+				);`,
+			SQLite: `
+				CREATE TABLE rate_limit_buckets (
+					key varchar(200) PRIMARY KEY,
+					tokens real,
+					refilled_at datetime,
+					version integer
+				);`,
+		},
+		Down: SQL{
+			Postgres: `DROP TABLE rate_limit_buckets;`,
+			SQLite:   `DROP TABLE rate_limit_buckets;`,
+		},
+	},
+	{
+		Version: 6,
+		Name:    "add_scrap_job_heartbeat",
+		Up: SQL{
+			Postgres: `ALTER TABLE scrap_jobs ADD COLUMN heartbeat_at timestamp with time zone;`,
+			SQLite:   `ALTER TABLE scrap_jobs ADD COLUMN heartbeat_at datetime;`,
+		},
+		Down: SQL{
+			Postgres: `ALTER TABLE scrap_jobs DROP COLUMN heartbeat_at;`,
+			SQLite:   `ALTER TABLE scrap_jobs DROP COLUMN heartbeat_at;`,
+		},
+	},
+	{
+		Version: 7,
+		Name:    "add_rate_limit_bucket_full_at",
+		Up: SQL{
+			Postgres: `
+				ALTER TABLE rate_limit_buckets ADD COLUMN full_at timestamp with time zone;
+				CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);`,
+			SQLite: `
+				ALTER TABLE rate_limit_buckets ADD COLUMN full_at datetime;
+				CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);`,
+		},
+		Down: SQL{
+			Postgres: `
+				DROP INDEX idx_rate_limit_buckets_full_at;
+				ALTER TABLE rate_limit_buckets DROP COLUMN full_at;`,
+			SQLite: `
+				DROP INDEX idx_rate_limit_buckets_full_at;
+				ALTER TABLE rate_limit_buckets DROP COLUMN full_at;`,
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add_scrap_job_merge_and_provenance",
+		Up: SQL{
+			Postgres: `
+				ALTER TABLE scrap_jobs ADD COLUMN merge varchar(20) NOT NULL DEFAULT '';
+				ALTER TABLE scrap_jobs ADD COLUMN provenance boolean NOT NULL DEFAULT false;`,
+			SQLite: `
+				ALTER TABLE scrap_jobs ADD COLUMN merge varchar(20) NOT NULL DEFAULT '';
+				ALTER TABLE scrap_jobs ADD COLUMN provenance bool NOT NULL DEFAULT 0;`,
+		},
+		Down: SQL{
+			Postgres: `ALTER TABLE scrap_jobs DROP COLUMN merge; ALTER TABLE scrap_jobs DROP COLUMN provenance;`,
+			SQLite:   `ALTER TABLE scrap_jobs DROP COLUMN merge; ALTER TABLE scrap_jobs DROP COLUMN provenance;`,
+		},
+	},
+	{
+		// Books tables created by GORM's AutoMigrate before provenance existed were left without its columns by
+		// migration 1, there's nothing to roll back since migration 1 owns them
+		Version: 9,
+		Name:    "add_missing_book_provenance_columns",
+		Up: SQL{
+			Postgres: `
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS source_name varchar(50);
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS source_url text;
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS detail_url text;
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS first_seen_at timestamp with time zone;
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS last_seen_at timestamp with time zone;
+				ALTER TABLE books ADD COLUMN IF NOT EXISTS content_hash varchar(64);`,
+		},
+		// SQLite can't ADD COLUMN IF NOT EXISTS, columns are added there
+		Data: addMissingBookColumns,
+	},
+}
@@ -0,0 +1,186 @@
+package migrations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SQL holds the same statements written for each supported database
+type SQL struct {
+	Postgres string
+	SQLite   string
+}
+
+// Migration is a numbered schema change that can be applied (Up) and rolled back (Down)
+type Migration struct {
+	Version uint   `json:"version"`
+	Name    string `json:"name"`
+	Up      SQL    `json:"-"`
+	Down    SQL    `json:"-"`
+
+	// Data, when set, runs after Up in the same transaction, it's meant for changes SQL alone can't do
+	Data func(tx *gorm.DB) error `json:"-"`
+}
+
+// Status tells which version a database is at and which one code expects
+type Status struct {
+	Current uint `json:"current"`
+	Latest  uint `json:"latest"`
+}
+
+// UpToDate tells whether every migration was applied
+func (s Status) UpToDate() bool {
+	return s.Current >= s.Latest
+}
+
+// schemaMigration is a row of schema_migrations, there's one for each applied migration
+type schemaMigration struct {
+	Version   uint `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Latest returns the version database should be at
+func Latest() uint {
+	return all[len(all)-1].Version
+}
+
+// CurrentStatus reads which version database is at, a database that was never migrated is at version zero
+func CurrentStatus(db *gorm.DB) (Status, error) {
+	status := Status{Latest: Latest()}
+
+	if !db.HasTable(&schemaMigration{}) {
+		return status, nil
+	}
+
+	row := db.Model(&schemaMigration{}).Select("COALESCE(MAX(version), 0)").Row()
+	if err := row.Scan(&status.Current); err != nil {
+		return status, err
+	}
+
+	return status, nil
+}
+
+// CheckUpToDate fails when database is behind code, nothing should be served in that case
+func CheckUpToDate(db *gorm.DB) error {
+	status, err := CurrentStatus(db)
+	if err != nil {
+		return fmt.Errorf("Failed to read schema version: %s", err.Error())
+	}
+
+	if !status.UpToDate() {
+		return fmt.Errorf("Database schema is at version %d but %d is required, migrations must be applied first", status.Current, status.Latest)
+	}
+
+	return nil
+}
+
+// Up applies every pending migration in order and returns those applied, each one runs in its own transaction
+func Up(db *gorm.DB) ([]Migration, error) {
+	applied := make([]Migration, 0)
+
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return applied, err
+	}
+
+	status, err := CurrentStatus(db)
+	if err != nil {
+		return applied, err
+	}
+
+	for _, migration := range all {
+		if migration.Version <= status.Current {
+			continue
+		}
+
+		err := run(db, migration, migration.Up, func(tx *gorm.DB) error {
+			if migration.Data != nil {
+				if err := migration.Data(tx); err != nil {
+					return fmt.Errorf("Migration %d (%s) failed: %s", migration.Version, migration.Name, err.Error())
+				}
+			}
+
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the last steps migrations, newest first, and returns those rolled back
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	rolledBack := make([]Migration, 0)
+
+	status, err := CurrentStatus(db)
+	if err != nil {
+		return rolledBack, err
+	}
+
+	for index := len(all) - 1; index >= 0 && len(rolledBack) < steps; index-- {
+		migration := all[index]
+		if migration.Version > status.Current {
+			continue
+		}
+
+		err := run(db, migration, migration.Down, func(tx *gorm.DB) error {
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return rolledBack, err
+		}
+
+		rolledBack = append(rolledBack, migration)
+	}
+
+	return rolledBack, nil
+}
+
+// run executes statements written for db's dialect and records it, all in a single transaction
+func run(db *gorm.DB, migration Migration, statements SQL, record func(tx *gorm.DB) error) error {
+	script, err := forDialect(db, statements)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	// Some migrations only change data, or have nothing to roll back, on some databases
+	if script != "" {
+		if err := tx.Exec(script).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("Migration %d (%s) failed: %s", migration.Version, migration.Name, err.Error())
+		}
+	}
+
+	if err := record(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func forDialect(db *gorm.DB, statements SQL) (string, error) {
+	switch dialect := db.Dialect().GetName(); dialect {
+	case "postgres":
+		return statements.Postgres, nil
+	case "sqlite3":
+		return statements.SQLite, nil
+	default:
+		return "", fmt.Errorf("Migrations don't support %s databases", dialect)
+	}
+}
@@ -0,0 +1,217 @@
+package migrations
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // SQLite dialect for GORM
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database
+	db.DB().SetMaxOpenConns(1)
+
+	return db
+}
+
+func versionsOf(migrations []Migration) []uint {
+	versions := make([]uint, 0, len(migrations))
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+
+	return versions
+}
+
+func TestMigrationsAreSequential(t *testing.T) {
+	for index, migration := range all {
+		assert.Equal(t, uint(index+1), migration.Version)
+		assert.NotEqual(t, "", migration.Name)
+
+		// Only migrations changing data may have no statements, what they do isn't rolled back by SQL
+		if migration.Data == nil {
+			assert.NotEqual(t, "", migration.Up.Postgres)
+			assert.NotEqual(t, "", migration.Up.SQLite)
+			assert.NotEqual(t, "", migration.Down.Postgres)
+			assert.NotEqual(t, "", migration.Down.SQLite)
+		}
+	}
+}
+
+func TestUpAppliesEveryMigrationOnce(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	applied, err := Up(db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, versionsOf(all), versionsOf(applied))
+	assert.True(t, db.HasTable("books"))
+	assert.True(t, db.HasTable("scrap_jobs"))
+
+	applied, err = Up(db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []Migration{}, applied)
+
+	status, err := CurrentStatus(db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Status{Current: Latest(), Latest: Latest()}, status)
+}
+
+func TestDownRollsBackLastMigrations(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	Up(db)
+
+	rolledBack, err := Down(db, 1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []uint{Latest()}, versionsOf(rolledBack))
+
+	status, err := CurrentStatus(db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Latest()-1, status.Current)
+
+	rolledBack, err = Down(db, len(all))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int(Latest()-1), len(rolledBack))
+	assert.False(t, db.HasTable("books"))
+	assert.False(t, db.HasTable("scrap_jobs"))
+}
+
+func TestCheckUpToDate(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	expectedError := fmt.Sprintf("Database schema is at version 0 but %d is required, migrations must be applied first", Latest())
+	assert.EqualError(t, CheckUpToDate(db), expectedError)
+
+	Up(db)
+	assert.Equal(t, nil, CheckUpToDate(db))
+
+	Down(db, 1)
+	assert.NotEqual(t, nil, CheckUpToDate(db))
+}
+
+func TestUpRollsBackFailingMigration(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	// A table in the way makes the unique index on title impossible to create
+	db.Exec("CREATE TABLE books (id integer PRIMARY KEY, name text)")
+
+	applied, err := Up(db)
+	assert.Equal(t, []Migration{}, applied)
+	assert.NotEqual(t, nil, err)
+	assert.False(t, db.HasTable("scrap_jobs"))
+
+	status, _ := CurrentStatus(db)
+	assert.Equal(t, uint(0), status.Current)
+}
+
+// baselineBook is how books were stored before migrations existed, its table was created by GORM's AutoMigrate
+type baselineBook struct {
+	ID          uint   `gorm:"primary_key"`
+	ISBN        string `gorm:"size:13"`
+	Title       string `gorm:"type:varchar(100);unique_index"`
+	Description string
+	Language    string `gorm:"size:2"`
+}
+
+func (baselineBook) TableName() string {
+	return "books"
+}
+
+func TestUpUpgradesBaselineBooksTable(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	db.AutoMigrate(&baselineBook{})
+	db.Create(&baselineBook{ISBN: "9781617293290", Title: "Kotlin in Action", Language: "EN"})
+
+	_, err := Up(db)
+	assert.Equal(t, nil, err)
+
+	for _, column := range []string{"source_name", "source_url", "detail_url", "first_seen_at", "last_seen_at", "content_hash"} {
+		assert.True(t, db.Dialect().HasColumn("books", column), column)
+	}
+
+	var title, normalizedTitle string
+	var sourceName *string
+	row := db.Raw("SELECT title, normalized_title, source_name FROM books WHERE isbn = '9781617293290'").Row()
+	assert.Equal(t, nil, row.Scan(&title, &normalizedTitle, &sourceName))
+	assert.Equal(t, "Kotlin in Action", title)
+	assert.Equal(t, "kotlin in action", normalizedTitle)
+	assert.Nil(t, sourceName)
+
+	assert.Equal(t, nil, db.Exec("UPDATE books SET source_name = 'kotlinlang.org', content_hash = 'abc'").Error)
+}
+
+func TestUpAddsProvenanceColumnsToBaselineBooksTableAlreadyMigrated(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	db.AutoMigrate(&baselineBook{})
+	_, err := Up(db)
+	assert.Equal(t, nil, err)
+
+	// That's how released migration 1 left a baseline table, then the repair is rolled back to apply it again
+	for _, column := range []string{"source_name", "source_url", "detail_url", "first_seen_at", "last_seen_at", "content_hash"} {
+		assert.Equal(t, nil, db.Exec("ALTER TABLE books DROP COLUMN "+column).Error)
+	}
+	_, err = Down(db, 1)
+	assert.Equal(t, nil, err)
+
+	applied, err := Up(db)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []uint{Latest()}, versionsOf(applied))
+
+	for _, column := range []string{"source_name", "source_url", "detail_url", "first_seen_at", "last_seen_at", "content_hash"} {
+		assert.True(t, db.Dialect().HasColumn("books", column), column)
+	}
+}
+
+func TestUpBackfillsBookIdentity(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	Up(db)
+	Down(db, int(Latest()-1))
+
+	db.Exec("INSERT INTO books (title, isbn) VALUES ('Kotlin in Action!', '978-1-61729-329-0'), ('Kotlin Cookbook', 'Unavailable')")
+
+	_, err := Up(db)
+	assert.Equal(t, nil, err)
+
+	var normalizedISBN *string
+	var normalizedTitle string
+	db.Raw("SELECT normalized_isbn, normalized_title FROM books WHERE title = 'Kotlin in Action!'").Row().Scan(&normalizedISBN, &normalizedTitle)
+	assert.Equal(t, "9781617293290", *normalizedISBN)
+	assert.Equal(t, "kotlin in action", normalizedTitle)
+
+	db.Raw("SELECT normalized_isbn, normalized_title FROM books WHERE title = 'Kotlin Cookbook'").Row().Scan(&normalizedISBN, &normalizedTitle)
+	assert.Nil(t, normalizedISBN)
+	assert.Equal(t, "kotlin cookbook", normalizedTitle)
+}
+
+func TestUpRefusesToBackfillDuplicateBooks(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	Up(db)
+	Down(db, int(Latest()-1))
+
+	db.Exec("INSERT INTO books (title, isbn) VALUES ('Kotlin in Action', '9781617293290'), ('Kotlin in Action (2017)', '1617293296')")
+
+	_, err := Up(db)
+	assert.EqualError(t, err, "Migration 2 (add_book_identity_columns) failed: Books 1 and 2 have the same ISBN 9781617293290, merge them before migrating")
+
+	status, _ := CurrentStatus(db)
+	assert.Equal(t, uint(1), status.Current)
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to spot
+const apiKeyPrefix = "bk_"
+
+// APIKey lets a client authenticate. Only the key's hash is stored, the key itself is shown once when it's created
+type APIKey struct {
+	ID   uint   `gorm:"primary_key" json:"id"`
+	Name string `gorm:"size:100" json:"name"`
+
+	// Prefix is the beginning of the key, it tells keys apart without revealing them
+	Prefix string `gorm:"size:12" json:"prefix"`
+
+	Hash      string    `gorm:"size:64;unique_index" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	RevokedAt null.Time `json:"revokedAt"`
+}
+
+// NewAPIKey generates a key named name, it returns the key along with the APIKey to store
+func NewAPIKey(name string) (string, APIKey, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, APIKey{Name: name, Prefix: key[:12], Hash: HashAPIKey(key)}, nil
+}
+
+// HashAPIKey returns what's stored of key. Keys are random enough that a plain SHA-256 can't be reversed
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Revoked tells whether k can't be used anymore
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt.Valid
+}
+
+// Create stores k in database
+func (k *APIKey) Create(db *gorm.DB) error {
+	return db.Create(k).Error
+}
+
+// FindAPIKeyByHash retrieves the key with given hash, revoked or not, returns nil when there's no such key
+func FindAPIKeyByHash(db *gorm.DB, hash string) (*APIKey, error) {
+	key := APIKey{}
+
+	dbc := db.Where("hash = ?", hash).Find(&key)
+	if dbc.RecordNotFound() {
+		return nil, nil
+	}
+
+	if dbc.Error != nil {
+		return nil, dbc.Error
+	}
+
+	return &key, nil
+}
+
+// ListAPIKeys retrieves every key, oldest first
+func ListAPIKeys(db *gorm.DB) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	if err := db.Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey stops key with given ID from being used, returns false when there's no such key or it's revoked already
+func RevokeAPIKey(db *gorm.DB, id uint) (bool, error) {
+	dbc := db.Model(&APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if dbc.Error != nil {
+		return false, dbc.Error
+	}
+
+	return dbc.RowsAffected == 1, nil
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, apiKey, err := NewAPIKey("catalog")
+
+	assert.Equal(t, nil, err)
+	assert.True(t, strings.HasPrefix(key, "bk_"))
+	assert.Equal(t, 46, len(key))
+	assert.Equal(t, "catalog", apiKey.Name)
+	assert.Equal(t, key[:12], apiKey.Prefix)
+	assert.Equal(t, HashAPIKey(key), apiKey.Hash)
+	assert.NotContains(t, apiKey.Hash, key)
+
+	otherKey, _, _ := NewAPIKey("catalog")
+	assert.NotEqual(t, key, otherKey)
+}
+
+func TestBackendsStoreFindAndRevokeAPIKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		key, apiKey, _ := NewAPIKey("catalog")
+		assert.Equal(t, nil, apiKey.Create(db))
+
+		found, err := FindAPIKeyByHash(db, HashAPIKey(key))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, apiKey.ID, found.ID)
+		assert.False(t, found.Revoked())
+
+		found, err = FindAPIKeyByHash(db, HashAPIKey("bk_unknown"))
+		assert.Equal(t, nil, err)
+		assert.Nil(t, found)
+
+		revoked, err := RevokeAPIKey(db, apiKey.ID)
+		assert.Equal(t, nil, err)
+		assert.True(t, revoked)
+
+		revoked, _ = RevokeAPIKey(db, apiKey.ID)
+		assert.False(t, revoked)
+
+		found, _ = FindAPIKeyByHash(db, HashAPIKey(key))
+		assert.True(t, found.Revoked())
+
+		keys, err := ListAPIKeys(db)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(keys))
+		assert.Equal(t, "catalog", keys[0].Name)
+	})
+}
@@ -0,0 +1,375 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/felipefill/books/migrations"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres" // Postgres dialect for GORM
+	_ "github.com/jinzhu/gorm/dialects/sqlite"   // SQLite dialect for GORM
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// forEachBackend runs test against a fresh SQLite database and, when TEST_POSTGRES_URL is set, against Postgres too
+func forEachBackend(t *testing.T, test func(t *testing.T, db *gorm.DB)) {
+	t.Run("sqlite3", func(t *testing.T) {
+		db, err := gorm.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		// Every connection to :memory: gets its own database
+		db.DB().SetMaxOpenConns(1)
+
+		migrateTestSchema(t, db)
+		test(t, db)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		url := os.Getenv("TEST_POSTGRES_URL")
+		if url == "" {
+			t.Skip("TEST_POSTGRES_URL is not set")
+		}
+
+		db, err := gorm.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		db.DropTableIfExists(&Book{}, &ScrapJob{}, &APIKey{}, &RateLimitBucket{}, "schema_migrations")
+		migrateTestSchema(t, db)
+		test(t, db)
+	})
+}
+
+func migrateTestSchema(t *testing.T, db *gorm.DB) {
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storeOrKeep stores book in db or, when the same book is stored already, fills book with it
+func storeOrKeep(db *gorm.DB, book *Book) error {
+	_, err := book.StoreOrMerge(db, MergePolicy{Strategy: KeepExisting})
+	return err
+}
+
+func TestBackendsStoreOrMergeKeepingExisting(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		storedBook := sampleBook
+		assert.Equal(t, nil, storeOrKeep(db, &storedBook))
+		assert.Equal(t, uint(1), storedBook.ID)
+
+		retrievedBook := Book{Title: sampleBook.Title}
+		assert.Equal(t, nil, storeOrKeep(db, &retrievedBook))
+		assert.Equal(t, storedBook, retrievedBook)
+	})
+}
+
+func TestBackendsEnforceUniqueTitle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		first := sampleBook
+		second := sampleBook
+
+		assert.Equal(t, nil, db.Create(&first).Error)
+		assert.NotEqual(t, nil, db.Create(&second).Error)
+	})
+}
+
+func TestBackendsMatchBooksByISBNThenTitle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		storedBook := Book{Title: "Kotlin in Action", ISBN: null.StringFrom("978-1-61729-329-0")}
+		assert.Equal(t, nil, storeOrKeep(db, &storedBook))
+
+		sameISBN := Book{Title: "Kotlin in Action, 1st edition", ISBN: null.StringFrom("1617293296")}
+		assert.Equal(t, nil, storeOrKeep(db, &sameISBN))
+		assert.Equal(t, storedBook.ID, sameISBN.ID)
+
+		sameTitle := Book{Title: "  kotlin IN action! ", ISBN: null.StringFrom(UnavailableISBN)}
+		assert.Equal(t, nil, storeOrKeep(db, &sameTitle))
+		assert.Equal(t, storedBook.ID, sameTitle.ID)
+
+		// Both are the stored book, which is merged once and counted once
+		summary, err := UpsertBooks(db, []Book{sameISBN, sameTitle, {Title: "Another book"}}, MergePolicy{Strategy: KeepExisting})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, UpsertSummary{Inserted: 1, Unchanged: 1, Duplicates: 1}, summary)
+	})
+}
+
+func TestBackendsEnforceUniqueIdentity(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		first := Book{Title: "Kotlin in Action", ISBN: null.StringFrom("9781617293290")}
+		assert.Equal(t, nil, db.Create(&first).Error)
+
+		sameISBN := Book{Title: "Another title", ISBN: null.StringFrom("978-1617293290")}
+		assert.NotEqual(t, nil, db.Create(&sameISBN).Error)
+
+		sameTitle := Book{Title: "KOTLIN IN ACTION"}
+		assert.NotEqual(t, nil, db.Create(&sameTitle).Error)
+
+		// Books without ISBN don't clash with each other
+		assert.Equal(t, nil, db.Create(&Book{Title: "First", ISBN: null.StringFrom(UnavailableISBN)}).Error)
+		assert.Equal(t, nil, db.Create(&Book{Title: "Second", ISBN: null.StringFrom(UnavailableISBN)}).Error)
+	})
+}
+
+func TestBackendsGetAll(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		actualBooks := Books{}
+		assert.Equal(t, nil, actualBooks.GetAll(db))
+		assert.Equal(t, Books{NumberBooks: 0, Books: []Book{}}, actualBooks)
+
+		storedBook := sampleBook
+		storeOrKeep(db, &storedBook)
+
+		assert.Equal(t, nil, actualBooks.GetAll(db))
+		assert.Equal(t, Books{NumberBooks: 1, Books: []Book{storedBook}}, actualBooks)
+	})
+}
+
+func TestBackendsEachBook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		first, second := Book{Title: "First"}, Book{Title: "Second"}
+		db.Create(&first)
+		db.Create(&second)
+
+		titles := make([]string, 0)
+		err := NewGormBookRepository(db).Each(func(book Book) error {
+			titles = append(titles, book.Title)
+			return nil
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"First", "Second"}, titles)
+
+		err = NewGormBookRepository(db).Each(func(book Book) error {
+			return errors.New("Stop")
+		})
+		assert.EqualError(t, err, "Stop")
+	})
+}
+
+func TestBackendsUpsertBooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		firstSeenAt := time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)
+		lastSeenAt := time.Date(2018, 10, 2, 10, 0, 0, 0, time.UTC)
+
+		scrapped := sampleBook
+		scrapped.Provenance = Provenance{SourceName: null.StringFrom("kotlinlang.org"), FirstSeenAt: null.TimeFrom(firstSeenAt)}
+		created := Book{Title: "Created", Provenance: Provenance{SourceName: null.StringFrom(SourceAPI)}}
+		legacy := Book{Title: "Legacy"}
+
+		summary, err := UpsertBooks(db, []Book{scrapped, created, legacy}, MergePolicy{Strategy: KeepExisting})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, UpsertSummary{Inserted: 3}, summary)
+
+		seen := Provenance{
+			SourceName:  null.StringFrom("kotlinlang.org"),
+			FirstSeenAt: null.TimeFrom(lastSeenAt),
+			LastSeenAt:  null.TimeFrom(lastSeenAt),
+			ContentHash: null.StringFrom("hash"),
+		}
+
+		batch := []Book{{Title: scrapped.Title}, {Title: created.Title}, {Title: legacy.Title}, {Title: "New"}, {Title: "New"}}
+		for index := range batch {
+			batch[index].Provenance = seen
+		}
+
+		summary, err = UpsertBooks(db, batch, MergePolicy{Strategy: KeepExisting})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, UpsertSummary{Inserted: 1, Unchanged: 3, Duplicates: 1}, summary)
+
+		books := Books{}
+		books.GetAll(db)
+		assert.Equal(t, uint(4), books.NumberBooks)
+
+		// Same source keeps when it was first seen
+		assert.Equal(t, sampleBook.Description, books.Books[0].Description)
+		assert.True(t, firstSeenAt.Equal(books.Books[0].FirstSeenAt.Time))
+		assert.True(t, lastSeenAt.Equal(books.Books[0].LastSeenAt.Time))
+
+		// Other source is left untouched
+		assert.Equal(t, null.StringFrom(SourceAPI), books.Books[1].SourceName)
+		assert.False(t, books.Books[1].ContentHash.Valid)
+
+		// No source yet takes the whole provenance
+		assert.True(t, lastSeenAt.Equal(books.Books[2].FirstSeenAt.Time))
+		assert.Equal(t, null.StringFrom("hash"), books.Books[2].ContentHash)
+	})
+}
+
+func TestBackendsUpsertBooksUpdatesEveryColumn(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		seenAt := time.Date(2018, 10, 2, 10, 0, 0, 0, time.UTC)
+
+		withoutISBN := Book{Title: "Without ISBN"}
+		withISBN := Book{Title: "With ISBN", ISBN: null.StringFrom("9781617293290")}
+		assert.Equal(t, nil, storeOrKeep(db, &withoutISBN))
+		assert.Equal(t, nil, storeOrKeep(db, &withISBN))
+
+		seen := Provenance{SourceName: null.StringFrom("kotlinlang.org"), FirstSeenAt: null.TimeFrom(seenAt), LastSeenAt: null.TimeFrom(seenAt)}
+		batch := []Book{
+			{Title: withoutISBN.Title, Description: "Filled", Provenance: seen},
+			{Title: withISBN.Title, Description: "Filled too", Language: "EN", Provenance: seen},
+		}
+
+		// Null ISBNs and timestamps are written along with text, whatever the type Postgres would guess
+		summary, err := UpsertBooks(db, batch, MergePolicy{Strategy: FillEmpty})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, summary.Updated)
+
+		books := Books{}
+		books.GetAll(db)
+		assert.Equal(t, uint(2), books.NumberBooks)
+
+		assert.Equal(t, "Filled", books.Books[0].Description)
+		assert.False(t, books.Books[0].ISBN.Valid)
+		assert.False(t, books.Books[0].NormalizedISBN.Valid)
+		assert.True(t, seenAt.Equal(books.Books[0].LastSeenAt.Time))
+
+		assert.Equal(t, "Filled too", books.Books[1].Description)
+		assert.Equal(t, "EN", books.Books[1].Language)
+		assert.Equal(t, null.StringFrom("9781617293290"), books.Books[1].NormalizedISBN)
+		assert.True(t, seenAt.Equal(books.Books[1].FirstSeenAt.Time))
+	})
+}
+
+func TestBackendsMergeStoredBooks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		stored := sampleBook
+		stored.ISBN = null.StringFrom(UnavailableISBN)
+		assert.Equal(t, nil, storeOrKeep(db, &stored))
+
+		fillEmpty := MergePolicy{Strategy: FillEmpty}
+
+		created := sampleBook
+		created.Description = "Corrected description"
+		result, err := created.StoreOrMerge(db, fillEmpty)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, MergeResult{Changes: []FieldChange{{Field: "isbn", Old: UnavailableISBN, New: sampleBook.ISBN.String}}}, result)
+		assert.Equal(t, sampleBook.Description, created.Description)
+
+		scrapped := sampleBook
+		scrapped.Description = "Corrected description"
+		summary, err := UpsertBooks(db, []Book{scrapped}, MergePolicy{Strategy: Overwrite})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, UpsertSummary{Updated: 1, Changes: []BookChanges{{
+			Title:  sampleBook.Title,
+			Fields: []FieldChange{{Field: "description", Old: sampleBook.Description, New: "Corrected description"}},
+		}}}, summary)
+
+		storedBook, _ := NewGormBookRepository(db).FindByID(stored.ID)
+		assert.Equal(t, "Corrected description", storedBook.Description)
+		assert.Equal(t, sampleBook.ISBN, storedBook.ISBN)
+	})
+}
+
+func TestBackendsUpsertBooksRollsBackEveryBook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		// A title longer than Postgres' varchar(100) fails, SQLite has to be told explicitly
+		db.Exec("CREATE TRIGGER reject_long_titles BEFORE INSERT ON books WHEN length(NEW.title) > 100 BEGIN SELECT RAISE(ABORT, 'title too long'); END")
+
+		tooLong := Book{Title: strings.Repeat("a", 101)}
+
+		_, err := UpsertBooks(db, []Book{sampleBook, tooLong}, MergePolicy{Strategy: KeepExisting})
+		assert.NotEqual(t, nil, err)
+
+		books := Books{}
+		books.GetAll(db)
+		assert.Equal(t, uint(0), books.NumberBooks)
+	})
+}
+
+func TestBackendsFindByID(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		repository := NewGormBookRepository(db)
+
+		storedBook := sampleBook
+		storeOrKeep(db, &storedBook)
+
+		actualBook, actualError := repository.FindByID(storedBook.ID)
+		assert.Equal(t, nil, actualError)
+		assert.Equal(t, &storedBook, actualBook)
+
+		actualBook, actualError = repository.FindByID(storedBook.ID + 1)
+		assert.Equal(t, nil, actualError)
+		assert.Nil(t, actualBook)
+	})
+}
+
+func TestBackendsFailStaleScrapJobs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		alive := ScrapJob{Mode: "scrap_only"}
+		stale := ScrapJob{Mode: "scrap_and_store"}
+		pending := ScrapJob{Mode: "scrap_diff"}
+		for _, job := range []*ScrapJob{&alive, &stale, &pending} {
+			assert.Equal(t, nil, job.Create(db))
+		}
+
+		alive.Start(db)
+		stale.Start(db)
+		db.Model(&ScrapJob{}).Where("id = ?", stale.ID).Update("heartbeat_at", time.Now().Add(-10*time.Minute))
+
+		failed, err := FailStaleScrapJobs(db, 5*time.Minute)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(1), failed)
+
+		foundJob, _ := FindScrapJobByID(db, stale.ID)
+		assert.Equal(t, JobFailed, foundJob.Status)
+		assert.Equal(t, null.StringFrom("Worker stopped responding for more than 5m0s"), foundJob.Error)
+		assert.True(t, foundJob.FinishedAt.Valid)
+
+		foundJob, _ = FindScrapJobByID(db, alive.ID)
+		assert.Equal(t, JobRunning, foundJob.Status)
+
+		foundJob, _ = FindScrapJobByID(db, pending.ID)
+		assert.Equal(t, JobPending, foundJob.Status)
+	})
+}
+
+func TestBackendsScrapJobLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		job := ScrapJob{Mode: "scrap_only"}
+		assert.Equal(t, nil, job.Create(db))
+
+		claimedJob, err := ClaimNextPendingScrapJob(db)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, job.ID, claimedJob.ID)
+		assert.Equal(t, JobRunning, claimedJob.Status)
+
+		claimedJob, err = ClaimNextPendingScrapJob(db)
+		assert.Equal(t, nil, err)
+		assert.Nil(t, claimedJob)
+
+		assert.Equal(t, nil, job.Finish(db, "", errors.New("Something went wrong")))
+
+		foundJob, err := FindScrapJobByID(db, job.ID)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, JobFailed, foundJob.Status)
+		assert.Equal(t, null.StringFrom("Something went wrong"), foundJob.Error)
+		assert.True(t, foundJob.FinishedAt.Valid)
+
+		assert.Equal(t, ErrScrapJobNotRunning, job.Finish(db, "{}", nil))
+
+		foundJob, _ = FindScrapJobByID(db, job.ID)
+		assert.Equal(t, JobFailed, foundJob.Status)
+	})
+}
+
+func TestBackendsScrapJobKeepsMergeAndProvenance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		job := ScrapJob{Mode: "scrap_and_store", Merge: "fill_empty", Provenance: true}
+		assert.Equal(t, nil, job.Create(db))
+
+		claimedJob, err := ClaimNextPendingScrapJob(db)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "fill_empty", claimedJob.Merge)
+		assert.Equal(t, true, claimedJob.Provenance)
+	})
+}
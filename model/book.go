@@ -1,12 +1,21 @@
 package model
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 
+	"github.com/felipefill/books/normalize"
 	"github.com/jinzhu/gorm"
 	null "gopkg.in/guregu/null.v3"
 )
 
+// SourceAPI is the source name of books created through our API
+const SourceAPI = "api"
+
 // Book represents a book record in database
 type Book struct {
 	ID          uint        `gorm:"primary_key" json:"id"`
@@ -14,22 +23,439 @@ type Book struct {
 	Title       string      `gorm:"type:varchar(100);unique_index" json:"title"`
 	Description string      `json:"description"`
 	Language    string      `gorm:"size:2" json:"language"`
+
+	// Provenance is only part of JSON when IncludeProvenance is set
+	Provenance `json:"-"`
+
+	// NormalizedISBN and NormalizedTitle tell which books are the same, they're derived from ISBN and Title
+	// whenever book is saved
+	NormalizedISBN  null.String `gorm:"size:13" json:"-"`
+	NormalizedTitle string      `gorm:"size:100" json:"-"`
+
+	IncludeProvenance bool `gorm:"-" json:"-"`
+}
+
+// Provenance tells where a book came from and when it was seen there
+type Provenance struct {
+	SourceName  null.String `gorm:"size:50" json:"sourceName"`
+	SourceURL   null.String `json:"sourceUrl"`
+	DetailURL   null.String `json:"detailUrl"`
+	FirstSeenAt null.Time   `json:"firstSeenAt"`
+	LastSeenAt  null.Time   `json:"lastSeenAt"`
+	ContentHash null.String `gorm:"size:64" json:"contentHash"`
+}
+
+// UpsertSummary counts what happened to books of a batch. Books that were already stored are updated when merging
+// changed any of their fields and unchanged otherwise, even if their provenance was refreshed. Duplicates are books
+// that were the same as an earlier one of the batch, they are merged into it and counted only there
+type UpsertSummary struct {
+	Inserted   int           `json:"inserted"`
+	Updated    int           `json:"updated"`
+	Unchanged  int           `json:"unchanged"`
+	Duplicates int           `json:"duplicates"`
+	Changes    []BookChanges `json:"changes,omitempty"`
+}
+
+// BookChanges lists fields of a stored book that were changed by a merge
+type BookChanges struct {
+	Title  string        `json:"title"`
+	Fields []FieldChange `json:"fields"`
 }
 
+// upsertBatchSize is how many books are written by a single statement, it keeps statements below the number of
+// parameters Postgres and SQLite accept
+const upsertBatchSize = 500
+
+// bookColumns are columns UpsertBooks writes, in the order of Book.columnValues
+var bookColumns = []string{"isbn", "title", "description", "language", "source_name", "source_url", "detail_url",
+	"first_seen_at", "last_seen_at", "content_hash", "normalized_isbn", "normalized_title"}
+
 // Books represents a collection of books and their count
 type Books struct {
 	NumberBooks uint   `json:"numberBooks"`
 	Books       []Book `json:"books"`
 }
 
-// StoreOrRetrieveByTitle will store book in database or retrieve one with current title
-func (b *Book) StoreOrRetrieveByTitle(db *gorm.DB) error {
-	dbc := db.Where("title = ?", b.Title).Find(&b)
+// MarshalJSON adds provenance to book's JSON when asked to
+func (b Book) MarshalJSON() ([]byte, error) {
+	// book has the same fields but not this method, otherwise json.Marshal would call it forever
+	type book Book
+
+	if !b.IncludeProvenance {
+		return json.Marshal(book(b))
+	}
+
+	return json.Marshal(struct {
+		book
+		Provenance Provenance `json:"provenance"`
+	}{book(b), b.Provenance})
+}
+
+// HashContent returns a hash of book's scrapped fields, it changes whenever any of them does
+func (b *Book) HashContent() string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{b.Title, b.Description, b.ISBN.String, b.Language}, "\x00")))
+	return hex.EncodeToString(hash[:])
+}
+
+// Validate tells whether book has every field a book must have, it lists all missing fields at once
+func (b *Book) Validate() error {
+	missing := make([]string, 0)
+
+	if b.Title == "" {
+		missing = append(missing, "Title cannot be null nor empty")
+	}
+
+	if b.Description == "" {
+		missing = append(missing, "Description cannot be null nor empty")
+	}
+
+	if !b.ISBN.Valid || b.ISBN.String == "" {
+		missing = append(missing, "ISBN cannot be null nor empty")
+	}
+
+	if b.Language == "" {
+		missing = append(missing, "Language cannot be null nor empty")
+	}
+
+	if len(missing) > 0 {
+		return errors.New(strings.Join(missing, "; "))
+	}
+
+	return nil
+}
+
+// BeforeSave keeps NormalizedISBN and NormalizedTitle up to date, GORM calls it before creating or updating book
+func (b *Book) BeforeSave() error {
+	b.identify()
+	return nil
+}
+
+// identify derives NormalizedISBN and NormalizedTitle from ISBN and Title
+func (b *Book) identify() {
+	b.NormalizedTitle = normalize.Title(b.Title)
+
+	b.NormalizedISBN = null.String{}
+	if isbn := normalize.ISBN(b.ISBN.String); isbn != "" {
+		b.NormalizedISBN = null.StringFrom(isbn)
+	}
+}
+
+// findSame fills b with the stored book that is the same as b, it tells whether there was one. Books are the same
+// when they have the same normalized ISBN or, when no book has it, the same normalized title
+func (b *Book) findSame(db *gorm.DB) (bool, error) {
+	b.identify()
+
+	if b.NormalizedISBN.Valid {
+		found, err := b.findBy(db, "normalized_isbn = ?", b.NormalizedISBN.String)
+		if found || err != nil {
+			return found, err
+		}
+	}
+
+	return b.findBy(db, "normalized_title = ?", b.NormalizedTitle)
+}
+
+func (b *Book) findBy(db *gorm.DB, query string, value string) (bool, error) {
+	stored := Book{}
+
+	dbc := db.Where(query, value).Find(&stored)
 	if dbc.RecordNotFound() {
-		return db.Create(b).Error
+		return false, nil
+	} else if dbc.Error != nil {
+		return false, dbc.Error
+	}
+
+	*b = stored
+	return true, nil
+}
+
+// FindSameIn returns the index of the book in books that is the same as book and whether there was one, see
+// Book.findSame
+func FindSameIn(books []Book, book Book) (int, bool) {
+	book.identify()
+
+	if book.NormalizedISBN.Valid {
+		for index, other := range books {
+			if other.identify(); other.NormalizedISBN == book.NormalizedISBN {
+				return index, true
+			}
+		}
+	}
+
+	for index, other := range books {
+		if other.identify(); other.NormalizedTitle == book.NormalizedTitle {
+			return index, true
+		}
+	}
+
+	return -1, false
+}
+
+// StoreOrMerge will store book in database or, when the same book is stored already, merge book into it according to
+// policy. Either way book is filled with what's stored afterwards
+func (b *Book) StoreOrMerge(db *gorm.DB, policy MergePolicy) (MergeResult, error) {
+	incoming := *b
+
+	found, err := b.findSame(db)
+	if err != nil {
+		return MergeResult{}, err
+	} else if !found {
+		return MergeResult{Created: true, Changes: []FieldChange{}}, db.Create(b).Error
+	}
+
+	changes := b.Merge(incoming, policy)
+	if len(changes) == 0 {
+		return MergeResult{Changes: changes}, nil
+	}
+
+	b.identify()
+	err = db.Model(b).Updates(map[string]interface{}{
+		"description":     b.Description,
+		"isbn":            b.ISBN,
+		"language":        b.Language,
+		"normalized_isbn": b.NormalizedISBN,
+	}).Error
+
+	return MergeResult{Changes: changes}, err
+}
+
+// UpsertBooks stores books in a single transaction. Books already stored (see Book.findSame) are merged with the new
+// ones according to policy and have their provenance refreshed (see refreshProvenance), merging happens
+// here so stored fields, like when a book was first seen, are only changed the way policy says. New books are inserted
+// in batches and merged books updated one by one. Books someone else inserted meanwhile, with the same title or ISBN, are merged
+// the same way. Nothing is stored when any book fails
+func UpsertBooks(db *gorm.DB, books []Book, policy MergePolicy) (UpsertSummary, error) {
+	summary := UpsertSummary{}
+	if len(books) == 0 {
+		return summary, nil
+	}
+
+	tx := db.Begin()
+	if tx.Error != nil {
+		return summary, tx.Error
+	}
+
+	summary, err := upsertBooks(tx, books, policy)
+	if err != nil {
+		tx.Rollback()
+		return UpsertSummary{}, fmt.Errorf("Failed to store books: %s", err.Error())
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return UpsertSummary{}, err
+	}
+
+	return summary, nil
+}
+
+func upsertBooks(tx *gorm.DB, books []Book, policy MergePolicy) (UpsertSummary, error) {
+	summary := UpsertSummary{}
+
+	storedBooks, err := findSameBooks(tx, books)
+	if err != nil {
+		return summary, err
+	}
+
+	changed := make([]bool, len(storedBooks))
+	merged := make([]bool, len(storedBooks))
+	newBooks := make([]Book, 0)
+	for _, book := range books {
+		if index, found := FindSameIn(storedBooks, book); found {
+			changes, write := mergeStored(&storedBooks[index], book, policy)
+			changed[index] = write || changed[index]
+
+			if merged[index] {
+				summary.Duplicates++
+			} else {
+				summary.count(storedBooks[index].Title, changes)
+			}
+			merged[index] = true
+		} else if index, found := FindSameIn(newBooks, book); found {
+			mergeStored(&newBooks[index], book, policy)
+			summary.Duplicates++
+		} else {
+			book.identify()
+			newBooks = append(newBooks, book)
+		}
+	}
+
+	conflicting, err := insertBooks(tx, newBooks)
+	if err != nil {
+		return summary, err
+	}
+
+	summary.Inserted = len(newBooks) - len(conflicting)
+
+	updates := make([]Book, 0)
+	for index, book := range storedBooks {
+		if changed[index] {
+			updates = append(updates, book)
+		}
+	}
+
+	if len(conflicting) > 0 {
+		insertedMeanwhile, err := findSameBooks(tx, conflicting)
+		if err != nil {
+			return summary, err
+		}
+
+		for _, book := range conflicting {
+			index, found := FindSameIn(insertedMeanwhile, book)
+			if !found {
+				return summary, fmt.Errorf("Book %s could neither be inserted nor found", book.Title)
+			}
+
+			changes, write := mergeStored(&insertedMeanwhile[index], book, policy)
+			summary.count(insertedMeanwhile[index].Title, changes)
+			if write {
+				updates = append(updates, insertedMeanwhile[index])
+			}
+		}
+	}
+
+	return summary, updateBooks(tx, updates)
+}
+
+// findSameBooks retrieves stored books that are the same as any of books, see Book.findSame
+func findSameBooks(tx *gorm.DB, books []Book) ([]Book, error) {
+	isbns := make([]string, 0, len(books))
+	titles := make([]string, 0, len(books))
+	for _, book := range books {
+		book.identify()
+		if book.NormalizedISBN.Valid {
+			isbns = append(isbns, book.NormalizedISBN.String)
+		}
+
+		titles = append(titles, book.NormalizedTitle)
+	}
+
+	var storedBooks []Book
+	err := tx.Where("normalized_isbn IN (?) OR normalized_title IN (?)", isbns, titles).Order("id").Find(&storedBooks).Error
+
+	return storedBooks, err
+}
+
+// mergeStored merges book into stored according to policy and refreshes its provenance, it returns the fields that
+// changed and tells whether anything has to be written
+func mergeStored(stored *Book, book Book, policy MergePolicy) ([]FieldChange, bool) {
+	changes := stored.Merge(book, policy)
+	refreshed := stored.refreshProvenance(book.Provenance)
+
+	stored.identify()
+	return changes, len(changes) > 0 || refreshed
+}
+
+// columnValues returns values of bookColumns
+func (b *Book) columnValues() []interface{} {
+	return []interface{}{b.ISBN, b.Title, b.Description, b.Language, b.SourceName, b.SourceURL, b.DetailURL,
+		b.FirstSeenAt, b.LastSeenAt, b.ContentHash, b.NormalizedISBN, b.NormalizedTitle}
+}
+
+// insertBooks inserts books in batches and returns those that weren't because the same book, by title or ISBN, was
+// inserted meanwhile. Both Postgres and SQLite understand these statements
+func insertBooks(tx *gorm.DB, books []Book) ([]Book, error) {
+	conflicting := make([]Book, 0)
+
+	for start := 0; start < len(books); start += upsertBatchSize {
+		batch := books[start:minInt(start+upsertBatchSize, len(books))]
+
+		row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(bookColumns)), ", ") + ")"
+		values := make([]interface{}, 0, len(batch)*len(bookColumns))
+		for _, book := range batch {
+			values = append(values, book.columnValues()...)
+		}
+
+		query := fmt.Sprintf("INSERT INTO books (%s) VALUES %s ON CONFLICT DO NOTHING RETURNING normalized_title",
+			strings.Join(bookColumns, ", "), strings.TrimSuffix(strings.Repeat(row+", ", len(batch)), ", "))
+
+		rows, err := tx.Raw(query, values...).Rows()
+		if err != nil {
+			return conflicting, err
+		}
+
+		inserted := make(map[string]bool)
+		for rows.Next() {
+			var title string
+			if err := rows.Scan(&title); err != nil {
+				rows.Close()
+				return conflicting, err
+			}
+
+			inserted[title] = true
+		}
+
+		if err := rows.Close(); err != nil {
+			return conflicting, err
+		}
+
+		for _, book := range batch {
+			if !inserted[book.NormalizedTitle] {
+				conflicting = append(conflicting, book)
+			}
+		}
+	}
+
+	return conflicting, nil
+}
+
+// updateBooks writes every column of books, found by ID, one statement each. Parameters get the type of the column
+// they're assigned to, which Postgres can't tell for values of a CASE
+func updateBooks(tx *gorm.DB, books []Book) error {
+	assignments := make([]string, 0, len(bookColumns))
+	for _, name := range bookColumns {
+		assignments = append(assignments, name+" = ?")
+	}
+
+	query := fmt.Sprintf("UPDATE books SET %s WHERE id = ?", strings.Join(assignments, ", "))
+	for _, book := range books {
+		if err := tx.Exec(query, append(book.columnValues(), book.ID)...).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
 	}
 
-	return dbc.Error
+	return b
+}
+
+// count records what merging a stored book did
+func (s *UpsertSummary) count(title string, changes []FieldChange) {
+	if len(changes) == 0 {
+		s.Unchanged++
+		return
+	}
+
+	s.Updated++
+	s.Changes = append(s.Changes, BookChanges{Title: title, Fields: changes})
+}
+
+// refreshProvenance applies provenance of a new sighting to stored book, it tells whether anything was applied
+func (b *Book) refreshProvenance(seen Provenance) bool {
+	switch {
+	case !b.SourceName.Valid:
+		// Book was stored before provenance existed, it's considered seen for the first time
+		b.Provenance = seen
+	case b.SourceName == seen.SourceName:
+		seen.FirstSeenAt = b.FirstSeenAt
+		b.Provenance = seen
+	default:
+		return false
+	}
+
+	return true
+}
+
+// ShowProvenance makes every book include its provenance in JSON
+func (b *Books) ShowProvenance() {
+	for index := range b.Books {
+		b.Books[index].IncludeProvenance = true
+	}
 }
 
 // GetAll retrieve all books from database
@@ -51,3 +477,26 @@ func (b *Books) GetAll(db *gorm.DB) error {
 
 	return nil
 }
+
+// EachBook calls fn with every book in database ordered by ID, books are read one at a time so that they never are
+// all in memory. It stops at the first error fn returns
+func EachBook(db *gorm.DB, fn func(Book) error) error {
+	rows, err := db.Model(&Book{}).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		book := Book{}
+		if err := db.ScanRows(rows, &book); err != nil {
+			return err
+		}
+
+		if err := fn(book); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// BookRepository stores and retrieves books, handlers receive one instead of talking to the database directly
+type BookRepository interface {
+	// FindByID retrieves book with given ID, it returns nil when there's none
+	FindByID(id uint) (*Book, error)
+
+	// GetAll retrieves every book
+	GetAll() (*Books, error)
+
+	// Each calls fn with every book ordered by ID, it stops at the first error fn returns
+	Each(fn func(Book) error) error
+
+	// StoreOrMerge stores book or merges it into the same stored book, see Book.StoreOrMerge
+	StoreOrMerge(book *Book, policy MergePolicy) (MergeResult, error)
+
+	// UpsertBooks stores books all at once, or none of them when any fails, see UpsertBooks
+	UpsertBooks(books []Book, policy MergePolicy) (UpsertSummary, error)
+}
+
+// GormBookRepository is a BookRepository backed by a GORM database
+type GormBookRepository struct {
+	db *gorm.DB
+}
+
+// NewGormBookRepository creates a BookRepository that uses given database
+func NewGormBookRepository(db *gorm.DB) *GormBookRepository {
+	return &GormBookRepository{db: db}
+}
+
+// FindByID retrieves book with given ID, it returns nil when there's none
+func (r *GormBookRepository) FindByID(id uint) (*Book, error) {
+	book := Book{}
+
+	dbc := r.db.Where("id = ?", id).Find(&book)
+	if dbc.RecordNotFound() {
+		return nil, nil
+	}
+
+	if dbc.Error != nil {
+		return nil, dbc.Error
+	}
+
+	return &book, nil
+}
+
+// GetAll retrieves every book
+func (r *GormBookRepository) GetAll() (*Books, error) {
+	books := Books{}
+	if err := books.GetAll(r.db); err != nil {
+		return nil, err
+	}
+
+	return &books, nil
+}
+
+// Each calls fn with every book ordered by ID, books are streamed from database
+func (r *GormBookRepository) Each(fn func(Book) error) error {
+	return EachBook(r.db, fn)
+}
+
+// StoreOrMerge stores book or merges it into the same stored book
+func (r *GormBookRepository) StoreOrMerge(book *Book, policy MergePolicy) (MergeResult, error) {
+	return book.StoreOrMerge(r.db, policy)
+}
+
+// UpsertBooks stores books in a single transaction
+func (r *GormBookRepository) UpsertBooks(books []Book, policy MergePolicy) (UpsertSummary, error) {
+	return UpsertBooks(r.db, books, policy)
+}
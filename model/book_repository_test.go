@@ -0,0 +1,100 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGormBookRepositoryFindByIDFindsBook(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	expectedBook := sampleBook
+	expectedBook.ID = 22
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(22).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "isbn", "language"}).
+			AddRow(expectedBook.ID, expectedBook.Title, expectedBook.Description, expectedBook.ISBN.String, expectedBook.Language),
+		)
+
+	actualBook, actualError := NewGormBookRepository(gormDB).FindByID(22)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, &expectedBook, actualBook)
+}
+
+func TestGormBookRepositoryFindByIDDoesNotFindBook(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(22).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	var expectedBook *Book
+	actualBook, actualError := NewGormBookRepository(gormDB).FindByID(22)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, expectedBook, actualBook)
+}
+
+func TestGormBookRepositoryFindByIDFails(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(22).
+		WillReturnError(errors.New("database error"))
+
+	var expectedBook *Book
+	actualBook, actualError := NewGormBookRepository(gormDB).FindByID(22)
+
+	assert.Equal(t, errors.New("database error"), actualError)
+	assert.Equal(t, expectedBook, actualBook)
+}
+
+func TestGormBookRepositoryGetAll(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	expectedBook := sampleBook
+	expectedBook.ID = 1
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\"").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "isbn", "language"}).
+			AddRow(expectedBook.ID, expectedBook.Title, expectedBook.Description, expectedBook.ISBN.String, expectedBook.Language),
+		)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\"").
+		WillReturnError(errors.New("database error"))
+
+	repository := NewGormBookRepository(gormDB)
+
+	actualBooks, actualError := repository.GetAll()
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, &Books{NumberBooks: 1, Books: []Book{expectedBook}}, actualBooks)
+
+	actualBooks, actualError = repository.GetAll()
+
+	assert.Equal(t, errors.New("Failed to retrieve books from database"), actualError)
+	assert.Nil(t, actualBooks)
+}
@@ -1,15 +1,18 @@
 package model
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
 )
 
-func TestStoreOrRetrieveByTitleRetrieve(t *testing.T) {
+func TestStoreOrMergeKeepingExistingRetrievesStoredBook(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
@@ -20,39 +23,47 @@ func TestStoreOrRetrieveByTitleRetrieve(t *testing.T) {
 
 	mock.
 		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(expectedBook.Title).
+		WithArgs(expectedBook.ISBN.String).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "description", "isbn", "language"}).
 			AddRow(expectedBook.ID, expectedBook.Title, expectedBook.Description, expectedBook.ISBN.String, expectedBook.Language),
 		)
 
 	actualBook := Book{
 		Title: sampleBook.Title,
+		ISBN:  null.StringFrom("978-1-61729-329-0"),
 	}
 
-	actualError := actualBook.StoreOrRetrieveByTitle(gormDB)
+	actualResult, actualError := actualBook.StoreOrMerge(gormDB, MergePolicy{Strategy: KeepExisting})
 
 	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, MergeResult{Changes: []FieldChange{}}, actualResult)
 	assert.Equal(t, expectedBook, actualBook)
 }
 
-func TestStoreOrRetrieveByTitleStore(t *testing.T) {
+func TestStoreOrMergeStoresNewBook(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	gormDB, _ := gorm.Open("postgres", db)
 
 	var expectedError error
-	expectedBook := sampleBook
+	expectedBook := identified(sampleBook)
 	expectedBook.ID = 1
 
 	mock.
-		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(expectedBook.Title).
+		ExpectQuery("SELECT (.+) FROM \"books\" WHERE \\(normalized_isbn = \\$1\\)(.*)").
+		WithArgs(expectedBook.ISBN.String).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" WHERE \\(normalized_title = \\$1\\)(.*)").
+		WithArgs("book title example").
 		WillReturnError(gorm.ErrRecordNotFound)
 
 	mock.
-		ExpectQuery("INSERT INTO \"books\" \\(\"isbn\",\"title\",\"description\",\"language\"\\)").
-		WithArgs(expectedBook.ISBN.String, expectedBook.Title, expectedBook.Description, expectedBook.Language).
+		ExpectQuery("INSERT INTO \"books\" \\(\"isbn\",\"title\",\"description\",\"language\",\"source_name\",(.+)\\)").
+		WithArgs(expectedBook.ISBN.String, expectedBook.Title, expectedBook.Description, expectedBook.Language, nil, nil, nil, nil, nil, nil,
+			expectedBook.ISBN.String, "book title example").
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 
 	actualBook := Book{
@@ -62,11 +73,12 @@ func TestStoreOrRetrieveByTitleStore(t *testing.T) {
 		ISBN:        sampleBook.ISBN,
 	}
 
-	actualError := actualBook.StoreOrRetrieveByTitle(gormDB)
+	actualResult, actualError := actualBook.StoreOrMerge(gormDB, MergePolicy{Strategy: KeepExisting})
 
 	sampleBook.ID = 0
 
 	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, MergeResult{Created: true, Changes: []FieldChange{}}, actualResult)
 	assert.Equal(t, expectedBook, actualBook)
 }
 
@@ -137,3 +149,115 @@ func TestGetAllRetrievesBook(t *testing.T) {
 	assert.Equal(t, expectedError, actualError)
 	assert.Equal(t, expectedBooks, actualBooks)
 }
+
+func TestUpsertBooksRollsBackWhenABookFails(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	other := sampleBook
+	other.Title = "Other book title"
+	other.ISBN = null.StringFrom("9780804429573")
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(sampleBook.ISBN.String, other.ISBN.String, "book title example", "other book title").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+	mock.
+		ExpectQuery("INSERT INTO books (.+) VALUES (.+), (.+) ON CONFLICT DO NOTHING RETURNING normalized_title").
+		WithArgs(sampleBook.ISBN.String, sampleBook.Title, sampleBook.Description, sampleBook.Language, nil, nil, nil, nil, nil, nil,
+			sampleBook.ISBN.String, "book title example",
+			other.ISBN.String, other.Title, other.Description, other.Language, nil, nil, nil, nil, nil, nil,
+			other.ISBN.String, "other book title").
+		WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	summary, err := UpsertBooks(gormDB, []Book{sampleBook, other}, MergePolicy{Strategy: KeepExisting})
+
+	assert.EqualError(t, err, "Failed to store books: some error")
+	assert.Equal(t, UpsertSummary{}, summary)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestUpsertBooksMergesBooksInsertedMeanwhile(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	firstSeenAt := time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)
+	seenAt := time.Date(2018, 10, 2, 10, 0, 0, 0, time.UTC)
+
+	scrapped := sampleBook
+	scrapped.Provenance = Provenance{SourceName: null.StringFrom("kotlinlang.org"), FirstSeenAt: null.TimeFrom(seenAt), LastSeenAt: null.TimeFrom(seenAt)}
+
+	// Someone else stored the same book, with another title but the same ISBN, after it was looked for
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(sampleBook.ISBN.String, "book title example").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.
+		ExpectQuery("INSERT INTO books (.+) ON CONFLICT DO NOTHING RETURNING normalized_title").
+		WillReturnRows(sqlmock.NewRows([]string{"normalized_title"}))
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WithArgs(sampleBook.ISBN.String, "book title example").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "description", "language", "source_name", "first_seen_at", "normalized_isbn", "normalized_title"}).
+			AddRow(7, sampleBook.ISBN.String, "Book title example (2nd edition)", "", "BR", "kotlinlang.org", firstSeenAt, sampleBook.ISBN.String, "book title example 2nd edition"))
+	mock.
+		ExpectExec("UPDATE books SET isbn = \\$1, (.+) WHERE id = \\$13").
+		WithArgs(sampleBook.ISBN.String, "Book title example (2nd edition)", sampleBook.Description, "BR",
+			"kotlinlang.org", nil, nil, firstSeenAt, seenAt, nil, sampleBook.ISBN.String, "book title example 2nd edition", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	summary, err := UpsertBooks(gormDB, []Book{scrapped}, MergePolicy{Strategy: FillEmpty})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, UpsertSummary{Updated: 1, Changes: []BookChanges{{
+		Title:  "Book title example (2nd edition)",
+		Fields: []FieldChange{{Field: "description", Old: "", New: sampleBook.Description}},
+	}}}, summary)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestUpsertBooksDoesNothingWithoutBooks(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	summary, err := UpsertBooks(gormDB, []Book{}, MergePolicy{Strategy: KeepExisting})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, UpsertSummary{}, summary)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestBookMarshalJSON(t *testing.T) {
+	book := sampleBook
+	book.SourceName = null.StringFrom(SourceAPI)
+
+	withoutProvenance, _ := json.Marshal(book)
+	assert.Equal(t, `{"id":0,"isbn":"9781617293290","title":"Book title example","description":"Book description example","language":"BR"}`, string(withoutProvenance))
+
+	book.IncludeProvenance = true
+
+	withProvenance, _ := json.Marshal(book)
+	assert.Equal(t, `{"id":0,"isbn":"9781617293290","title":"Book title example","description":"Book description example","language":"BR",`+
+		`"provenance":{"sourceName":"api","sourceUrl":null,"detailUrl":null,"firstSeenAt":null,"lastSeenAt":null,"contentHash":null}}`, string(withProvenance))
+}
+
+func TestHashContent(t *testing.T) {
+	book := sampleBook
+	hash := book.HashContent()
+
+	assert.Equal(t, 64, len(hash))
+	assert.Equal(t, hash, book.HashContent())
+
+	book.Description = "Another description"
+	assert.NotEqual(t, hash, book.HashContent())
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"sort"
+	"sync"
+)
+
+// InMemoryBookRepository is a BookRepository that keeps books in memory, it's meant for tests and local mode
+// and is safe for concurrent use
+type InMemoryBookRepository struct {
+	lock   sync.RWMutex
+	books  map[uint]Book
+	lastID uint
+}
+
+// NewInMemoryBookRepository creates an InMemoryBookRepository holding given books, books without ID are given one
+func NewInMemoryBookRepository(books ...Book) *InMemoryBookRepository {
+	r := &InMemoryBookRepository{books: make(map[uint]Book)}
+
+	for _, book := range books {
+		if book.ID == 0 {
+			r.store(&book)
+			continue
+		}
+
+		r.books[book.ID] = book
+		if book.ID > r.lastID {
+			r.lastID = book.ID
+		}
+	}
+
+	return r
+}
+
+// FindByID retrieves book with given ID, it returns nil when there's none
+func (r *InMemoryBookRepository) FindByID(id uint) (*Book, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	book, ok := r.books[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &book, nil
+}
+
+// GetAll retrieves every book ordered by ID
+func (r *InMemoryBookRepository) GetAll() (*Books, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	books := r.sorted()
+	return &Books{NumberBooks: uint(len(books)), Books: books}, nil
+}
+
+// Each calls fn with every book ordered by ID, fn may use the repository since no lock is held while it runs
+func (r *InMemoryBookRepository) Each(fn func(Book) error) error {
+	books, _ := r.GetAll()
+
+	for _, book := range books.Books {
+		if err := fn(book); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StoreOrMerge stores book or merges it into the same stored book
+func (r *InMemoryBookRepository) StoreOrMerge(book *Book, policy MergePolicy) (MergeResult, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	stored, ok := r.findSame(*book)
+	if !ok {
+		r.store(book)
+		return MergeResult{Created: true, Changes: []FieldChange{}}, nil
+	}
+
+	changes := stored.Merge(*book, policy)
+	r.books[stored.ID] = stored
+
+	*book = stored
+	return MergeResult{Changes: changes}, nil
+}
+
+// UpsertBooks merges books into the same stored ones or stores them, it can't fail midway
+func (r *InMemoryBookRepository) UpsertBooks(books []Book, policy MergePolicy) (UpsertSummary, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	summary := UpsertSummary{}
+	merged := make(map[uint]bool)
+	for _, book := range books {
+		stored, ok := r.findSame(book)
+		if !ok {
+			r.store(&book)
+			summary.Inserted++
+			merged[book.ID] = true
+			continue
+		}
+
+		changes := stored.Merge(book, policy)
+		stored.refreshProvenance(book.Provenance)
+		r.books[stored.ID] = stored
+
+		if merged[stored.ID] {
+			summary.Duplicates++
+		} else {
+			summary.count(stored.Title, changes)
+		}
+		merged[stored.ID] = true
+	}
+
+	return summary, nil
+}
+
+// sorted returns every book ordered by ID. Lock must be held
+func (r *InMemoryBookRepository) sorted() []Book {
+	books := make([]Book, 0, len(r.books))
+	for _, book := range r.books {
+		books = append(books, book)
+	}
+
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+
+	return books
+}
+
+// findSame retrieves the stored book that is the same as book, see Book.findSame. Books are looked at in ID order,
+// so that the same one is found every time when several match. Lock must be held
+func (r *InMemoryBookRepository) findSame(book Book) (Book, bool) {
+	books := r.sorted()
+
+	index, ok := FindSameIn(books, book)
+	if !ok {
+		return Book{}, false
+	}
+
+	return books[index], true
+}
+
+// store gives book the next ID and keeps a copy of it, lock must be held
+func (r *InMemoryBookRepository) store(book *Book) {
+	r.lastID++
+	book.ID = r.lastID
+	r.books[book.ID] = *book
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// storeOrKeepIn stores book in repository or, when the same book is stored already, fills book with it
+func storeOrKeepIn(repository *InMemoryBookRepository, book *Book) error {
+	_, err := repository.StoreOrMerge(book, MergePolicy{Strategy: KeepExisting})
+	return err
+}
+
+func TestInMemoryBookRepositoryStoreOrMergeRetrievesStoredBook(t *testing.T) {
+	repository := NewInMemoryBookRepository()
+
+	storedBook := sampleBook
+	assert.Equal(t, nil, storeOrKeepIn(repository, &storedBook))
+	assert.Equal(t, uint(1), storedBook.ID)
+
+	retrievedBook := Book{Title: sampleBook.Title}
+	assert.Equal(t, nil, storeOrKeepIn(repository, &retrievedBook))
+	assert.Equal(t, storedBook, retrievedBook)
+
+	actualBooks, _ := repository.GetAll()
+	assert.Equal(t, &Books{NumberBooks: 1, Books: []Book{storedBook}}, actualBooks)
+}
+
+func TestInMemoryBookRepositoryMatchesBooksByISBNThenTitle(t *testing.T) {
+	repository := NewInMemoryBookRepository(
+		Book{ID: 1, Title: "Kotlin in Action", ISBN: null.StringFrom("9781617293290")},
+		Book{ID: 2, Title: "Kotlin Cookbook"},
+	)
+
+	sameISBN := Book{Title: "Kotlin Cookbook", ISBN: null.StringFrom("1617293296")}
+	storeOrKeepIn(repository, &sameISBN)
+	assert.Equal(t, uint(1), sameISBN.ID)
+
+	sameTitle := Book{Title: "kotlin: cookbook"}
+	storeOrKeepIn(repository, &sameTitle)
+	assert.Equal(t, uint(2), sameTitle.ID)
+}
+
+func TestInMemoryBookRepositoryMatchesLowestIDFirst(t *testing.T) {
+	for attempt := 0; attempt < 20; attempt++ {
+		repository := NewInMemoryBookRepository(
+			Book{ID: 3, Title: "Kotlin in Action!"},
+			Book{ID: 1, Title: "Kotlin in action"},
+			Book{ID: 2, Title: "KOTLIN IN ACTION"},
+		)
+
+		book := Book{Title: "Kotlin in Action"}
+		assert.Equal(t, nil, storeOrKeepIn(repository, &book))
+		assert.Equal(t, uint(1), book.ID)
+	}
+}
+
+func TestInMemoryBookRepositoryFindByID(t *testing.T) {
+	book := sampleBook
+	book.ID = 7
+
+	repository := NewInMemoryBookRepository(book)
+
+	actualBook, actualError := repository.FindByID(7)
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, &book, actualBook)
+
+	actualBook, actualError = repository.FindByID(8)
+	assert.Equal(t, nil, actualError)
+	assert.Nil(t, actualBook)
+
+	// Books stored later don't reuse seeded IDs
+	newBook := Book{Title: "Another book"}
+	storeOrKeepIn(repository, &newBook)
+	assert.Equal(t, uint(8), newBook.ID)
+}
+
+func TestInMemoryBookRepositoryGetAllIsOrderedByID(t *testing.T) {
+	repository := NewInMemoryBookRepository(Book{ID: 3, Title: "C"}, Book{ID: 1, Title: "A"}, Book{ID: 2, Title: "B"})
+
+	actualBooks, _ := repository.GetAll()
+
+	assert.Equal(t, uint(3), actualBooks.NumberBooks)
+	assert.Equal(t, "A", actualBooks.Books[0].Title)
+	assert.Equal(t, "B", actualBooks.Books[1].Title)
+	assert.Equal(t, "C", actualBooks.Books[2].Title)
+}
+
+func TestInMemoryBookRepositoryUpsertBooksRefreshesProvenance(t *testing.T) {
+	firstSeenAt := null.TimeFrom(time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC))
+	lastSeenAt := null.TimeFrom(time.Date(2018, 10, 2, 10, 0, 0, 0, time.UTC))
+
+	scrapped := Book{ID: 1, Title: "Scrapped", Provenance: Provenance{SourceName: null.StringFrom("kotlinlang.org"), FirstSeenAt: firstSeenAt}}
+	created := Book{ID: 2, Title: "Created", Provenance: Provenance{SourceName: null.StringFrom(SourceAPI)}}
+
+	repository := NewInMemoryBookRepository(scrapped, created)
+
+	seenAgain := Book{Title: "Scrapped", Provenance: Provenance{
+		SourceName:  null.StringFrom("kotlinlang.org"),
+		FirstSeenAt: lastSeenAt,
+		LastSeenAt:  lastSeenAt,
+		ContentHash: null.StringFrom("hash"),
+	}}
+	_, err := repository.UpsertBooks([]Book{seenAgain}, MergePolicy{Strategy: KeepExisting})
+	assert.Equal(t, nil, err)
+
+	storedBook, _ := repository.FindByID(1)
+	assert.Equal(t, firstSeenAt, storedBook.FirstSeenAt)
+	assert.Equal(t, lastSeenAt, storedBook.LastSeenAt)
+	assert.Equal(t, null.StringFrom("hash"), storedBook.ContentHash)
+
+	seenElsewhere := Book{Title: "Created", Provenance: Provenance{SourceName: null.StringFrom("kotlinlang.org")}}
+	_, err = repository.UpsertBooks([]Book{seenElsewhere}, MergePolicy{Strategy: KeepExisting})
+	assert.Equal(t, nil, err)
+
+	storedBook, _ = repository.FindByID(2)
+	assert.Equal(t, created, *storedBook)
+}
+
+func TestInMemoryBookRepositoryUpsertBooks(t *testing.T) {
+	scrapped := Book{ID: 1, Title: "Scrapped", Provenance: Provenance{SourceName: null.StringFrom("kotlinlang.org")}}
+	repository := NewInMemoryBookRepository(scrapped)
+
+	seen := Provenance{SourceName: null.StringFrom("kotlinlang.org"), ContentHash: null.StringFrom("hash")}
+	summary, err := repository.UpsertBooks([]Book{{Title: "Scrapped", Provenance: seen}, {Title: "New", Provenance: seen}}, MergePolicy{Strategy: KeepExisting})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, UpsertSummary{Inserted: 1, Unchanged: 1}, summary)
+
+	storedBook, _ := repository.FindByID(1)
+	assert.Equal(t, null.StringFrom("hash"), storedBook.ContentHash)
+
+	storedBook, _ = repository.FindByID(2)
+	assert.Equal(t, "New", storedBook.Title)
+}
+
+func TestInMemoryBookRepositoryUpsertBooksCountsDuplicatesOnce(t *testing.T) {
+	repository := NewInMemoryBookRepository(Book{ID: 1, Title: "Scrapped"})
+
+	books := []Book{{Title: "Scrapped"}, {Title: "scrapped!"}, {Title: "New"}, {Title: "New", Description: "Description"}}
+	summary, err := repository.UpsertBooks(books, MergePolicy{Strategy: FillEmpty})
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, UpsertSummary{Inserted: 1, Unchanged: 1, Duplicates: 2}, summary)
+
+	storedBook, _ := repository.FindByID(2)
+	assert.Equal(t, "Description", storedBook.Description)
+}
+
+func TestInMemoryBookRepositoryIsSafeForConcurrentUse(t *testing.T) {
+	repository := NewInMemoryBookRepository()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			book := Book{Title: fmt.Sprintf("Book %d", i%10)}
+			storeOrKeepIn(repository, &book)
+			repository.GetAll()
+		}(i)
+	}
+	wg.Wait()
+
+	actualBooks, _ := repository.GetAll()
+	assert.Equal(t, uint(10), actualBooks.NumberBooks)
+}
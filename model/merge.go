@@ -0,0 +1,163 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	null "gopkg.in/guregu/null.v3"
+)
+
+// UnavailableISBN is the ISBN of books whose detail page didn't have one
+const UnavailableISBN = "Unavailable"
+
+// MergeStrategy tells what happens to a stored book when the same book is stored again, see Book.findSame
+type MergeStrategy string
+
+const (
+	// KeepExisting leaves stored book untouched
+	KeepExisting MergeStrategy = "keep_existing"
+
+	// Overwrite replaces stored fields with new values, empty new values never replace anything
+	Overwrite MergeStrategy = "overwrite"
+
+	// FillEmpty only sets fields that are empty in stored book, an unavailable ISBN counts as empty
+	FillEmpty MergeStrategy = "fill_empty"
+
+	// PreferSource overwrites when new book's source has the same or a higher priority than stored book's, it
+	// only fills empty fields otherwise
+	PreferSource MergeStrategy = "prefer_source"
+)
+
+var mergeStrategies = []MergeStrategy{KeepExisting, Overwrite, FillEmpty, PreferSource}
+
+// ParseMergeStrategy converts value into a MergeStrategy, it fails when there's no such strategy
+func ParseMergeStrategy(value string) (MergeStrategy, error) {
+	names := make([]string, 0, len(mergeStrategies))
+	for _, strategy := range mergeStrategies {
+		if string(strategy) == value {
+			return strategy, nil
+		}
+
+		names = append(names, string(strategy))
+	}
+
+	return "", fmt.Errorf("Merge strategy must be one of %s or %s", strings.Join(names[:len(names)-1], ", "), names[len(names)-1])
+}
+
+// MergePolicy tells how a stored book is merged with a new one
+type MergePolicy struct {
+	Strategy MergeStrategy
+
+	// SourcePriority lists source names from highest to lowest priority, sources not listed come last
+	SourcePriority []string
+}
+
+// NewMergePolicyFromEnv creates a MergePolicy from MERGE_STRATEGY and MERGE_SOURCE_PRIORITY (comma separated), by
+// default stored books are kept and books created through our API take precedence over any other source
+func NewMergePolicyFromEnv() MergePolicy {
+	strategy, err := ParseMergeStrategy(os.Getenv("MERGE_STRATEGY"))
+	if err != nil {
+		strategy = KeepExisting
+	}
+
+	priority := []string{SourceAPI}
+	if value := os.Getenv("MERGE_SOURCE_PRIORITY"); value != "" {
+		priority = make([]string, 0)
+		for _, source := range strings.Split(value, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				priority = append(priority, source)
+			}
+		}
+	}
+
+	return MergePolicy{Strategy: strategy, SourcePriority: priority}
+}
+
+// MergePolicyFromQuery creates a MergePolicy from environment, see NewMergePolicyFromEnv, with the strategy named by
+// the merge query string parameter when there's one. It fails when there's no such strategy
+func MergePolicyFromQuery(query map[string]string) (MergePolicy, error) {
+	policy := NewMergePolicyFromEnv()
+
+	value, ok := query["merge"]
+	if !ok {
+		return policy, nil
+	}
+
+	strategy, err := ParseMergeStrategy(value)
+	if err != nil {
+		return policy, err
+	}
+
+	policy.Strategy = strategy
+	return policy, nil
+}
+
+// rank returns source's position in SourcePriority, lower is better
+func (p MergePolicy) rank(source null.String) int {
+	for index, name := range p.SourcePriority {
+		if source.Valid && source.String == name {
+			return index
+		}
+	}
+
+	return len(p.SourcePriority)
+}
+
+// FieldChange is a field of a stored book that was changed by a merge
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// MergeResult tells what storing a book did, Changes is empty when book was created
+type MergeResult struct {
+	Created bool          `json:"created"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// Merge applies fields of incoming book to b according to policy, it returns which fields changed.
+// Title identifies the book so it's never changed, neither is provenance
+func (b *Book) Merge(incoming Book, policy MergePolicy) []FieldChange {
+	changes := make([]FieldChange, 0)
+
+	overwrite := false
+	switch policy.Strategy {
+	case Overwrite:
+		overwrite = true
+	case FillEmpty:
+	case PreferSource:
+		overwrite = policy.rank(incoming.SourceName) <= policy.rank(b.SourceName)
+	default:
+		return changes
+	}
+
+	merge := func(field string, stored string, new string, isEmpty func(string) bool) string {
+		if isEmpty(new) || stored == new || (!overwrite && !isEmpty(stored)) {
+			return stored
+		}
+
+		changes = append(changes, FieldChange{Field: field, Old: stored, New: new})
+		return new
+	}
+
+	b.Description = merge("description", b.Description, incoming.Description, isEmptyField)
+
+	isbn := merge("isbn", b.ISBN.String, incoming.ISBN.String, isEmptyISBN)
+	if isbn != b.ISBN.String {
+		b.ISBN = null.StringFrom(isbn)
+	}
+
+	b.Language = merge("language", b.Language, incoming.Language, isEmptyField)
+
+	return changes
+}
+
+func isEmptyField(value string) bool {
+	return strings.TrimSpace(value) == ""
+}
+
+func isEmptyISBN(value string) bool {
+	return isEmptyField(value) || value == UnavailableISBN
+}
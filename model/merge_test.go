@@ -0,0 +1,106 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestMergeStrategies(t *testing.T) {
+	stored := Book{
+		Title:       "Title",
+		Description: "Stored description",
+		ISBN:        null.StringFrom(UnavailableISBN),
+		Language:    "",
+		Provenance:  Provenance{SourceName: null.StringFrom("kotlinlang.org")},
+	}
+
+	incoming := Book{
+		Title:       "Title",
+		Description: "New description",
+		ISBN:        null.StringFrom("9781617293290"),
+		Language:    "EN",
+		Provenance:  Provenance{SourceName: null.StringFrom(SourceAPI)},
+	}
+
+	descriptionChange := FieldChange{Field: "description", Old: "Stored description", New: "New description"}
+	isbnChange := FieldChange{Field: "isbn", Old: UnavailableISBN, New: "9781617293290"}
+	languageChange := FieldChange{Field: "language", Old: "", New: "EN"}
+
+	cases := []struct {
+		strategy        MergeStrategy
+		incomingSource  string
+		expectedChanges []FieldChange
+	}{
+		{KeepExisting, SourceAPI, []FieldChange{}},
+		{Overwrite, SourceAPI, []FieldChange{descriptionChange, isbnChange, languageChange}},
+		{FillEmpty, SourceAPI, []FieldChange{isbnChange, languageChange}},
+		{PreferSource, SourceAPI, []FieldChange{descriptionChange, isbnChange, languageChange}},
+		{PreferSource, "other.org", []FieldChange{isbnChange, languageChange}},
+	}
+
+	for _, c := range cases {
+		book := stored
+		newBook := incoming
+		newBook.SourceName = null.StringFrom(c.incomingSource)
+
+		changes := book.Merge(newBook, MergePolicy{Strategy: c.strategy, SourcePriority: []string{SourceAPI, "kotlinlang.org"}})
+
+		assert.Equal(t, c.expectedChanges, changes, string(c.strategy))
+		assert.Equal(t, stored.Provenance, book.Provenance)
+		for _, change := range changes {
+			if change.Field == "isbn" {
+				assert.Equal(t, null.StringFrom("9781617293290"), book.ISBN)
+			}
+		}
+	}
+}
+
+func TestMergeNeverReplacesWithEmptyValues(t *testing.T) {
+	book := Book{Title: "Title", Description: "Stored description", ISBN: null.StringFrom("9781617293290"), Language: "EN"}
+	expectedBook := book
+
+	changes := book.Merge(Book{Title: "Title", ISBN: null.StringFrom(UnavailableISBN), Description: " "}, MergePolicy{Strategy: Overwrite})
+
+	assert.Equal(t, []FieldChange{}, changes)
+	assert.Equal(t, expectedBook, book)
+}
+
+func TestParseMergeStrategy(t *testing.T) {
+	strategy, err := ParseMergeStrategy("fill_empty")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, FillEmpty, strategy)
+
+	_, err = ParseMergeStrategy("whatever")
+	assert.EqualError(t, err, "Merge strategy must be one of keep_existing, overwrite, fill_empty or prefer_source")
+}
+
+func TestNewMergePolicyFromEnv(t *testing.T) {
+	os.Unsetenv("MERGE_STRATEGY")
+	os.Unsetenv("MERGE_SOURCE_PRIORITY")
+	assert.Equal(t, MergePolicy{Strategy: KeepExisting, SourcePriority: []string{SourceAPI}}, NewMergePolicyFromEnv())
+
+	os.Setenv("MERGE_STRATEGY", "prefer_source")
+	os.Setenv("MERGE_SOURCE_PRIORITY", "kotlinlang.org, api")
+	defer os.Unsetenv("MERGE_STRATEGY")
+	defer os.Unsetenv("MERGE_SOURCE_PRIORITY")
+	assert.Equal(t, MergePolicy{Strategy: PreferSource, SourcePriority: []string{"kotlinlang.org", SourceAPI}}, NewMergePolicyFromEnv())
+}
+
+func TestMergePolicyFromQuery(t *testing.T) {
+	os.Setenv("MERGE_STRATEGY", "fill_empty")
+	defer os.Unsetenv("MERGE_STRATEGY")
+
+	policy, err := MergePolicyFromQuery(map[string]string{})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, FillEmpty, policy.Strategy)
+
+	policy, err = MergePolicyFromQuery(map[string]string{"merge": "overwrite"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Overwrite, policy.Strategy)
+
+	_, err = MergePolicyFromQuery(map[string]string{"merge": "replace"})
+	assert.EqualError(t, err, "Merge strategy must be one of keep_existing, overwrite, fill_empty or prefer_source")
+}
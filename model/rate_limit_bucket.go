@@ -0,0 +1,69 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RateLimitBucket is the state of a client's token bucket, shared by every function instance
+type RateLimitBucket struct {
+	Key        string `gorm:"primary_key;size:200"`
+	Tokens     float64
+	RefilledAt time.Time
+
+	// FullAt is when the bucket is full again, it's no different from no bucket at all from then on
+	FullAt time.Time
+
+	// Version is bumped by every update, so that concurrent ones don't overwrite each other
+	Version int
+}
+
+// FindRateLimitBucket retrieves the bucket with given key, returns nil when there's no such bucket
+func FindRateLimitBucket(db *gorm.DB, key string) (*RateLimitBucket, error) {
+	bucket := RateLimitBucket{}
+
+	dbc := db.Where("key = ?", key).Find(&bucket)
+	if dbc.RecordNotFound() {
+		return nil, nil
+	}
+
+	if dbc.Error != nil {
+		return nil, dbc.Error
+	}
+
+	return &bucket, nil
+}
+
+// Create stores b in database, it fails when there's a bucket with the same key already
+func (b *RateLimitBucket) Create(db *gorm.DB) error {
+	return db.Create(b).Error
+}
+
+// Update stores b's tokens, returns false when b was updated by someone else since it was read
+func (b *RateLimitBucket) Update(db *gorm.DB) (bool, error) {
+	dbc := db.Model(&RateLimitBucket{}).
+		Where("key = ? AND version = ?", b.Key, b.Version).
+		Updates(map[string]interface{}{
+			"tokens":      b.Tokens,
+			"refilled_at": b.RefilledAt,
+			"full_at":     b.FullAt,
+			"version":     b.Version + 1,
+		})
+	if dbc.Error != nil {
+		return false, dbc.Error
+	}
+
+	if dbc.RowsAffected != 1 {
+		return false, nil
+	}
+
+	b.Version++
+	return true, nil
+}
+
+// DeleteFullRateLimitBuckets deletes buckets that are full again at now, along with those stored before FullAt was
+func DeleteFullRateLimitBuckets(db *gorm.DB, now time.Time) (int64, error) {
+	dbc := db.Where("full_at <= ? OR full_at IS NULL", now).Delete(&RateLimitBucket{})
+	return dbc.RowsAffected, dbc.Error
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendsStoreAndUpdateRateLimitBuckets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		refilledAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		bucket := RateLimitBucket{Key: "create:ip:192.0.2.1", Tokens: 29, RefilledAt: refilledAt}
+		assert.Equal(t, nil, bucket.Create(db))
+
+		duplicate := RateLimitBucket{Key: "create:ip:192.0.2.1", Tokens: 29, RefilledAt: refilledAt}
+		assert.NotEqual(t, nil, duplicate.Create(db))
+
+		found, err := FindRateLimitBucket(db, "create:ip:192.0.2.1")
+		assert.Equal(t, nil, err)
+		assert.Equal(t, float64(29), found.Tokens)
+		assert.True(t, refilledAt.Equal(found.RefilledAt))
+
+		stale := *found
+		found.Tokens = 28
+		updated, err := found.Update(db)
+		assert.Equal(t, nil, err)
+		assert.True(t, updated)
+		assert.Equal(t, 1, found.Version)
+
+		stale.Tokens = 28
+		updated, err = stale.Update(db)
+		assert.Equal(t, nil, err)
+		assert.False(t, updated)
+
+		found, err = FindRateLimitBucket(db, "search:ip:192.0.2.1")
+		assert.Equal(t, nil, err)
+		assert.Nil(t, found)
+	})
+}
+
+func TestBackendsDeleteFullRateLimitBuckets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, db *gorm.DB) {
+		now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+		full := RateLimitBucket{Key: "create:ip:192.0.2.1", RefilledAt: now, FullAt: now.Add(-time.Second)}
+		filling := RateLimitBucket{Key: "create:ip:192.0.2.2", RefilledAt: now, FullAt: now.Add(time.Minute)}
+		assert.Equal(t, nil, full.Create(db))
+		assert.Equal(t, nil, filling.Create(db))
+
+		deleted, err := DeleteFullRateLimitBuckets(db, now)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, int64(1), deleted)
+
+		found, _ := FindRateLimitBucket(db, "create:ip:192.0.2.1")
+		assert.Nil(t, found)
+
+		found, _ = FindRateLimitBucket(db, "create:ip:192.0.2.2")
+		assert.NotNil(t, found)
+	})
+}
@@ -0,0 +1,198 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	null "gopkg.in/guregu/null.v3"
+)
+
+// JobStatus represents the state a scrap job is in
+type JobStatus string
+
+const (
+	// JobPending is the status of a job waiting for a worker
+	JobPending JobStatus = "pending"
+
+	// JobRunning is the status of a job currently being executed by a worker
+	JobRunning JobStatus = "running"
+
+	// JobSucceeded is the status of a job that finished successfully
+	JobSucceeded JobStatus = "succeeded"
+
+	// JobFailed is the status of a job that finished with an error
+	JobFailed JobStatus = "failed"
+)
+
+// ErrScrapJobNotRunning is returned when finishing a job that isn't running anymore, e.g. it was failed as stale
+var ErrScrapJobNotRunning = errors.New("Scrap job is not running anymore")
+
+// ScrapJob represents an asynchronous scrap run record in database
+type ScrapJob struct {
+	ID           uint        `gorm:"primary_key" json:"id"`
+	Mode         string      `gorm:"size:20" json:"mode"`
+	ForceRefresh bool        `json:"forceRefresh"`
+	Merge        string      `gorm:"size:20" json:"merge"`
+	Provenance   bool        `json:"provenance"`
+	Status       JobStatus   `gorm:"size:10;index" json:"status"`
+	Progress     string      `json:"progress"`
+	Result       null.String `gorm:"type:text" json:"-"`
+	Error        null.String `json:"error"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	StartedAt    null.Time   `json:"startedAt"`
+	FinishedAt   null.Time   `json:"finishedAt"`
+
+	// HeartbeatAt is when the worker running job last told it's still alive, see FailStaleScrapJobs
+	HeartbeatAt null.Time `json:"heartbeatAt"`
+}
+
+// Create stores job in database as pending
+func (j *ScrapJob) Create(db *gorm.DB) error {
+	j.Status = JobPending
+	j.Progress = "Waiting for a worker"
+
+	return db.Create(j).Error
+}
+
+// MergePolicy is the policy job's books are merged with, its strategy is the one job was created with when there's one
+func (j *ScrapJob) MergePolicy() MergePolicy {
+	policy := NewMergePolicyFromEnv()
+	if strategy, err := ParseMergeStrategy(j.Merge); err == nil {
+		policy.Strategy = strategy
+	}
+
+	return policy
+}
+
+// Start marks job as running, returns false if job was not pending anymore (i.e. another worker took it)
+func (j *ScrapJob) Start(db *gorm.DB) (bool, error) {
+	now := time.Now()
+
+	dbc := db.Model(&ScrapJob{}).
+		Where("id = ? AND status = ?", j.ID, JobPending).
+		Updates(map[string]interface{}{"status": JobRunning, "progress": "Scrapping books", "started_at": now, "heartbeat_at": now})
+	if dbc.Error != nil {
+		return false, dbc.Error
+	}
+
+	if dbc.RowsAffected != 1 {
+		return false, nil
+	}
+
+	j.Status = JobRunning
+	j.Progress = "Scrapping books"
+	j.StartedAt = null.TimeFrom(now)
+	j.HeartbeatAt = null.TimeFrom(now)
+
+	return true, nil
+}
+
+// UpdateProgress stores a human readable description of what the job is currently doing, it's a heartbeat as well
+func (j *ScrapJob) UpdateProgress(db *gorm.DB, progress string) error {
+	now := time.Now()
+	j.Progress = progress
+	j.HeartbeatAt = null.TimeFrom(now)
+
+	return db.Model(&ScrapJob{}).Where("id = ?", j.ID).Updates(map[string]interface{}{"progress": progress, "heartbeat_at": now}).Error
+}
+
+// Heartbeat tells job's worker is still alive, it's meant to be called periodically while job runs. Only job's ID is
+// used so it can be called while job is being changed elsewhere
+func (j *ScrapJob) Heartbeat(db *gorm.DB) error {
+	return db.Model(&ScrapJob{}).Where("id = ? AND status = ?", j.ID, JobRunning).Update("heartbeat_at", time.Now()).Error
+}
+
+// Finish stores job's outcome, result is kept for successful jobs and failure for failed ones. It fails with
+// ErrScrapJobNotRunning when job isn't running anymore, its outcome is left as it was then
+func (j *ScrapJob) Finish(db *gorm.DB, result string, failure error) error {
+	j.FinishedAt = null.TimeFrom(time.Now())
+
+	if failure != nil {
+		j.Status = JobFailed
+		j.Progress = "Failed"
+		j.Error = null.StringFrom(failure.Error())
+	} else {
+		j.Status = JobSucceeded
+		j.Progress = "Done"
+		j.Result = null.StringFrom(result)
+	}
+
+	dbc := db.Model(&ScrapJob{}).Where("id = ? AND status = ?", j.ID, JobRunning).Updates(map[string]interface{}{
+		"status":      j.Status,
+		"progress":    j.Progress,
+		"result":      j.Result,
+		"error":       j.Error,
+		"finished_at": j.FinishedAt,
+	})
+	if dbc.Error != nil {
+		return dbc.Error
+	}
+
+	if dbc.RowsAffected != 1 {
+		return ErrScrapJobNotRunning
+	}
+
+	return nil
+}
+
+// FindScrapJobByID retrieves job with given ID, returns nil when there's no such job
+func FindScrapJobByID(db *gorm.DB, id uint) (*ScrapJob, error) {
+	job := ScrapJob{}
+
+	dbc := db.Where("id = ?", id).Find(&job)
+	if dbc.RecordNotFound() {
+		return nil, nil
+	}
+
+	if dbc.Error != nil {
+		return nil, dbc.Error
+	}
+
+	return &job, nil
+}
+
+// ClaimNextPendingScrapJob starts the oldest pending job and returns it, returns nil when there's nothing to run
+func ClaimNextPendingScrapJob(db *gorm.DB) (*ScrapJob, error) {
+	for {
+		job := ScrapJob{}
+
+		dbc := db.Where("status = ?", JobPending).First(&job)
+		if dbc.RecordNotFound() {
+			return nil, nil
+		}
+
+		if dbc.Error != nil {
+			return nil, dbc.Error
+		}
+
+		started, err := job.Start(db)
+		if err != nil {
+			return nil, err
+		}
+
+		// Another worker might have taken it in the meantime, in that case try the next one
+		if started {
+			return &job, nil
+		}
+	}
+}
+
+// FailStaleScrapJobs fails running jobs whose worker didn't send a heartbeat for longer than staleAfter, their worker
+// is gone (e.g. Lambda timed out) and they'd be running forever otherwise. It returns how many jobs were failed
+func FailStaleScrapJobs(db *gorm.DB, staleAfter time.Duration) (int64, error) {
+	now := time.Now()
+	threshold := now.Add(-staleAfter)
+
+	dbc := db.Model(&ScrapJob{}).
+		Where("status = ? AND (heartbeat_at < ? OR (heartbeat_at IS NULL AND started_at < ?))", JobRunning, threshold, threshold).
+		Updates(map[string]interface{}{
+			"status":      JobFailed,
+			"progress":    "Failed",
+			"error":       fmt.Sprintf("Worker stopped responding for more than %s", staleAfter),
+			"finished_at": now,
+		})
+
+	return dbc.RowsAffected, dbc.Error
+}
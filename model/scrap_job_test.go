@@ -0,0 +1,264 @@
+package model
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrapJobCreate(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("INSERT INTO \"scrap_jobs\" (.+)").
+		WithArgs("scrap_only", false, "", false, JobPending, "Waiting for a worker", nil, nil, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+
+	var expectedError error
+	job := ScrapJob{Mode: "scrap_only"}
+	actualError := job.Create(gormDB)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, uint(1), job.ID)
+	assert.Equal(t, JobPending, job.Status)
+}
+
+func TestScrapJobStart(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+) WHERE \\(id = \\$5 AND status = \\$6\\)").
+		WithArgs(sqlmock.AnyArg(), "Scrapping books", sqlmock.AnyArg(), JobRunning, 1, JobPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobPending}
+	started, err := job.Start(gormDB)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, started)
+	assert.Equal(t, JobRunning, job.Status)
+	assert.Equal(t, true, job.StartedAt.Valid)
+	assert.Equal(t, job.StartedAt, job.HeartbeatAt)
+}
+
+func TestScrapJobStartAlreadyTaken(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobPending}
+	started, err := job.Start(gormDB)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, started)
+	assert.Equal(t, JobPending, job.Status)
+}
+
+func TestScrapJobUpdateProgress(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET \"heartbeat_at\" = \\$1, \"progress\" = \\$2 WHERE \\(id = \\$3\\)").
+		WithArgs(sqlmock.AnyArg(), "Storing 3 books", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobRunning}
+	err := job.UpdateProgress(gormDB, "Storing 3 books")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Storing 3 books", job.Progress)
+	assert.Equal(t, true, job.HeartbeatAt.Valid)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestScrapJobHeartbeat(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET \"heartbeat_at\" = \\$1 WHERE \\(id = \\$2 AND status = \\$3\\)").
+		WithArgs(sqlmock.AnyArg(), 1, JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobRunning}
+
+	assert.Equal(t, nil, job.Heartbeat(gormDB))
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestScrapJobFinishSucceeded(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+) WHERE \\(id = \\$6 AND status = \\$7\\)").
+		WithArgs(nil, sqlmock.AnyArg(), "Done", `{"numberBooks":0,"books":[]}`, JobSucceeded, 1, JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobRunning}
+	err := job.Finish(gormDB, `{"numberBooks":0,"books":[]}`, nil)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, JobSucceeded, job.Status)
+	assert.Equal(t, `{"numberBooks":0,"books":[]}`, job.Result.String)
+	assert.Equal(t, false, job.Error.Valid)
+}
+
+func TestScrapJobFinishFailed(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WithArgs("website is down", sqlmock.AnyArg(), "Failed", nil, JobFailed, 1, JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobRunning}
+	err := job.Finish(gormDB, "", errors.New("website is down"))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, JobFailed, job.Status)
+	assert.Equal(t, "website is down", job.Error.String)
+	assert.Equal(t, false, job.Result.Valid)
+}
+
+func TestScrapJobFinishNotRunning(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	job := ScrapJob{ID: 1, Status: JobRunning}
+	err := job.Finish(gormDB, `{"numberBooks":0,"books":[]}`, nil)
+
+	assert.Equal(t, ErrScrapJobNotRunning, err)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestScrapJobMergePolicy(t *testing.T) {
+	os.Setenv("MERGE_STRATEGY", "overwrite")
+	defer os.Unsetenv("MERGE_STRATEGY")
+
+	assert.Equal(t, FillEmpty, (&ScrapJob{Merge: "fill_empty"}).MergePolicy().Strategy)
+	assert.Equal(t, Overwrite, (&ScrapJob{}).MergePolicy().Strategy)
+}
+
+func TestFindScrapJobByID(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "mode", "status", "progress"}).AddRow(1, "scrap_only", "running", "Scrapping books"))
+
+	expectedJob := &ScrapJob{ID: 1, Mode: "scrap_only", Status: JobRunning, Progress: "Scrapping books"}
+	actualJob, actualError := FindScrapJobByID(gormDB, 1)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, expectedJob, actualJob)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WithArgs(2).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	actualJob, actualError = FindScrapJobByID(gormDB, 2)
+
+	assert.Equal(t, nil, actualError)
+	assert.Nil(t, actualJob)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WithArgs(3).
+		WillReturnError(errors.New("database error"))
+
+	actualJob, actualError = FindScrapJobByID(gormDB, 3)
+
+	assert.Equal(t, errors.New("database error"), actualError)
+	assert.Nil(t, actualJob)
+}
+
+func TestClaimNextPendingScrapJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	// First pending job is taken by someone else before we start it
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" WHERE \\(status = \\$1\\) ORDER BY (.+)").
+		WithArgs(JobPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "mode", "status"}).AddRow(1, "scrap_only", "pending"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" WHERE \\(status = \\$1\\) ORDER BY (.+)").
+		WithArgs(JobPending).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "mode", "status"}).AddRow(2, "scrap_diff", "pending"))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job, err := ClaimNextPendingScrapJob(gormDB)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, uint(2), job.ID)
+	assert.Equal(t, JobRunning, job.Status)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" WHERE \\(status = \\$1\\) ORDER BY (.+)").
+		WithArgs(JobPending).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	job, err = ClaimNextPendingScrapJob(gormDB)
+
+	assert.Equal(t, nil, err)
+	assert.Nil(t, job)
+}
@@ -8,3 +8,9 @@ var sampleBook = Book{
 	ISBN:        null.StringFrom("9781617293290"),
 	Language:    "BR",
 }
+
+// identified returns book along with its normalized ISBN and title, as it is once stored in database
+func identified(book Book) Book {
+	book.identify()
+	return book
+}
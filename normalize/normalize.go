@@ -0,0 +1,69 @@
+// Package normalize turns ISBNs and titles into the canonical form books are matched by
+package normalize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ISBN returns isbn as 13 digits, without hyphens nor spaces and with ISBN-10 converted to ISBN-13.
+// It returns an empty string when isbn isn't one, e.g. "Unavailable"
+func ISBN(isbn string) string {
+	isbn = strings.ToUpper(strings.TrimSpace(isbn))
+	for _, prefix := range []string{"ISBN-13", "ISBN-10", "ISBN"} {
+		isbn = strings.TrimPrefix(isbn, prefix)
+	}
+
+	digits := make([]rune, 0, 13)
+	for _, r := range isbn {
+		switch {
+		case r >= '0' && r <= '9', r == 'X':
+			digits = append(digits, r)
+		case r == '-', r == ':', unicode.IsSpace(r):
+		default:
+			return ""
+		}
+	}
+
+	value := string(digits)
+	switch {
+	case len(value) == 13 && !strings.ContainsRune(value, 'X'):
+		return value
+	case len(value) == 10 && !strings.ContainsRune(value[:9], 'X'):
+		return isbn10To13(value)
+	default:
+		return ""
+	}
+}
+
+// isbn10To13 prefixes isbn with 978 and computes the new check digit, isbn's own check digit is dropped
+func isbn10To13(isbn string) string {
+	isbn = "978" + isbn[:9]
+
+	sum := 0
+	for index, r := range isbn {
+		weight := 1
+		if index%2 == 1 {
+			weight = 3
+		}
+
+		sum += int(r-'0') * weight
+	}
+
+	return isbn + string(rune('0'+(10-sum%10)%10))
+}
+
+// Title returns title in Unicode NFC, lower case, with punctuation turned into spaces and spaces collapsed,
+// so that "Kotlin in Action" and "kotlin  in action!" are the same title
+func Title(title string) string {
+	runes := []rune(strings.ToLower(norm.NFC.String(title)))
+	for index, r := range runes {
+		if unicode.IsPunct(r) {
+			runes[index] = ' '
+		}
+	}
+
+	return strings.Join(strings.Fields(string(runes)), " ")
+}
@@ -0,0 +1,43 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestISBN(t *testing.T) {
+	cases := map[string]string{
+		"9781617293290":           "9781617293290",
+		"978-1-61729-329-0":       "9781617293290",
+		" 978 1617293290 ":        "9781617293290",
+		"ISBN: 9781617293290":     "9781617293290",
+		"ISBN-13: 978-1617293290": "9781617293290",
+		"1617293296":              "9781617293290",
+		"0-8044-2957-X":           "9780804429573",
+		"Unavailable":             "",
+		"":                        "",
+		"12345":                   "",
+		"97816172932X0":           "",
+	}
+
+	for isbn, expected := range cases {
+		assert.Equal(t, expected, ISBN(isbn), isbn)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	cases := map[string]string{
+		"Kotlin in Action":                  "kotlin in action",
+		"  Kotlin   in\tAction!  ":          "kotlin in action",
+		"Kotlin: The Big Nerd Ranch":        "kotlin the big nerd ranch",
+		"Kotlin - The Big Nerd Ranch":       "kotlin the big nerd ranch",
+		"Programação em Kotlin":             "programação em kotlin",
+		"Programac\u0327a\u0303o em Kotlin": "programação em kotlin", // Decomposed accents
+		"C++ for Kotlin developers":         "c++ for kotlin developers",
+	}
+
+	for title, expected := range cases {
+		assert.Equal(t, expected, Title(title), title)
+	}
+}
@@ -0,0 +1,122 @@
+package openapi
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+)
+
+// bodyName is what problems with the body itself call it
+const bodyName = "Body"
+
+// Validation checks requests against document before they reach handlers. Operations are found by request's
+// resource, so requests must have been routed already; those document doesn't describe are left alone
+func Validation(document *Document) middleware.Middleware {
+	return func(next middleware.HandlerFunc) middleware.HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			operation := document.Operation(request.HTTPMethod, request.Resource)
+			if operation == nil {
+				return next(ctx, request)
+			}
+
+			if err := document.ValidateRequest(operation, request); err != nil {
+				return err.Response(request), nil
+			}
+
+			return next(ctx, request)
+		}
+	}
+}
+
+// ValidateRequest checks request's parameters and body against operation. Wrong parameters fail with
+// INVALID_PARAMETER, a body that isn't JSON with INVALID_REQUEST and one that doesn't match its schema with
+// VALIDATION_FAILED
+func (d *Document) ValidateRequest(operation *Operation, request events.APIGatewayProxyRequest) *apierror.Error {
+	var problems []string
+	for _, parameter := range operation.Parameters {
+		value, ok := parameterValue(parameter, request)
+		if !ok {
+			if parameter.Required {
+				problems = append(problems, fmt.Sprintf("%q is required", parameter.Name))
+			}
+
+			continue
+		}
+
+		name := fmt.Sprintf("%q", parameter.Name)
+		schema := d.Resolve(parameter.Schema)
+
+		parsed, ok := parse(schema, value)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s must be %s %s", name, article(schema.Type), schema.Type))
+			continue
+		}
+
+		problems = append(problems, d.Validate(schema, parsed, name)...)
+	}
+
+	if len(problems) > 0 {
+		return apierror.New(400, apierror.InvalidParameter, strings.Join(problems, "; "))
+	}
+
+	if operation.RequestBody == nil {
+		return nil
+	}
+
+	return d.validateBody(operation.RequestBody, request)
+}
+
+func (d *Document) validateBody(requestBody *RequestBody, request events.APIGatewayProxyRequest) *apierror.Error {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return apierror.New(400, apierror.InvalidRequest, "Body is not valid base64")
+		}
+
+		body = decoded
+	}
+
+	if len(body) == 0 {
+		if requestBody.Required {
+			return apierror.New(400, apierror.InvalidRequest, "Body cannot be empty")
+		}
+
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return apierror.New(400, apierror.InvalidRequest, "Body must be valid JSON")
+	}
+
+	problems := d.Validate(requestBody.Content["application/json"].Schema, value, bodyName)
+	if len(problems) > 0 {
+		return apierror.New(400, apierror.ValidationFailed, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+func parameterValue(parameter Parameter, request events.APIGatewayProxyRequest) (string, bool) {
+	var value string
+	var ok bool
+
+	switch parameter.In {
+	case "path":
+		value, ok = request.PathParameters[parameter.Name]
+	case "query":
+		value, ok = request.QueryStringParameters[parameter.Name]
+	case "header":
+		value = middleware.GetHeader(request, parameter.Name)
+		ok = value != ""
+	}
+
+	return value, ok
+}
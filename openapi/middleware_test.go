@@ -0,0 +1,83 @@
+package openapi
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func validated(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, bool) {
+	handled := false
+	next := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		handled = true
+		return events.APIGatewayProxyResponse{StatusCode: 200}, nil
+	}
+
+	response, _ := Validation(testDocument)(next)(context.Background(), request)
+	return response, handled
+}
+
+func getBook(id string, query map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		Resource:              "/book/{id}",
+		PathParameters:        map[string]string{"id": id},
+		QueryStringParameters: query,
+		Headers:               map[string]string{"x-client": "tests"},
+	}
+}
+
+func TestValidationLetsValidRequestsThrough(t *testing.T) {
+	requests := []events.APIGatewayProxyRequest{
+		getBook("42", map[string]string{"provenance": "true"}),
+		{HTTPMethod: "POST", Resource: "/book", Body: `{"title": "Kotlin", "language": "EN"}`, QueryStringParameters: map[string]string{"merge": "overwrite"}},
+		{HTTPMethod: "POST", Resource: "/book", Body: base64.StdEncoding.EncodeToString([]byte(`{"title": "Kotlin", "language": "EN"}`)), IsBase64Encoded: true},
+		{HTTPMethod: "DELETE", Resource: "/book/{id}"},
+		{HTTPMethod: "GET", Resource: "/authors"},
+	}
+
+	for _, request := range requests {
+		response, handled := validated(request)
+
+		assert.True(t, handled, request.HTTPMethod+" "+request.Resource)
+		assert.Equal(t, 200, response.StatusCode)
+	}
+}
+
+func TestValidationRejectsInvalidParameters(t *testing.T) {
+	requests := map[string]events.APIGatewayProxyRequest{
+		`"id" must be an integer`:                                 getBook("abc", nil),
+		`"id" must be at least 1`:                                 getBook("0", nil),
+		`"provenance" must be a boolean`:                          getBook("1", map[string]string{"provenance": "maybe"}),
+		`"id" must be an integer; "provenance" must be a boolean`: getBook("1.5", map[string]string{"provenance": "maybe"}),
+		`"X-Client" is required`:                                  {HTTPMethod: "GET", Resource: "/book/{id}", PathParameters: map[string]string{"id": "1"}},
+		`"merge" must be one of keep_existing, overwrite`:         {HTTPMethod: "POST", Resource: "/book", Body: `{}`, QueryStringParameters: map[string]string{"merge": "whatever"}},
+	}
+
+	for message, request := range requests {
+		response, handled := validated(request)
+
+		assert.False(t, handled, message)
+		assert.Equal(t, 400, response.StatusCode, message)
+		assert.Equal(t, `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"`+strings.Replace(message, `"`, `\"`, -1)+`"}}`, response.Body)
+	}
+}
+
+func TestValidationRejectsInvalidBodies(t *testing.T) {
+	bodies := map[string]string{
+		"":                              `{"error":{"status":400,"code":"INVALID_REQUEST","message":"Body cannot be empty"}}`,
+		`{"title": `:                    `{"error":{"status":400,"code":"INVALID_REQUEST","message":"Body must be valid JSON"}}`,
+		`{"title": "", "pages": "360"}`: `{"error":{"status":400,"code":"VALIDATION_FAILED","message":"language is required; pages must be an integer; title cannot be empty"}}`,
+	}
+
+	for body, expected := range bodies {
+		response, handled := validated(events.APIGatewayProxyRequest{HTTPMethod: "POST", Resource: "/book", Body: body})
+
+		assert.False(t, handled, body)
+		assert.Equal(t, expected, response.Body)
+	}
+}
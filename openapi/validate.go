@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Validate checks value, as decoded by encoding/json, against schema. It returns every problem found, each one naming
+// where in value it is, e.g. "books[0].title"; name is what value itself is called
+func (d *Document) Validate(schema *Schema, value interface{}, name string) []string {
+	var problems []string
+	d.validate(schema, value, name, &problems)
+
+	return problems
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, name string, problems *[]string) {
+	schema = d.Resolve(schema)
+	if schema == nil {
+		return
+	}
+
+	if value == nil {
+		if schema.Type != "" && !schema.Nullable {
+			*problems = append(*problems, fmt.Sprintf("%s cannot be null", name))
+		}
+
+		return
+	}
+
+	if len(schema.OneOf) > 0 {
+		d.validateOneOf(schema, value, name, problems)
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		d.validateObject(schema, value, name, problems)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be an array", name))
+			return
+		}
+
+		for index, item := range items {
+			d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", name, index), problems)
+		}
+	case "string":
+		validateString(schema, value, name, problems)
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (schema.Type == "integer" && number != math.Trunc(number)) {
+			*problems = append(*problems, fmt.Sprintf("%s must be %s %s", name, article(schema.Type), schema.Type))
+			return
+		}
+
+		if schema.Minimum != nil && number < *schema.Minimum {
+			*problems = append(*problems, fmt.Sprintf("%s must be at least %v", name, *schema.Minimum))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*problems = append(*problems, fmt.Sprintf("%s must be a boolean", name))
+		}
+	}
+}
+
+func (d *Document) validateObject(schema *Schema, value interface{}, name string, problems *[]string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s must be an object", name))
+		return
+	}
+
+	for _, property := range schema.Required {
+		if _, ok := object[property]; !ok {
+			*problems = append(*problems, fmt.Sprintf("%s is required", join(name, property)))
+		}
+	}
+
+	// Sorted so that problems are always listed in the same order
+	properties := make([]string, 0, len(schema.Properties))
+	for property := range schema.Properties {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+
+	for _, property := range properties {
+		if propertyValue, ok := object[property]; ok {
+			d.validate(schema.Properties[property], propertyValue, join(name, property), problems)
+		}
+	}
+}
+
+func (d *Document) validateOneOf(schema *Schema, value interface{}, name string, problems *[]string) {
+	matches := 0
+	for _, candidate := range schema.OneOf {
+		if len(d.Validate(candidate, value, name)) == 0 {
+			matches++
+		}
+	}
+
+	if matches != 1 {
+		*problems = append(*problems, fmt.Sprintf("%s must match exactly one of its schemas, it matches %d", name, matches))
+	}
+}
+
+func validateString(schema *Schema, value interface{}, name string, problems *[]string) {
+	text, ok := value.(string)
+	if !ok {
+		*problems = append(*problems, fmt.Sprintf("%s must be a string", name))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !contains(schema.Enum, text) {
+		*problems = append(*problems, fmt.Sprintf("%s must be one of %s", name, strings.Join(schema.Enum, ", ")))
+	}
+
+	length := len([]rune(text))
+	if length < schema.MinLength {
+		*problems = append(*problems, fmt.Sprintf("%s cannot be empty", name))
+	}
+
+	if schema.MaxLength > 0 && length > schema.MaxLength {
+		*problems = append(*problems, fmt.Sprintf("%s must be at most %d characters long", name, schema.MaxLength))
+	}
+}
+
+// parse converts value, sent as text in path, query string or headers, into what schema describes
+func parse(schema *Schema, value string) (interface{}, bool) {
+	switch schema.Type {
+	case "integer", "number":
+		number, err := strconv.ParseFloat(value, 64)
+		return number, err == nil
+	case "boolean":
+		boolean, err := strconv.ParseBool(value)
+		return boolean, err == nil
+	default:
+		return value, true
+	}
+}
+
+// join names property of the object named name, properties of the body aren't prefixed
+func join(name string, property string) string {
+	if name == bodyName {
+		return property
+	}
+
+	return name + "." + property
+}
+
+func article(typeName string) string {
+	if typeName == "integer" {
+		return "an"
+	}
+
+	return "a"
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var one = float64(1)
+
+var testDocument = &Document{
+	OpenAPI: Version,
+	Info:    Info{Title: "Books", Version: "test"},
+	Paths: map[string]PathItem{
+		"/book": {"post": {
+			OperationID: "createBook",
+			Parameters:  []Parameter{{Name: "merge", In: "query", Schema: &Schema{Type: "string", Enum: []string{"keep_existing", "overwrite"}}}},
+			RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: SchemaRef("Book")}}},
+		}},
+		"/book/{id}": {"get": {
+			OperationID: "getBook",
+			Parameters: []Parameter{
+				{Name: "id", In: "path", Required: true, Schema: &Schema{Type: "integer", Minimum: &one}},
+				{Name: "provenance", In: "query", Schema: &Schema{Type: "boolean"}},
+				{Name: "X-Client", In: "header", Required: true, Schema: &Schema{Type: "string", MinLength: 1}},
+			},
+		}},
+	},
+	Components: Components{Schemas: map[string]*Schema{
+		"Book": {Type: "object", Required: []string{"title", "language"}, Properties: map[string]*Schema{
+			"title":    {Type: "string", MinLength: 1, MaxLength: 10},
+			"isbn":     {Type: "string", Nullable: true},
+			"language": {Type: "string", Enum: []string{"EN", "PT"}},
+			"pages":    {Type: "integer"},
+			"authors":  {Type: "array", Items: &Schema{Type: "object", Required: []string{"name"}, Properties: map[string]*Schema{"name": {Type: "string"}}}},
+			"metadata": {},
+		}},
+		"Diff":   {Type: "object", Required: []string{"new"}, Properties: map[string]*Schema{"new": {Type: "array"}}},
+		"Result": {OneOf: []*Schema{SchemaRef("Book"), SchemaRef("Diff")}},
+	}},
+}
+
+func decoded(text string) interface{} {
+	var value interface{}
+	json.Unmarshal([]byte(text), &value)
+
+	return value
+}
+
+func TestValidateAcceptsMatchingValues(t *testing.T) {
+	book := decoded(`{"title": "Kotlin", "isbn": null, "language": "EN", "pages": 360, "authors": [{"name": "Dmitry"}], "metadata": [1, "a"], "extra": true}`)
+
+	assert.Nil(t, testDocument.Validate(SchemaRef("Book"), book, "Body"))
+	assert.Nil(t, testDocument.Validate(SchemaRef("Result"), decoded(`{"new": []}`), "Body"))
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	book := decoded(`{"title": "Kotlin in Action", "isbn": 42, "language": "FR", "pages": 1.5, "authors": [{"name": "Dmitry"}, {}], "metadata": null}`)
+
+	assert.Equal(t, []string{
+		"authors[1].name is required",
+		"isbn must be a string",
+		"language must be one of EN, PT",
+		"pages must be an integer",
+		"title must be at most 10 characters long",
+	}, testDocument.Validate(SchemaRef("Book"), book, "Body"))
+
+	assert.Equal(t, []string{"title is required", "language is required"}, testDocument.Validate(SchemaRef("Book"), decoded(`{}`), "Body"))
+	assert.Equal(t, []string{"title cannot be null"}, testDocument.Validate(SchemaRef("Book"), decoded(`{"title": null, "language": "EN"}`), "Body"))
+	assert.Equal(t, []string{"title cannot be empty"}, testDocument.Validate(SchemaRef("Book"), decoded(`{"title": "", "language": "EN"}`), "Body"))
+	assert.Equal(t, []string{"Body must be an object"}, testDocument.Validate(SchemaRef("Book"), decoded(`[]`), "Body"))
+}
+
+func TestValidateRequiresExactlyOneOfSchemas(t *testing.T) {
+	problems := testDocument.Validate(SchemaRef("Result"), decoded(`{"title": "Kotlin", "language": "EN", "new": []}`), "result")
+	assert.Equal(t, []string{"result must match exactly one of its schemas, it matches 2"}, problems)
+
+	problems = testDocument.Validate(SchemaRef("Result"), decoded(`{}`), "result")
+	assert.Equal(t, []string{"result must match exactly one of its schemas, it matches 0"}, problems)
+}
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/felipefill/books/model"
+	"github.com/jinzhu/gorm"
+)
+
+// maxDBAttempts bounds how many times DBStore retries taking a token when other requests update the same bucket
+var maxDBAttempts = 5
+
+// DBStore keeps buckets in database, so that every function instance shares them. Buckets full again are deleted
+// every pruneEvery, by whichever instance takes a token then
+type DBStore struct {
+	db func() (*gorm.DB, error)
+
+	mutex    sync.Mutex
+	prunedAt time.Time
+}
+
+// NewDBStore creates a DBStore keeping buckets in the database db returns, e.g. utils.GetDB
+func NewDBStore(db func() (*gorm.DB, error)) *DBStore {
+	return &DBStore{db: db}
+}
+
+// Take implements Store. Buckets are updated optimistically, taking a token is retried when a concurrent request
+// updated the same bucket first
+func (s *DBStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	db, err := s.db()
+	if err != nil {
+		return Result{}, err
+	}
+
+	s.pruneIfDue(db, now)
+
+	for attempt := 0; attempt < maxDBAttempts; attempt++ {
+		stored, err := model.FindRateLimitBucket(db, key)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if stored == nil {
+			current := fullBucket(limit, now)
+			result := current.take(limit, now)
+
+			// Creating fails when a concurrent request created the bucket first, it's then found on next attempt
+			stored = &model.RateLimitBucket{
+				Key:        key,
+				Tokens:     current.tokens,
+				RefilledAt: current.refilledAt,
+				FullAt:     now.Add(result.Reset),
+			}
+			if stored.Create(db) == nil {
+				return result, nil
+			}
+
+			continue
+		}
+
+		current := bucket{tokens: stored.Tokens, refilledAt: stored.RefilledAt}
+		result := current.take(limit, now)
+
+		stored.Tokens = current.tokens
+		stored.RefilledAt = current.refilledAt
+		stored.FullAt = now.Add(result.Reset)
+
+		updated, err := stored.Update(db)
+		if err != nil {
+			return Result{}, err
+		}
+
+		if updated {
+			return result, nil
+		}
+	}
+
+	return Result{}, fmt.Errorf("Could not take a token from %s after %d attempts", key, maxDBAttempts)
+}
+
+// Peek implements Store
+func (s *DBStore) Peek(key string, limit Limit, now time.Time) (Result, error) {
+	db, err := s.db()
+	if err != nil {
+		return Result{}, err
+	}
+
+	stored, err := model.FindRateLimitBucket(db, key)
+	if err != nil {
+		return Result{}, err
+	}
+
+	current := fullBucket(limit, now)
+	if stored != nil {
+		current = bucket{tokens: stored.Tokens, refilledAt: stored.RefilledAt}
+	}
+
+	return current.take(limit, now), nil
+}
+
+// pruneIfDue deletes buckets full again when it wasn't done for pruneEvery. It only costs the request some time, a
+// failure is logged and pruning is retried pruneEvery later
+func (s *DBStore) pruneIfDue(db *gorm.DB, now time.Time) {
+	s.mutex.Lock()
+	if now.Sub(s.prunedAt) < pruneEvery {
+		s.mutex.Unlock()
+		return
+	}
+	s.prunedAt = now
+	s.mutex.Unlock()
+
+	if _, err := model.DeleteFullRateLimitBuckets(db, now); err != nil {
+		log.Printf("Could not prune rate limit buckets: %s", err.Error())
+	}
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/felipefill/books/migrations"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite" // SQLite dialect for GORM
+	"github.com/stretchr/testify/assert"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection to :memory: gets its own database
+	db.DB().SetMaxOpenConns(1)
+
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestDBStoreSharesBucketsThroughDatabase(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	getDB := func() (*gorm.DB, error) { return db, nil }
+	limit := Limit{Requests: 2, Per: time.Minute}
+
+	first, err := NewDBStore(getDB).Take("create:ip:192.0.2.1", limit, testNow)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 30 * time.Second}, first)
+
+	second, _ := NewDBStore(getDB).Take("create:ip:192.0.2.1", limit, testNow)
+	third, _ := NewDBStore(getDB).Take("create:ip:192.0.2.1", limit, testNow)
+	assert.True(t, second.Allowed)
+	assert.False(t, third.Allowed)
+
+	later, _ := NewDBStore(getDB).Take("create:ip:192.0.2.1", limit, testNow.Add(30*time.Second))
+	assert.True(t, later.Allowed)
+}
+
+func TestDBStorePeeksWithoutTaking(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	store := NewDBStore(func() (*gorm.DB, error) { return db, nil })
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	peeked, err := store.Peek("auth_failures:ip:192.0.2.1", limit, testNow)
+	assert.Equal(t, nil, err)
+	assert.True(t, peeked.Allowed)
+
+	store.Take("auth_failures:ip:192.0.2.1", limit, testNow)
+	peeked, _ = store.Peek("auth_failures:ip:192.0.2.1", limit, testNow)
+	assert.False(t, peeked.Allowed)
+
+	peeked, _ = store.Peek("auth_failures:ip:192.0.2.1", limit, testNow.Add(time.Minute))
+	assert.True(t, peeked.Allowed)
+}
+
+func TestDBStoreDeletesFullBuckets(t *testing.T) {
+	db := openTestDB(t)
+	defer db.Close()
+
+	store := NewDBStore(func() (*gorm.DB, error) { return db, nil })
+	store.Take("create:ip:192.0.2.1", Limit{Requests: 1, Per: time.Minute}, testNow)
+	store.Take("scrap_and_store:ip:192.0.2.1", Limit{Requests: 1, Per: time.Hour}, testNow)
+
+	store.Take("search:ip:192.0.2.1", Limit{Requests: 10, Per: time.Minute}, testNow.Add(30*time.Second))
+
+	var count int
+	db.Table("rate_limit_buckets").Count(&count)
+	assert.Equal(t, 3, count)
+
+	store.Take("search:ip:192.0.2.1", Limit{Requests: 10, Per: time.Minute}, testNow.Add(2*time.Minute))
+
+	var keys []string
+	db.Table("rate_limit_buckets").Order("key").Pluck("key", &keys)
+	assert.Equal(t, []string{"scrap_and_store:ip:192.0.2.1", "search:ip:192.0.2.1"}, keys)
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// pruneEvery is how often MemoryStore forgets buckets that are full again, which are the same as no bucket at all
+var pruneEvery = time.Minute
+
+// MemoryStore keeps buckets in memory. It's meant for the HTTP server, Lambda instances would each have their own
+type MemoryStore struct {
+	mutex    sync.Mutex
+	buckets  map[string]*memoryBucket
+	prunedAt time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	fullAt time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.prunedAt) >= pruneEvery {
+		s.prune(now)
+	}
+
+	stored, ok := s.buckets[key]
+	if !ok {
+		stored = &memoryBucket{bucket: fullBucket(limit, now)}
+		s.buckets[key] = stored
+	}
+
+	result := stored.take(limit, now)
+	stored.fullAt = now.Add(result.Reset)
+
+	return result, nil
+}
+
+// Peek implements Store
+func (s *MemoryStore) Peek(key string, limit Limit, now time.Time) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current := fullBucket(limit, now)
+	if stored, ok := s.buckets[key]; ok {
+		current = stored.bucket
+	}
+
+	return current.take(limit, now), nil
+}
+
+func (s *MemoryStore) prune(now time.Time) {
+	for key, stored := range s.buckets {
+		if !stored.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+
+	s.prunedAt = now
+}
@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStoreKeepsABucketPerKey(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	first, _ := store.Take("create:ip:192.0.2.1", limit, testNow)
+	second, _ := store.Take("create:ip:192.0.2.1", limit, testNow)
+	other, _ := store.Take("create:ip:192.0.2.2", limit, testNow)
+
+	assert.True(t, first.Allowed)
+	assert.False(t, second.Allowed)
+	assert.True(t, other.Allowed)
+}
+
+func TestMemoryStoreForgetsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	store.Take("create:ip:192.0.2.1", Limit{Requests: 1, Per: time.Minute}, testNow)
+	store.Take("scrap_and_store:ip:192.0.2.1", Limit{Requests: 1, Per: time.Hour}, testNow)
+
+	store.Take("search:ip:192.0.2.1", Limit{Requests: 10, Per: time.Minute}, testNow.Add(2*time.Minute))
+
+	assert.Len(t, store.buckets, 2)
+	assert.Contains(t, store.buckets, "scrap_and_store:ip:192.0.2.1")
+}
+
+func TestMemoryStorePeeksWithoutTaking(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	peeked, _ := store.Peek("auth_failures:ip:192.0.2.1", limit, testNow)
+	assert.True(t, peeked.Allowed)
+	assert.Empty(t, store.buckets)
+
+	store.Take("auth_failures:ip:192.0.2.1", limit, testNow)
+	peeked, _ = store.Peek("auth_failures:ip:192.0.2.1", limit, testNow)
+	assert.False(t, peeked.Allowed)
+	assert.Equal(t, time.Minute, peeked.RetryAfter)
+}
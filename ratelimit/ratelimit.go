@@ -0,0 +1,230 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/auth"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/utils"
+)
+
+// Limit lets Requests requests through every Per, in bursts of up to Requests. The zero Limit doesn't limit anything
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads a limit written as requests/period, e.g. "30/1m" or "2/h", "off" doesn't limit anything
+func ParseLimit(value string) (Limit, error) {
+	if value == "off" {
+		return Limit{}, nil
+	}
+
+	parts := strings.Split(value, "/")
+	if len(parts) != 2 {
+		return Limit{}, fmt.Errorf("Limit must look like 30/1m, got %q", value)
+	}
+
+	requests, err := strconv.Atoi(parts[0])
+	if err != nil || requests < 1 {
+		return Limit{}, fmt.Errorf("Limit must allow at least one request, got %q", value)
+	}
+
+	period := parts[1]
+	if period != "" && strings.IndexAny(period[:1], "0123456789") < 0 {
+		period = "1" + period
+	}
+
+	per, err := time.ParseDuration(period)
+	if err != nil || per <= 0 {
+		return Limit{}, fmt.Errorf("Limit must have a positive period, got %q", value)
+	}
+
+	return Limit{Requests: requests, Per: per}, nil
+}
+
+func (l Limit) String() string {
+	if l.Requests == 0 {
+		return "off"
+	}
+
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// Result tells whether a request was let through and how the client's bucket is doing afterwards
+type Result struct {
+	Allowed bool
+	Limit   int
+
+	// Remaining is how many requests can be made right away
+	Remaining int
+
+	// RetryAfter is how long to wait for the next request to be let through, zero when it would be right away
+	RetryAfter time.Duration
+
+	// Reset is how long it takes for the bucket to be full again
+	Reset time.Duration
+}
+
+// Store keeps buckets, Take takes a token from the one with given key when there's one left. Peek tells what Take
+// would without taking anything
+type Store interface {
+	Take(key string, limit Limit, now time.Time) (Result, error)
+	Peek(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// bucket is a token bucket, it holds up to limit's requests tokens and gets them back at limit's pace
+type bucket struct {
+	tokens     float64
+	refilledAt time.Time
+}
+
+// fullBucket is what clients start with
+func fullBucket(limit Limit, now time.Time) bucket {
+	return bucket{tokens: float64(limit.Requests), refilledAt: now}
+}
+
+// take refills b for the time elapsed since it last was and then takes a token from it, if there's one
+func (b *bucket) take(limit Limit, now time.Time) Result {
+	capacity := float64(limit.Requests)
+	perToken := float64(limit.Per) / capacity
+
+	if elapsed := now.Sub(b.refilledAt); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+float64(elapsed)/perToken)
+		b.refilledAt = now
+	}
+
+	result := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * perToken)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((capacity - b.tokens) * perToken)
+
+	return result
+}
+
+// Rules tells which limit applies to request, under which name. Every name gets buckets of its own, requests for
+// which ok is false aren't limited
+type Rules func(request events.APIGatewayProxyRequest) (name string, limit Limit, ok bool)
+
+// Middleware limits requests according to rules, with a bucket per rule and client kept in store. Clients are told
+// how they are doing in X-RateLimit-* headers, those out of tokens are answered with 429 RATE_LIMITED. It must run
+// after authentication: authenticated clients are told apart by principal, anonymous ones by IP
+func Middleware(store Store, rules Rules) middleware.Middleware {
+	return func(next middleware.HandlerFunc) middleware.HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			name, limit, ok := rules(request)
+			if !ok || limit.Requests == 0 {
+				return next(ctx, request)
+			}
+
+			result, err := store.Take(name+":"+client(ctx, request), limit, time.Now())
+			if err != nil {
+				return storeFailed(request, err), nil
+			}
+
+			if !result.Allowed {
+				return rateLimited(request, result), nil
+			}
+
+			response, err := next(ctx, request)
+			if err != nil {
+				return response, err
+			}
+
+			return withHeaders(response, result), nil
+		}
+	}
+}
+
+// LimitFailures limits, under name, how many requests of a client may fail as failed tells, e.g. with 401 for bad
+// credentials. It must run before authentication: clients are told apart by IP and, once out of tokens, answered with
+// 429 RATE_LIMITED without their credentials being checked. Requests that don't fail take no token
+func LimitFailures(store Store, name string, limit Limit, failed func(events.APIGatewayProxyResponse) bool) middleware.Middleware {
+	return func(next middleware.HandlerFunc) middleware.HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			if limit.Requests == 0 {
+				return next(ctx, request)
+			}
+
+			key := name + ":ip:" + request.RequestContext.Identity.SourceIP
+			result, err := store.Peek(key, limit, time.Now())
+			if err != nil {
+				return storeFailed(request, err), nil
+			}
+
+			if !result.Allowed {
+				return rateLimited(request, result), nil
+			}
+
+			response, err := next(ctx, request)
+			if err != nil || !failed(response) {
+				return response, err
+			}
+
+			// The response is sent anyway, a failure that couldn't be counted is only logged
+			if _, err := store.Take(key, limit, time.Now()); err != nil {
+				log.Printf("Could not count failed request of %s: %s", key, err.Error())
+			}
+
+			return response, nil
+		}
+	}
+}
+
+func storeFailed(request events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+	if utils.IsUnavailable(err) {
+		return utils.UnavailableResponse(request, err)
+	}
+
+	return apierror.Internal().Response(request)
+}
+
+func rateLimited(request events.APIGatewayProxyRequest, result Result) events.APIGatewayProxyResponse {
+	retryAfter := seconds(result.RetryAfter)
+	rateLimited := apierror.New(429, apierror.RateLimited, fmt.Sprintf("Too many requests, retry in %d seconds", retryAfter)).
+		WithHeader("Retry-After", strconv.Itoa(retryAfter))
+
+	return withHeaders(rateLimited.Response(request), result)
+}
+
+// client tells who made request: its principal, found in ctx, when it's authenticated, its source IP otherwise
+func client(ctx context.Context, request events.APIGatewayProxyRequest) string {
+	if principal := auth.GetPrincipal(ctx); principal != nil {
+		return principal.ID
+	}
+
+	return "ip:" + request.RequestContext.Identity.SourceIP
+}
+
+func withHeaders(response events.APIGatewayProxyResponse, result Result) events.APIGatewayProxyResponse {
+	headers := make(map[string]string, len(response.Headers)+3)
+	for name, value := range response.Headers {
+		headers[name] = value
+	}
+
+	headers["X-RateLimit-Limit"] = strconv.Itoa(result.Limit)
+	headers["X-RateLimit-Remaining"] = strconv.Itoa(result.Remaining)
+	headers["X-RateLimit-Reset"] = strconv.Itoa(seconds(result.Reset))
+	response.Headers = headers
+
+	return response
+}
+
+// seconds rounds duration up to whole seconds, so that clients waiting that long are let through
+func seconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/auth"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var testNow = time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+func TestParseLimit(t *testing.T) {
+	limits := map[string]Limit{
+		"30/1m":   {Requests: 30, Per: time.Minute},
+		"2/h":     {Requests: 2, Per: time.Hour},
+		"10/90s":  {Requests: 10, Per: 90 * time.Second},
+		"off":     {},
+		"":        {},
+		"30":      {},
+		"0/1m":    {},
+		"30/soon": {},
+		"30/-1m":  {},
+	}
+
+	for value, expected := range limits {
+		limit, err := ParseLimit(value)
+
+		assert.Equal(t, expected, limit, value)
+		assert.Equal(t, expected.Requests == 0 && value != "off", err != nil, value)
+	}
+}
+
+func TestBucketLetsBurstsThroughAndRefillsAtLimitPace(t *testing.T) {
+	limit := Limit{Requests: 2, Per: time.Minute}
+	current := fullBucket(limit, testNow)
+
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 30 * time.Second}, current.take(limit, testNow))
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Minute}, current.take(limit, testNow))
+	assert.Equal(t, Result{Limit: 2, RetryAfter: 30 * time.Second, Reset: time.Minute}, current.take(limit, testNow))
+
+	assert.Equal(t, Result{Limit: 2, RetryAfter: 10 * time.Second, Reset: 40 * time.Second}, current.take(limit, testNow.Add(20*time.Second)))
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: time.Minute}, current.take(limit, testNow.Add(30*time.Second)))
+
+	result := current.take(limit, testNow.Add(time.Hour))
+	assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 30 * time.Second}, result)
+}
+
+// stubStore answers result, or err, and keeps the keys it was given to take from
+type stubStore struct {
+	result Result
+	err    error
+	keys   []string
+}
+
+func (s *stubStore) Take(key string, limit Limit, now time.Time) (Result, error) {
+	s.keys = append(s.keys, key)
+	return s.result, s.err
+}
+
+func (s *stubStore) Peek(key string, limit Limit, now time.Time) (Result, error) {
+	return s.result, s.err
+}
+
+// authenticatedAs authenticates every request as its principal
+type authenticatedAs auth.Principal
+
+func (a authenticatedAs) Authenticate(request events.APIGatewayProxyRequest) (*auth.Principal, error) {
+	principal := auth.Principal(a)
+	return &principal, nil
+}
+
+func limitEverything(limit Limit) Rules {
+	return func(request events.APIGatewayProxyRequest) (string, Limit, bool) {
+		return "create", limit, request.HTTPMethod == "POST"
+	}
+}
+
+func ok(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{StatusCode: 201, Headers: map[string]string{"Location": "/book/7"}}, nil
+}
+
+func TestMiddlewareTellsClientsHowTheyAreDoing(t *testing.T) {
+	store := &stubStore{result: Result{Allowed: true, Limit: 30, Remaining: 29, Reset: 1500 * time.Millisecond}}
+	request := events.APIGatewayProxyRequest{HTTPMethod: "POST"}
+	request.RequestContext.Identity.SourceIP = "192.0.2.1"
+
+	response, err := Middleware(store, limitEverything(Limit{Requests: 30, Per: time.Minute}))(ok)(context.Background(), request)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, events.APIGatewayProxyResponse{StatusCode: 201, Headers: map[string]string{
+		"Location":              "/book/7",
+		"X-RateLimit-Limit":     "30",
+		"X-RateLimit-Remaining": "29",
+		"X-RateLimit-Reset":     "2",
+	}}, response)
+	assert.Equal(t, []string{"create:ip:192.0.2.1"}, store.keys)
+}
+
+func TestMiddlewareTellsPrincipalsApart(t *testing.T) {
+	store := &stubStore{result: Result{Allowed: true, Limit: 30}}
+	request := events.APIGatewayProxyRequest{HTTPMethod: "POST"}
+	request.RequestContext.Identity.SourceIP = "192.0.2.1"
+	authenticate := auth.Middleware(authenticatedAs{ID: "api_key:7"}, auth.Always)
+
+	middleware.Chain(ok, authenticate, Middleware(store, limitEverything(Limit{Requests: 30, Per: time.Minute})))(context.Background(), request)
+
+	assert.Equal(t, []string{"create:api_key:7"}, store.keys)
+}
+
+func TestMiddlewareRejectsClientsOutOfTokens(t *testing.T) {
+	store := &stubStore{result: Result{Limit: 2, RetryAfter: 1799 * time.Second, Reset: time.Hour}}
+	handled := false
+	next := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		handled = true
+		return ok(ctx, request)
+	}
+
+	response, _ := Middleware(store, limitEverything(Limit{Requests: 2, Per: time.Hour}))(next)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST"})
+
+	assert.False(t, handled)
+	assert.Equal(t, events.APIGatewayProxyResponse{
+		StatusCode: 429,
+		Body:       `{"error":{"status":429,"code":"RATE_LIMITED","message":"Too many requests, retry in 1799 seconds"}}`,
+		Headers: map[string]string{
+			"Retry-After":           "1799",
+			"X-RateLimit-Limit":     "2",
+			"X-RateLimit-Remaining": "0",
+			"X-RateLimit-Reset":     "3600",
+		},
+	}, response)
+}
+
+func TestMiddlewareLeavesOtherRequestsAlone(t *testing.T) {
+	store := &stubStore{}
+	rules := limitEverything(Limit{Requests: 30, Per: time.Minute})
+
+	response, _ := Middleware(store, rules)(ok)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+	assert.Equal(t, 201, response.StatusCode)
+
+	response, _ = Middleware(store, limitEverything(Limit{}))(ok)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "POST"})
+	assert.Equal(t, 201, response.StatusCode)
+
+	assert.Nil(t, store.keys)
+}
+
+func TestMiddlewareAnswersWhenStoreFails(t *testing.T) {
+	rules := limitEverything(Limit{Requests: 30, Per: time.Minute})
+	request := events.APIGatewayProxyRequest{HTTPMethod: "POST"}
+
+	response, _ := Middleware(&stubStore{err: &utils.UnavailableError{Reason: "could not connect"}}, rules)(ok)(context.Background(), request)
+	assert.Equal(t, 503, response.StatusCode)
+
+	response, _ = Middleware(&stubStore{err: errors.New("database error")}, rules)(ok)(context.Background(), request)
+	assert.Equal(t, 500, response.StatusCode)
+}
+
+func unauthorized(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{StatusCode: 401}, nil
+}
+
+func isUnauthorized(response events.APIGatewayProxyResponse) bool {
+	return response.StatusCode == 401
+}
+
+func TestLimitFailuresRejectsClientsFailingTooOften(t *testing.T) {
+	store := NewMemoryStore()
+	limitFailures := LimitFailures(store, "auth_failures", Limit{Requests: 2, Per: time.Minute}, isUnauthorized)
+	request := events.APIGatewayProxyRequest{}
+	request.RequestContext.Identity.SourceIP = "192.0.2.1"
+
+	handled := 0
+	next := func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		handled++
+		return unauthorized(ctx, request)
+	}
+
+	first, _ := limitFailures(next)(context.Background(), request)
+	second, _ := limitFailures(next)(context.Background(), request)
+	third, _ := limitFailures(next)(context.Background(), request)
+
+	assert.Equal(t, 401, first.StatusCode)
+	assert.Equal(t, 401, second.StatusCode)
+	assert.Equal(t, 429, third.StatusCode)
+	assert.Equal(t, "30", third.Headers["Retry-After"])
+	assert.Equal(t, 2, handled)
+	assert.Contains(t, store.buckets, "auth_failures:ip:192.0.2.1")
+
+	other := events.APIGatewayProxyRequest{}
+	other.RequestContext.Identity.SourceIP = "192.0.2.2"
+	response, _ := limitFailures(next)(context.Background(), other)
+	assert.Equal(t, 401, response.StatusCode)
+}
+
+func TestLimitFailuresDoesNotCountOtherResponses(t *testing.T) {
+	store := &stubStore{result: Result{Allowed: true, Limit: 2, Remaining: 1}}
+	limitFailures := LimitFailures(store, "auth_failures", Limit{Requests: 2, Per: time.Minute}, isUnauthorized)
+
+	response, _ := limitFailures(ok)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, events.APIGatewayProxyResponse{StatusCode: 201, Headers: map[string]string{"Location": "/book/7"}}, response)
+	assert.Nil(t, store.keys)
+
+	limitFailures(unauthorized)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, []string{"auth_failures:ip:"}, store.keys)
+}
+
+func TestLimitFailuresAnswersWhenStoreFails(t *testing.T) {
+	limit := Limit{Requests: 2, Per: time.Minute}
+
+	response, _ := LimitFailures(&stubStore{err: &utils.UnavailableError{Reason: "could not connect"}}, "auth_failures", limit, isUnauthorized)(ok)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, 503, response.StatusCode)
+
+	response, _ = LimitFailures(&stubStore{err: errors.New("database error")}, "auth_failures", limit, isUnauthorized)(ok)(context.Background(), events.APIGatewayProxyRequest{})
+	assert.Equal(t, 500, response.StatusCode)
+}
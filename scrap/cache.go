@@ -0,0 +1,179 @@
+package scrap
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var defaultCacheTTL = time.Hour
+
+// ResponseCache is an http.RoundTripper that keeps responses on disk, once TTL expires cached responses are
+// revalidated using ETag/Last-Modified so that unchanged pages aren't downloaded again
+type ResponseCache struct {
+	Dir          string
+	TTL          time.Duration
+	ForceRefresh bool
+	Transport    http.RoundTripper
+}
+
+type cachedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"storedAt"`
+}
+
+// NewResponseCacheFromEnv creates a ResponseCache using SCRAP_CACHE_DIR and SCRAP_CACHE_TTL, returns nil when SCRAP_CACHE_DISABLED is set
+func NewResponseCacheFromEnv(forceRefresh bool) *ResponseCache {
+	if os.Getenv("SCRAP_CACHE_DISABLED") == "true" {
+		return nil
+	}
+
+	// Lambda only allows writing to /tmp, that's why temp dir is the default
+	dir := os.Getenv("SCRAP_CACHE_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "books-scrap-cache")
+	}
+
+	ttl := getDurationEnvOrDefault("SCRAP_CACHE_TTL", defaultCacheTTL)
+
+	return &ResponseCache{Dir: dir, TTL: ttl, ForceRefresh: forceRefresh}
+}
+
+// RoundTrip serves GET requests from cache when possible, otherwise forwards them to underlying transport
+func (cache *ResponseCache) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet {
+		return cache.transport().RoundTrip(request)
+	}
+
+	var cached *cachedResponse
+	if !cache.ForceRefresh {
+		cached = cache.load(request.URL.String())
+	}
+
+	if cached != nil && time.Since(cached.StoredAt) < cache.TTL {
+		return cached.toResponse(request), nil
+	}
+
+	if cached != nil {
+		// RoundTrippers must not modify given request
+		request = cloneRequest(request)
+
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+
+		if lastModified := cached.Header.Get("Last-Modified"); lastModified != "" {
+			request.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	response, err := cache.transport().RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		response.Body.Close()
+
+		cached.StoredAt = time.Now()
+		cache.store(request.URL.String(), cached)
+
+		return cached.toResponse(request), nil
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return response, nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.store(request.URL.String(), &cachedResponse{
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Body:       body,
+		StoredAt:   time.Now(),
+	})
+
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+func (cache *ResponseCache) transport() http.RoundTripper {
+	if cache.Transport != nil {
+		return cache.Transport
+	}
+
+	return http.DefaultTransport
+}
+
+func (cache *ResponseCache) path(url string) string {
+	hash := sha1.Sum([]byte(url))
+	return filepath.Join(cache.Dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (cache *ResponseCache) load(url string) *cachedResponse {
+	content, err := ioutil.ReadFile(cache.path(url))
+	if err != nil {
+		return nil
+	}
+
+	cached := new(cachedResponse)
+	if err = json.Unmarshal(content, cached); err != nil {
+		return nil
+	}
+
+	return cached
+}
+
+// Failing to store a response only means it will be downloaded again next time, so errors are ignored
+func (cache *ResponseCache) store(url string, cached *cachedResponse) {
+	content, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(cache.Dir, 0755); err != nil {
+		return
+	}
+
+	_ = ioutil.WriteFile(cache.path(url), content, 0644)
+}
+
+func (cached *cachedResponse) toResponse(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", cached.StatusCode, http.StatusText(cached.StatusCode)),
+		StatusCode:    cached.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        cached.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(cached.Body)),
+		ContentLength: int64(len(cached.Body)),
+		Request:       request,
+	}
+}
+
+func cloneRequest(request *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *request
+
+	clone.Header = make(http.Header)
+	for key, values := range request.Header {
+		clone.Header[key] = append([]string(nil), values...)
+	}
+
+	return clone
+}
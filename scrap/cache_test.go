@@ -0,0 +1,132 @@
+package scrap
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createCachingTestServer(requests *int, conditionalRequests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			*conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>ISBN 978-3-16-148410-0 is the identifier of this book</body></html>"))
+	}))
+}
+
+func createTestCache(t *testing.T, ttl time.Duration) *ResponseCache {
+	dir, err := ioutil.TempDir("", "books-scrap-cache")
+	assert.Equal(t, nil, err)
+
+	return &ResponseCache{Dir: dir, TTL: ttl}
+}
+
+func getThroughCache(t *testing.T, cache *ResponseCache, url string) string {
+	request, _ := http.NewRequest("GET", url, nil)
+
+	response, err := cache.RoundTrip(request)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	return string(body)
+}
+
+func TestResponseCacheServesFreshResponsesFromDisk(t *testing.T) {
+	requests, conditionalRequests := 0, 0
+	ts := createCachingTestServer(&requests, &conditionalRequests)
+	defer ts.Close()
+
+	cache := createTestCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	expectedBody := "<html><body>ISBN 978-3-16-148410-0 is the identifier of this book</body></html>"
+
+	assert.Equal(t, expectedBody, getThroughCache(t, cache, ts.URL+"/book.html"))
+	assert.Equal(t, expectedBody, getThroughCache(t, cache, ts.URL+"/book.html"))
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, 0, conditionalRequests)
+}
+
+func TestResponseCacheRevalidatesExpiredResponses(t *testing.T) {
+	requests, conditionalRequests := 0, 0
+	ts := createCachingTestServer(&requests, &conditionalRequests)
+	defer ts.Close()
+
+	cache := createTestCache(t, 0)
+	defer os.RemoveAll(cache.Dir)
+
+	expectedBody := "<html><body>ISBN 978-3-16-148410-0 is the identifier of this book</body></html>"
+
+	assert.Equal(t, expectedBody, getThroughCache(t, cache, ts.URL+"/book.html"))
+	assert.Equal(t, expectedBody, getThroughCache(t, cache, ts.URL+"/book.html"))
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 1, conditionalRequests)
+}
+
+func TestResponseCacheForceRefresh(t *testing.T) {
+	requests, conditionalRequests := 0, 0
+	ts := createCachingTestServer(&requests, &conditionalRequests)
+	defer ts.Close()
+
+	cache := createTestCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	getThroughCache(t, cache, ts.URL+"/book.html")
+
+	cache.ForceRefresh = true
+	getThroughCache(t, cache, ts.URL+"/book.html")
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, 0, conditionalRequests)
+}
+
+func TestScraperUsesCache(t *testing.T) {
+	requests, conditionalRequests := 0, 0
+	ts := createCachingTestServer(&requests, &conditionalRequests)
+	defer ts.Close()
+
+	cache := createTestCache(t, time.Hour)
+	defer os.RemoveAll(cache.Dir)
+
+	scraper := &Scraper{Cache: cache}
+
+	firstISBN, firstError := scraper.scrapISBN(ts.URL + "/book.html")
+	secondISBN, secondError := scraper.scrapISBN(ts.URL + "/book.html")
+
+	assert.Equal(t, nil, firstError)
+	assert.Equal(t, nil, secondError)
+	assert.Equal(t, "9783161484100", firstISBN)
+	assert.Equal(t, "9783161484100", secondISBN)
+	assert.Equal(t, 1, requests)
+}
+
+func TestNewResponseCacheFromEnv(t *testing.T) {
+	os.Setenv("SCRAP_CACHE_DIR", "/tmp/somewhere")
+	os.Setenv("SCRAP_CACHE_TTL", "10m")
+	defer os.Unsetenv("SCRAP_CACHE_DIR")
+	defer os.Unsetenv("SCRAP_CACHE_TTL")
+
+	expectedCache := &ResponseCache{Dir: "/tmp/somewhere", TTL: 10 * time.Minute, ForceRefresh: true}
+	assert.Equal(t, expectedCache, NewResponseCacheFromEnv(true))
+
+	os.Setenv("SCRAP_CACHE_DISABLED", "true")
+	defer os.Unsetenv("SCRAP_CACHE_DISABLED")
+
+	assert.Nil(t, NewResponseCacheFromEnv(false))
+}
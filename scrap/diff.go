@@ -0,0 +1,104 @@
+package scrap
+
+import "github.com/felipefill/books/model"
+
+// FieldDiff represents a single field whose stored value differs from the scrapped one
+type FieldDiff struct {
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`
+	Scrapped string `json:"scrapped"`
+}
+
+// BookDiff represents a book and the fields that differ between database and website
+type BookDiff struct {
+	Book  model.Book  `json:"book"`
+	Diffs []FieldDiff `json:"diffs"`
+}
+
+// BooksDiff represents what would change in database if scrapped books were stored
+type BooksDiff struct {
+	New     []BookDiff `json:"new"`
+	Changed []BookDiff `json:"changed"`
+	Removed []BookDiff `json:"removed"`
+
+	Failed  []FailedBook  `json:"failed,omitempty"`
+	Skipped []SkippedLink `json:"skipped,omitempty"`
+	Summary *ScrapSummary `json:"summary,omitempty"`
+}
+
+// DiffBooks compares books in result, scrapped from sourceName, against stored ones. Books are matched the same way
+// they are stored, against every stored book, see model.FindSameIn. Only stored books from sourceName, or stored
+// before provenance existed, are reported as removed, so those created through the API aren't. The ISBN of books
+// whose detail page failed or was skipped isn't compared, it wasn't scrapped, they're reported in Failed and Skipped
+func DiffBooks(result *ScrapResult, storedBooks []model.Book, sourceName string) BooksDiff {
+	diff := BooksDiff{
+		New:     make([]BookDiff, 0),
+		Changed: make([]BookDiff, 0),
+		Removed: make([]BookDiff, 0),
+	}
+
+	incomplete := incompleteTitles(result)
+
+	matched := make(map[int]bool)
+	for _, scrapped := range result.Books {
+		withISBN := !incomplete[scrapped.Title]
+
+		index, found := model.FindSameIn(storedBooks, scrapped)
+		if !found {
+			diff.New = append(diff.New, BookDiff{Book: scrapped, Diffs: diffBookFields(model.Book{}, scrapped, withISBN)})
+			continue
+		}
+
+		matched[index] = true
+
+		stored := storedBooks[index]
+		if fields := diffBookFields(stored, scrapped, withISBN); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, BookDiff{Book: stored, Diffs: fields})
+		}
+	}
+
+	for index, stored := range storedBooks {
+		if !matched[index] && storedFrom(stored, sourceName) {
+			diff.Removed = append(diff.Removed, BookDiff{Book: stored, Diffs: diffBookFields(stored, model.Book{}, true)})
+		}
+	}
+
+	return diff
+}
+
+// incompleteTitles returns the titles of books in result whose detail page failed or was skipped
+func incompleteTitles(result *ScrapResult) map[string]bool {
+	titles := make(map[string]bool)
+	for _, failed := range result.Failed {
+		titles[failed.Book.Title] = true
+	}
+
+	for _, skipped := range result.Skipped {
+		titles[skipped.Title] = true
+	}
+
+	return titles
+}
+
+// storedFrom tells whether book was stored from sourceName or without a source
+func storedFrom(book model.Book, sourceName string) bool {
+	return !book.SourceName.Valid || book.SourceName.String == sourceName
+}
+
+func diffBookFields(stored model.Book, scrapped model.Book, withISBN bool) []FieldDiff {
+	diffs := make([]FieldDiff, 0)
+
+	if stored.Description != scrapped.Description {
+		diffs = append(diffs, FieldDiff{Field: "description", Stored: stored.Description, Scrapped: scrapped.Description})
+	}
+
+	if withISBN && stored.ISBN.String != scrapped.ISBN.String {
+		diffs = append(diffs, FieldDiff{Field: "isbn", Stored: stored.ISBN.String, Scrapped: scrapped.ISBN.String})
+	}
+
+	if stored.Language != scrapped.Language {
+		diffs = append(diffs, FieldDiff{Field: "language", Stored: stored.Language, Scrapped: scrapped.Language})
+	}
+
+	return diffs
+}
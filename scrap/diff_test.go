@@ -0,0 +1,172 @@
+package scrap
+
+import (
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
+)
+
+func TestDiffBooksNothingStored(t *testing.T) {
+	books := sampleBooksUsedInLocalWebsite
+
+	expectedDiff := BooksDiff{
+		New: []BookDiff{
+			BookDiff{Book: books[0], Diffs: []FieldDiff{
+				FieldDiff{Field: "description", Stored: "", Scrapped: books[0].Description},
+				FieldDiff{Field: "isbn", Stored: "", Scrapped: books[0].ISBN.String},
+				FieldDiff{Field: "language", Stored: "", Scrapped: books[0].Language},
+			}},
+			BookDiff{Book: books[1], Diffs: []FieldDiff{
+				FieldDiff{Field: "description", Stored: "", Scrapped: books[1].Description},
+				FieldDiff{Field: "isbn", Stored: "", Scrapped: books[1].ISBN.String},
+				FieldDiff{Field: "language", Stored: "", Scrapped: books[1].Language},
+			}},
+			BookDiff{Book: books[2], Diffs: []FieldDiff{
+				FieldDiff{Field: "description", Stored: "", Scrapped: books[2].Description},
+				FieldDiff{Field: "isbn", Stored: "", Scrapped: books[2].ISBN.String},
+				FieldDiff{Field: "language", Stored: "", Scrapped: books[2].Language},
+			}},
+		},
+		Changed: []BookDiff{},
+		Removed: []BookDiff{},
+	}
+
+	actualDiff := DiffBooks(&ScrapResult{Books: books}, []model.Book{}, kotlinSourceName)
+
+	assert.Equal(t, expectedDiff, actualDiff)
+}
+
+func TestDiffBooksNothingChanged(t *testing.T) {
+	storedBooks := make([]model.Book, len(sampleBooksUsedInLocalWebsite))
+	copy(storedBooks, sampleBooksUsedInLocalWebsite)
+	storedBooks[0].ID = 1
+	storedBooks[1].ID = 2
+	storedBooks[2].ID = 3
+
+	expectedDiff := BooksDiff{
+		New:     []BookDiff{},
+		Changed: []BookDiff{},
+		Removed: []BookDiff{},
+	}
+
+	actualDiff := DiffBooks(&ScrapResult{Books: sampleBooksUsedInLocalWebsite}, storedBooks, kotlinSourceName)
+
+	assert.Equal(t, expectedDiff, actualDiff)
+}
+
+func TestDiffBooksChangedAndRemoved(t *testing.T) {
+	storedBooks := make([]model.Book, len(sampleBooksUsedInLocalWebsite))
+	copy(storedBooks, sampleBooksUsedInLocalWebsite)
+	storedBooks[0].ID = 1
+	storedBooks[1].ID = 2
+	storedBooks[1].ISBN = null.StringFrom("9781234567890")
+	storedBooks[1].Language = "PT"
+
+	removedBook := model.Book{
+		ID:          4,
+		Title:       "Book that is gone",
+		Description: "It used to be on the website",
+		ISBN:        null.StringFrom("Unavailable"),
+		Language:    "EN",
+	}
+
+	scrappedBooks := sampleBooksUsedInLocalWebsite[:2]
+	storedBooks = append(storedBooks[:2], removedBook)
+
+	expectedDiff := BooksDiff{
+		New: []BookDiff{},
+		Changed: []BookDiff{
+			BookDiff{Book: storedBooks[1], Diffs: []FieldDiff{
+				FieldDiff{Field: "isbn", Stored: "9781234567890", Scrapped: "Unavailable"},
+				FieldDiff{Field: "language", Stored: "PT", Scrapped: "EN"},
+			}},
+		},
+		Removed: []BookDiff{
+			BookDiff{Book: removedBook, Diffs: []FieldDiff{
+				FieldDiff{Field: "description", Stored: removedBook.Description, Scrapped: ""},
+				FieldDiff{Field: "isbn", Stored: removedBook.ISBN.String, Scrapped: ""},
+				FieldDiff{Field: "language", Stored: removedBook.Language, Scrapped: ""},
+			}},
+		},
+	}
+
+	actualDiff := DiffBooks(&ScrapResult{Books: scrappedBooks}, storedBooks, kotlinSourceName)
+
+	assert.Equal(t, expectedDiff, actualDiff)
+}
+
+func TestDiffBooksMatchesByISBNBeforeTitle(t *testing.T) {
+	storedBook := model.Book{ID: 1, Title: "Kotlin in Action", ISBN: null.StringFrom("978-1-61729-329-0"), Language: "EN"}
+	scrappedBook := model.Book{Title: "Kotlin In Action!", ISBN: null.StringFrom("1617293296"), Language: "EN"}
+
+	expectedDiff := BooksDiff{
+		New: []BookDiff{},
+		Changed: []BookDiff{
+			BookDiff{Book: storedBook, Diffs: []FieldDiff{
+				FieldDiff{Field: "isbn", Stored: "978-1-61729-329-0", Scrapped: "1617293296"},
+			}},
+		},
+		Removed: []BookDiff{},
+	}
+
+	actualDiff := DiffBooks(&ScrapResult{Books: []model.Book{scrappedBook}}, []model.Book{storedBook}, kotlinSourceName)
+
+	assert.Equal(t, expectedDiff, actualDiff)
+}
+
+func TestDiffBooksLeavesOutBooksFromOtherSources(t *testing.T) {
+	scrappedBook := model.Book{Title: "Kotlin in Action", ISBN: null.StringFrom("9781617293290"), Language: "EN"}
+	removedBook := model.Book{ID: 2, Title: "Book that is gone", Language: "EN", Provenance: model.Provenance{SourceName: null.StringFrom(kotlinSourceName)}}
+	createdBook := model.Book{ID: 3, Title: "Book created through the API", Language: "EN", Provenance: model.Provenance{SourceName: null.StringFrom(model.SourceAPI)}}
+
+	storedBook := scrappedBook
+	storedBook.ID = 1
+
+	actualDiff := DiffBooks(&ScrapResult{Books: []model.Book{scrappedBook}}, []model.Book{storedBook, removedBook, createdBook}, kotlinSourceName)
+
+	assert.Equal(t, []BookDiff{}, actualDiff.New)
+	assert.Equal(t, []BookDiff{}, actualDiff.Changed)
+	assert.Equal(t, 1, len(actualDiff.Removed))
+	assert.Equal(t, removedBook, actualDiff.Removed[0].Book)
+}
+
+func TestDiffBooksMatchesBooksFromOtherSources(t *testing.T) {
+	scrappedBook := model.Book{Title: "Kotlin in Action", ISBN: null.StringFrom("9781617293290"), Language: "EN"}
+	createdBook := model.Book{ID: 1, Title: "Kotlin in Action", Language: "EN", Provenance: model.Provenance{SourceName: null.StringFrom(model.SourceAPI)}}
+
+	actualDiff := DiffBooks(&ScrapResult{Books: []model.Book{scrappedBook}}, []model.Book{createdBook}, kotlinSourceName)
+
+	assert.Equal(t, []BookDiff{}, actualDiff.New)
+	assert.Equal(t, []BookDiff{
+		BookDiff{Book: createdBook, Diffs: []FieldDiff{
+			FieldDiff{Field: "isbn", Stored: "", Scrapped: "9781617293290"},
+		}},
+	}, actualDiff.Changed)
+	assert.Equal(t, []BookDiff{}, actualDiff.Removed)
+}
+
+func TestDiffBooksLeavesISBNOfFailedAndSkippedBooksOut(t *testing.T) {
+	failedBook := model.Book{Title: "Kotlin in Action", ISBN: null.StringFrom("Unavailable"), Language: "EN"}
+	skippedBook := model.Book{Title: "Atomic Kotlin", ISBN: null.StringFrom("Unavailable"), Language: "EN"}
+	result := &ScrapResult{
+		Books:   []model.Book{failedBook, skippedBook},
+		Failed:  []FailedBook{FailedBook{Book: failedBook, Link: "https://www.manning.com/books/kotlin-in-action", Error: "timeout"}},
+		Skipped: []SkippedLink{SkippedLink{Title: skippedBook.Title, Link: "https://example.com", Reason: "unsupported host"}},
+	}
+
+	storedBook := failedBook
+	storedBook.ID = 1
+	storedBook.ISBN = null.StringFrom("9781617293290")
+
+	actualDiff := DiffBooks(result, []model.Book{storedBook}, kotlinSourceName)
+
+	assert.Equal(t, []BookDiff{
+		BookDiff{Book: skippedBook, Diffs: []FieldDiff{
+			FieldDiff{Field: "language", Stored: "", Scrapped: "EN"},
+		}},
+	}, actualDiff.New)
+	assert.Equal(t, []BookDiff{}, actualDiff.Changed)
+	assert.Equal(t, []BookDiff{}, actualDiff.Removed)
+}
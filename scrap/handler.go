@@ -0,0 +1,233 @@
+package scrap
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
+	"github.com/felipefill/books/utils"
+	null "gopkg.in/guregu/null.v3"
+)
+
+var kotlinBooksURL = "https://kotlinlang.org/docs/books.html"
+
+// kotlinSourceName is the source name of books scrapped from Kotlin website
+var kotlinSourceName = "kotlinlang.org"
+
+// BooksResponse is a collection of books along with what happened to detail pages when books were scrapped
+type BooksResponse struct {
+	model.Books
+	Failed  []FailedBook  `json:"failed,omitempty"`
+	Skipped []SkippedLink `json:"skipped,omitempty"`
+	Summary *ScrapSummary `json:"summary,omitempty"`
+
+	// Stored counts books of the scrap that were inserted or already stored, only scrap_and_store sets it
+	Stored *model.UpsertSummary `json:"stored,omitempty"`
+}
+
+// Handler scraps books and stores them in its repository
+type Handler struct {
+	books model.BookRepository
+}
+
+// NewHandler creates a Handler that stores and retrieves books using given repository
+func NewHandler(books model.BookRepository) *Handler {
+	return &Handler{books: books}
+}
+
+// traced returns a copy of h whose queries are traced within span, see utils.TraceBookRepository
+func (h *Handler) traced(span *tracing.Span) *Handler {
+	return &Handler{books: utils.TraceBookRepository(h.books, span)}
+}
+
+// Handle is our lambda handler, it's invoked by `lambda.Start` (see cmd/scrap) or by the HTTP server (see cmd/server)
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.Resource {
+	case "/scrap/jobs":
+		return h.enqueueScrapJob(request)
+	case "/scrap/jobs/{id}":
+		return retrieveScrapJob(request)
+	}
+
+	policy, err := model.MergePolicyFromQuery(request.QueryStringParameters)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidParameter, err.Error()).Response(request), nil
+	}
+
+	span := middleware.GetSpan(ctx)
+	scraper := NewScraper(retrieveForceRefresh(request))
+	scraper.Span = span
+
+	response, err := h.traced(span).runWorkingMode(scraper, retrieveWorkingMode(request), kotlinBooksURL, policy, retrieveIncludeProvenance(request))
+	if err != nil {
+		return errorResponse(request, err), nil
+	}
+
+	return response, nil
+}
+
+// errorResponse answers request with err, an *apierror.Error or database being unavailable
+func errorResponse(request events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+	if utils.IsUnavailable(err) {
+		return utils.UnavailableResponse(request, err)
+	}
+
+	if apiErr, ok := err.(*apierror.Error); ok {
+		return apiErr.Response(request)
+	}
+
+	return apierror.Internal().Response(request)
+}
+
+// runWorkingMode runs given mode, policy tells how stored books are merged with scrapped ones and includeProvenance
+// adds books' provenance to the response. It fails with an *apierror.Error or with database being unavailable, it's
+// up to callers to answer with it
+func (h *Handler) runWorkingMode(scraper *Scraper, workingMode WorkingMode, kotlinBooksURL string, policy model.MergePolicy, includeProvenance bool) (events.APIGatewayProxyResponse, error) {
+	switch workingMode {
+	case ScrapOnly:
+		return scrapBooksAndReturn(scraper, kotlinBooksURL, includeProvenance)
+	case ScrapAndStore:
+		return h.scrapAndStoreBooksThenReturn(scraper, kotlinBooksURL, policy, includeProvenance)
+	case ScrapDiff:
+		return h.scrapDiffAndReturn(scraper, kotlinBooksURL)
+	default:
+		return h.retrieveAllStoredBooks(includeProvenance)
+	}
+}
+
+func scrapBooksAndReturn(scraper *Scraper, kotlinBooksURL string, includeProvenance bool) (events.APIGatewayProxyResponse, error) {
+	result, err := scraper.FindKotlinBooks(kotlinBooksURL)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	}
+
+	books := BooksResponse{
+		Books: model.Books{
+			NumberBooks: uint(len(result.Books)),
+			Books:       result.Books,
+		},
+		Failed:  result.Failed,
+		Skipped: result.Skipped,
+		Summary: &result.Summary,
+	}
+
+	if includeProvenance {
+		books.ShowProvenance()
+	}
+
+	json, _ := json.Marshal(books)
+	return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+}
+
+func (h *Handler) scrapAndStoreBooksThenReturn(scraper *Scraper, kotlinBooksURL string, policy model.MergePolicy, includeProvenance bool) (events.APIGatewayProxyResponse, error) {
+	result, err := scraper.FindKotlinBooks(kotlinBooksURL)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	}
+
+	seenAt := null.TimeFrom(time.Now())
+
+	batch := make([]model.Book, 0, len(result.Books))
+	for _, book := range result.Books {
+		book.SourceName = null.StringFrom(kotlinSourceName)
+		book.SourceURL = null.StringFrom(kotlinBooksURL)
+		book.FirstSeenAt = seenAt
+		book.LastSeenAt = seenAt
+		book.ContentHash = null.StringFrom(book.HashContent())
+
+		batch = append(batch, book)
+	}
+
+	scraper.report("Storing %d books", len(batch))
+	stored, err := h.books.UpsertBooks(batch, policy)
+	if utils.IsUnavailable(err) {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.InternalError, "Something went wrong while storing scrapped books")
+	}
+
+	recordStored(stored)
+
+	return h.retrieveStoredBooks(result, &stored, includeProvenance)
+}
+
+func (h *Handler) scrapDiffAndReturn(scraper *Scraper, kotlinBooksURL string) (events.APIGatewayProxyResponse, error) {
+	result, err := scraper.FindKotlinBooks(kotlinBooksURL)
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	}
+
+	scraper.report("Comparing %d books with stored ones", len(result.Books))
+	storedBooks, err := h.books.GetAll()
+	if utils.IsUnavailable(err) {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.InternalError, "Something went wrong while retrieving books from database")
+	}
+
+	diff := DiffBooks(result, storedBooks.Books, kotlinSourceName)
+	diff.Failed = result.Failed
+	diff.Skipped = result.Skipped
+	diff.Summary = &result.Summary
+
+	json, _ := json.Marshal(diff)
+	return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+}
+
+func (h *Handler) retrieveAllStoredBooks(includeProvenance bool) (events.APIGatewayProxyResponse, error) {
+	return h.retrieveStoredBooks(nil, nil, includeProvenance)
+}
+
+// retrieveStoredBooks responds with all stored books, result is the scrap that preceded it and stored what happened
+// when its books were stored, if any
+func (h *Handler) retrieveStoredBooks(result *ScrapResult, stored *model.UpsertSummary, includeProvenance bool) (events.APIGatewayProxyResponse, error) {
+	storedBooks, err := h.books.GetAll()
+	if utils.IsUnavailable(err) {
+		return events.APIGatewayProxyResponse{}, err
+	}
+
+	if err != nil {
+		return events.APIGatewayProxyResponse{}, apierror.New(500, apierror.InternalError, "Something went wrong while retrieving books from database")
+	}
+
+	response := BooksResponse{Books: *storedBooks}
+	if result != nil {
+		response.Failed = result.Failed
+		response.Skipped = result.Skipped
+		response.Summary = &result.Summary
+	}
+
+	response.Stored = stored
+
+	if includeProvenance {
+		response.ShowProvenance()
+	}
+
+	json, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+}
+
+func retrieveWorkingMode(request events.APIGatewayProxyRequest) WorkingMode {
+	value, _ := request.QueryStringParameters["mode"]
+	return WorkingModeFromString(value)
+}
+
+func retrieveForceRefresh(request events.APIGatewayProxyRequest) bool {
+	forceRefresh, _ := strconv.ParseBool(request.QueryStringParameters["force_refresh"])
+	return forceRefresh
+}
+
+func retrieveIncludeProvenance(request events.APIGatewayProxyRequest) bool {
+	includeProvenance, _ := strconv.ParseBool(request.QueryStringParameters["provenance"])
+	return includeProvenance
+}
@@ -0,0 +1,493 @@
+package scrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/model"
+	"github.com/jinzhu/gorm"
+	null "gopkg.in/guregu/null.v3"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrieveWorkingMode(t *testing.T) {
+	expected := RetrieveAll
+	actual := retrieveWorkingMode(events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, expected, actual)
+}
+
+func TestRetrieveAllStoredBooksSucceeds(t *testing.T) {
+	books := storedSampleBooks()
+
+	booksResponse := model.Books{
+		NumberBooks: uint(len(books)),
+		Books:       books,
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository(books...)).retrieveAllStoredBooks(false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestRetrieveAllStoredBooksIncludesProvenance(t *testing.T) {
+	books := model.NewInMemoryBookRepository(model.Book{
+		Title:      "Some book",
+		Provenance: model.Provenance{SourceName: null.StringFrom("kotlinlang.org"), DetailURL: null.StringFrom("https://kotlinlang.org/book.html")},
+	})
+
+	expectedBody := `{"numberBooks":1,"books":[{"id":1,"isbn":null,"title":"Some book","description":"","language":"",` +
+		`"provenance":{"sourceName":"kotlinlang.org","sourceUrl":null,"detailUrl":"https://kotlinlang.org/book.html",` +
+		`"firstSeenAt":null,"lastSeenAt":null,"contentHash":null}}]}`
+
+	actualResponse, actualError := NewHandler(books).retrieveAllStoredBooks(true)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, expectedBody, actualResponse.Body)
+}
+
+func TestRetrieveAllStoredBooksFails(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\"").
+		WillReturnError(errors.New("database error"))
+
+	expectedError := apierror.New(500, apierror.InternalError, "Something went wrong while retrieving books from database")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := NewHandler(model.NewGormBookRepository(gormDB)).retrieveAllStoredBooks(false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapAndStoreBooksThenReturnSucceeds(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	books := storedSampleBooks()
+	repository := model.NewInMemoryBookRepository()
+
+	booksResponse := BooksResponse{
+		Books: model.Books{
+			NumberBooks: uint(len(books)),
+			Books:       books,
+		},
+		Summary: &ScrapSummary{Succeeded: 3},
+		Stored:  &model.UpsertSummary{Inserted: 3},
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(repository).scrapAndStoreBooksThenReturn(testScraper, ts.URL+"/index.html", keepExisting, false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+
+	for _, book := range books {
+		storedBook, _ := repository.FindByID(book.ID)
+
+		assert.Equal(t, null.StringFrom("kotlinlang.org"), storedBook.SourceName)
+		assert.Equal(t, null.StringFrom(ts.URL+"/index.html"), storedBook.SourceURL)
+		assert.Equal(t, book.DetailURL, storedBook.DetailURL)
+		assert.Equal(t, null.StringFrom(book.HashContent()), storedBook.ContentHash)
+		assert.True(t, storedBook.FirstSeenAt.Valid)
+		assert.Equal(t, storedBook.FirstSeenAt, storedBook.LastSeenAt)
+	}
+}
+
+func TestScrapAndStoreBooksThenReturnFailsToScrapBooks(t *testing.T) {
+	expectedError := apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).scrapAndStoreBooksThenReturn(testScraper, "not_a_url", keepExisting, false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapAndStoreBooksThenReturnFailsDueToDatabase(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	books := sampleBooksUsedInLocalWebsite
+
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+	mock.
+		ExpectQuery("INSERT INTO books (.+) ON CONFLICT DO NOTHING RETURNING normalized_title").
+		WithArgs(books[0].ISBN.String, books[0].Title, books[0].Description, books[0].Language, kotlinSourceName,
+			ts.URL+"/index.html", books[0].DetailURL.String, sqlmock.AnyArg(), sqlmock.AnyArg(), books[0].HashContent(),
+			books[0].ISBN.String, "awesome book number one",
+			books[1].ISBN.String, books[1].Title, books[1].Description, books[1].Language, kotlinSourceName,
+			ts.URL+"/index.html", books[1].DetailURL.String, sqlmock.AnyArg(), sqlmock.AnyArg(), books[1].HashContent(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(),
+			books[2].ISBN.String, books[2].Title, books[2].Description, books[2].Language, kotlinSourceName,
+			ts.URL+"/index.html", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), books[2].HashContent(),
+			sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnError(errors.New("database error"))
+	mock.ExpectRollback()
+
+	expectedError := apierror.New(500, apierror.InternalError, "Something went wrong while storing scrapped books")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := NewHandler(model.NewGormBookRepository(gormDB)).scrapAndStoreBooksThenReturn(testScraper, ts.URL+"/index.html", keepExisting, false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestScrapDiffAndReturnSucceeds(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	books := sampleBooksUsedInLocalWebsite
+
+	storedBook := books[1]
+	storedBook.ID = 2
+	storedBook.ISBN = null.StringFrom("9781234567890")
+
+	repository := model.NewInMemoryBookRepository(books[0], storedBook)
+
+	diff := BooksDiff{
+		New: []BookDiff{
+			BookDiff{Book: books[2], Diffs: []FieldDiff{
+				FieldDiff{Field: "description", Stored: "", Scrapped: books[2].Description},
+				FieldDiff{Field: "isbn", Stored: "", Scrapped: books[2].ISBN.String},
+				FieldDiff{Field: "language", Stored: "", Scrapped: books[2].Language},
+			}},
+		},
+		Changed: []BookDiff{
+			BookDiff{Book: storedBook, Diffs: []FieldDiff{
+				FieldDiff{Field: "isbn", Stored: "9781234567890", Scrapped: "Unavailable"},
+			}},
+		},
+		Removed: []BookDiff{},
+		Failed:  []FailedBook{},
+		Skipped: []SkippedLink{},
+		Summary: &ScrapSummary{Succeeded: 3},
+	}
+
+	diffJSON, _ := json.Marshal(&diff)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(diffJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(repository).scrapDiffAndReturn(testScraper, ts.URL+"/index.html")
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapDiffAndReturnLeavesOutBooksFromOtherSources(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	createdBook := model.Book{
+		Title:      "Book created through the API",
+		ISBN:       null.StringFrom("9781234567890"),
+		Language:   "EN",
+		Provenance: model.Provenance{SourceName: null.StringFrom(model.SourceAPI)},
+	}
+
+	repository := model.NewInMemoryBookRepository(append(sampleBooksUsedInLocalWebsite, createdBook)...)
+
+	response, err := NewHandler(repository).scrapDiffAndReturn(testScraper, ts.URL+"/index.html")
+	assert.Equal(t, nil, err)
+
+	var diff BooksDiff
+	json.Unmarshal([]byte(response.Body), &diff)
+	assert.Equal(t, []BookDiff{}, diff.New)
+	assert.Equal(t, []BookDiff{}, diff.Changed)
+	assert.Equal(t, []BookDiff{}, diff.Removed)
+}
+
+func TestScrapDiffAndReturnMatchesBooksCreatedThroughTheAPI(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	createdBook := sampleBooksUsedInLocalWebsite[0]
+	createdBook.ISBN = null.String{}
+	createdBook.Provenance = model.Provenance{SourceName: null.StringFrom(model.SourceAPI)}
+
+	repository := model.NewInMemoryBookRepository(append([]model.Book{createdBook}, sampleBooksUsedInLocalWebsite[1:]...)...)
+
+	response, err := NewHandler(repository).scrapDiffAndReturn(testScraper, ts.URL+"/index.html")
+	assert.Equal(t, nil, err)
+
+	var diff BooksDiff
+	json.Unmarshal([]byte(response.Body), &diff)
+	assert.Equal(t, []BookDiff{}, diff.New)
+	assert.Equal(t, 1, len(diff.Changed))
+	assert.Equal(t, createdBook.Title, diff.Changed[0].Book.Title)
+	assert.Equal(t, []FieldDiff{FieldDiff{Field: "isbn", Stored: "", Scrapped: sampleBooksUsedInLocalWebsite[0].ISBN.String}}, diff.Changed[0].Diffs)
+	assert.Equal(t, []BookDiff{}, diff.Removed)
+}
+
+func TestScrapDiffAndReturnFailsToScrapBooks(t *testing.T) {
+	expectedError := apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).scrapDiffAndReturn(testScraper, "not_a_url")
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapDiffAndReturnFailsDueToDatabase(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"books\"").
+		WillReturnError(errors.New("database error"))
+
+	expectedError := apierror.New(500, apierror.InternalError, "Something went wrong while retrieving books from database")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := NewHandler(model.NewGormBookRepository(gormDB)).scrapDiffAndReturn(testScraper, ts.URL+"/index.html")
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapBooksAndReturnSucceeds(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	books := sampleBooksUsedInLocalWebsite
+
+	booksResponse := BooksResponse{
+		Books: model.Books{
+			NumberBooks: uint(len(books)),
+			Books:       books,
+		},
+		Summary: &ScrapSummary{Succeeded: 3},
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := scrapBooksAndReturn(testScraper, ts.URL+"/index.html", false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestScrapBooksAndReturnFailsToScrapBooks(t *testing.T) {
+	expectedError := apierror.New(500, apierror.ScrapFailed, "Something went wrong while searching for books")
+	expectedResponse := events.APIGatewayProxyResponse{}
+
+	actualResponse, actualError := scrapBooksAndReturn(testScraper, "not_a_url", false)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHandlerScrapOnly(t *testing.T) {
+	request := events.APIGatewayProxyRequest{}
+	request.QueryStringParameters = make(map[string]string)
+	request.QueryStringParameters["mode"] = "scrap_only"
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+
+	books := sampleBooksUsedInLocalWebsite
+
+	booksResponse := BooksResponse{
+		Books: model.Books{
+			NumberBooks: uint(len(books)),
+			Books:       books,
+		},
+		Summary: &ScrapSummary{Succeeded: 3},
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHandlerScrapAndStore(t *testing.T) {
+	request := events.APIGatewayProxyRequest{}
+	request.QueryStringParameters = make(map[string]string)
+	request.QueryStringParameters["mode"] = "scrap_and_store"
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+
+	books := storedSampleBooks()
+
+	booksResponse := BooksResponse{
+		Books: model.Books{
+			NumberBooks: uint(len(books)),
+			Books:       books,
+		},
+		Summary: &ScrapSummary{Succeeded: 3},
+		Stored:  &model.UpsertSummary{Inserted: 3},
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHandlerScrapAndStoreMergesStoredBooks(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "scrap_and_store", "merge": "fill_empty"},
+	}
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+
+	storedBook := storedSampleBooks()[0]
+	storedBook.ISBN = null.StringFrom(model.UnavailableISBN)
+	repository := model.NewInMemoryBookRepository(storedBook)
+
+	actualResponse, actualError := NewHandler(repository).Handle(context.Background(), request)
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, 200, actualResponse.StatusCode)
+
+	response := BooksResponse{}
+	json.Unmarshal([]byte(actualResponse.Body), &response)
+	assert.Equal(t, &model.UpsertSummary{Inserted: 2, Updated: 1, Changes: []model.BookChanges{{
+		Title:  storedBook.Title,
+		Fields: []model.FieldChange{{Field: "isbn", Old: model.UnavailableISBN, New: sampleBooksISBNs[0]}},
+	}}}, response.Stored)
+
+	mergedBook, _ := repository.FindByID(storedBook.ID)
+	assert.Equal(t, null.StringFrom(sampleBooksISBNs[0]), mergedBook.ISBN)
+}
+
+func TestHandlerAnswersWithWorkingModeFailure(t *testing.T) {
+	kotlinBooksURL = "not_a_url"
+
+	request := events.APIGatewayProxyRequest{
+		Headers:               map[string]string{"X-Request-Id": "abc"},
+		QueryStringParameters: map[string]string{"mode": "scrap_only"},
+	}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":500,"code":"SCRAP_FAILED","message":"Something went wrong while searching for books","requestId":"abc"}}`,
+		StatusCode: 500,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHandlerFailsMergeStrategyIsInvalid(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "scrap_and_store", "merge": "whatever"},
+	}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"Merge strategy must be one of keep_existing, overwrite, fill_empty or prefer_source"}}`,
+		StatusCode: 400,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestHandlerRetrieveAll(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+
+	books := storedSampleBooks()
+
+	booksResponse := model.Books{
+		NumberBooks: uint(len(books)),
+		Books:       books,
+	}
+
+	booksResponseJSON, _ := json.Marshal(&booksResponse)
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       string(booksResponseJSON),
+		StatusCode: 200,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository(books...)).Handle(context.Background(), events.APIGatewayProxyRequest{})
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
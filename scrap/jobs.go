@@ -0,0 +1,216 @@
+package scrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
+	"github.com/felipefill/books/utils"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultJobStaleAfter is how long a running job may go without a heartbeat before it's failed, it's well past
+// heartbeatInterval so a slow database doesn't fail jobs that are still running
+const defaultJobStaleAfter = 5 * time.Minute
+
+// heartbeatInterval is how often a running job tells it's still alive
+var heartbeatInterval = 30 * time.Second
+
+// scrapJobResponse is how a job is presented when its status is requested, result is the working mode's response body
+type scrapJobResponse struct {
+	model.ScrapJob
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+func (h *Handler) enqueueScrapJob(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	workingMode := retrieveWorkingMode(request)
+	if workingMode == RetrieveAll {
+		return apierror.New(400, apierror.InvalidParameter, `"mode" must be one of scrap_only, scrap_and_store or scrap_diff`).Response(request), nil
+	}
+
+	policy, err := model.MergePolicyFromQuery(request.QueryStringParameters)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidParameter, err.Error()).Response(request), nil
+	}
+
+	db, err := utils.GetDB()
+	if err != nil {
+		return utils.UnavailableResponse(request, err), nil
+	}
+
+	job := model.ScrapJob{
+		Mode:         workingMode.String(),
+		ForceRefresh: retrieveForceRefresh(request),
+		Merge:        string(policy.Strategy),
+		Provenance:   retrieveIncludeProvenance(request),
+	}
+	if err := job.Create(db); err != nil {
+		return apierror.New(500, apierror.InternalError, "Something went wrong while creating scrap job").Response(request), nil
+	}
+
+	// Locally there's no worker function, so the job runs in background right away
+	if os.Getenv("SCRAP_JOBS_RUNNER") == "inline" {
+		failStaleScrapJobs(db)
+		go func() {
+			if err := h.startAndRunScrapJob(db, job); err != nil {
+				log.Printf("Scrap job %d failed: %s", job.ID, err.Error())
+			}
+		}()
+	}
+
+	return events.APIGatewayProxyResponse{
+		Body:       fmt.Sprintf(`{"job_id": %d}`, job.ID),
+		StatusCode: 202,
+		Headers:    map[string]string{"Location": fmt.Sprintf("/scrap/jobs/%d", job.ID)},
+	}, nil
+}
+
+func retrieveScrapJob(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id, err := strconv.ParseUint(request.PathParameters["id"], 10, 32)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidParameter, `"id" parameter must be an integer`).Response(request), nil
+	}
+
+	db, err := utils.GetDB()
+	if err != nil {
+		return utils.UnavailableResponse(request, err), nil
+	}
+
+	job, err := model.FindScrapJobByID(db, uint(id))
+	if err != nil {
+		return apierror.New(500, apierror.InternalError, "Something went wrong while retrieving scrap job").Response(request), nil
+	}
+
+	if job == nil {
+		return apierror.New(404, apierror.ScrapJobNotFound, fmt.Sprintf("No scrap job with ID %d", id)).Response(request), nil
+	}
+
+	response := scrapJobResponse{ScrapJob: *job}
+	if job.Result.Valid {
+		response.Result = json.RawMessage(job.Result.String)
+	}
+
+	json, _ := json.Marshal(response)
+	return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+}
+
+func (h *Handler) startAndRunScrapJob(db *gorm.DB, job model.ScrapJob) error {
+	started, err := job.Start(db)
+	if err != nil || !started {
+		return err
+	}
+
+	return h.runScrapJob(db, &job)
+}
+
+// runScrapJob runs job in a trace of its own, exported as soon as it's done since jobs run outside of requests
+func (h *Handler) runScrapJob(db *gorm.DB, job *model.ScrapJob) error {
+	span := tracing.Default.Start("scrap job "+job.Mode, tracing.KindInternal, trace.SpanContext{})
+	span.SetAttribute("scrap.job_id", int(job.ID))
+	span.SetAttribute("scrap.mode", job.Mode)
+	defer func() {
+		span.End()
+		if err := tracing.Default.Flush(); err != nil {
+			log.Printf("Could not export spans: %s", err.Error())
+		}
+	}()
+
+	stopHeartbeat := startHeartbeat(db, job)
+	defer stopHeartbeat()
+
+	scraper := NewScraper(job.ForceRefresh)
+	scraper.Span = span
+	scraper.Progress = func(progress string) {
+		if err := job.UpdateProgress(db, progress); err != nil {
+			log.Printf("Could not update progress of scrap job %d: %s", job.ID, err.Error())
+		}
+	}
+
+	response, err := h.traced(span).runWorkingMode(scraper, WorkingModeFromString(job.Mode), kotlinBooksURL, job.MergePolicy(), job.Provenance)
+	span.SetError(err)
+	if err != nil {
+		return job.Finish(db, "", fmt.Errorf("Working mode %s failed: %s", job.Mode, err.Error()))
+	}
+
+	return job.Finish(db, response.Body, nil)
+}
+
+// startHeartbeat keeps telling job is alive until the returned function is called, see model.FailStaleScrapJobs
+func startHeartbeat(db *gorm.DB, job *model.ScrapJob) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(heartbeatInterval)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := job.Heartbeat(db); err != nil {
+					log.Printf("Could not send heartbeat of scrap job %d: %s", job.ID, err.Error())
+				}
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
+
+// failStaleScrapJobs fails running jobs whose worker is gone, those that went longer than SCRAP_JOB_STALE_AFTER
+// without a heartbeat
+func failStaleScrapJobs(db *gorm.DB) error {
+	failed, err := model.FailStaleScrapJobs(db, getDurationEnvOrDefault("SCRAP_JOB_STALE_AFTER", defaultJobStaleAfter))
+	if err != nil {
+		log.Printf("Could not fail stale scrap jobs: %s", err.Error())
+		return err
+	}
+
+	if failed > 0 {
+		log.Printf("Failed %d stale scrap jobs", failed)
+	}
+
+	return nil
+}
+
+// Work fails stale scrap jobs then runs pending ones until there are none left, it's invoked periodically by its own
+// lambda function
+func (h *Handler) Work() error {
+	db, err := utils.GetDB()
+	if err != nil {
+		return err
+	}
+
+	if err := failStaleScrapJobs(db); err != nil {
+		return err
+	}
+
+	for {
+		job, err := model.ClaimNextPendingScrapJob(db)
+		if err != nil {
+			return err
+		}
+
+		if job == nil {
+			return nil
+		}
+
+		// A job failed as stale while it was running keeps that outcome, the others still have to run
+		err = h.runScrapJob(db, job)
+		if err == model.ErrScrapJobNotRunning {
+			log.Printf("Scrap job %d was not running anymore when it finished", job.ID)
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package scrap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/utils"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnqueueScrapJobSucceeds(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.
+		ExpectQuery("INSERT INTO \"scrap_jobs\" (.+)").
+		WithArgs("scrap_and_store", false, "keep_existing", false, model.JobPending, sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	request := events.APIGatewayProxyRequest{
+		Resource:              "/scrap/jobs",
+		HTTPMethod:            "POST",
+		QueryStringParameters: map[string]string{"mode": "scrap_and_store"},
+	}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"job_id": 7}`,
+		StatusCode: 202,
+		Headers:    map[string]string{"Location": "/scrap/jobs/7"},
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestEnqueueScrapJobKeepsMergeAndProvenance(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.
+		ExpectQuery("INSERT INTO \"scrap_jobs\" (.+)").
+		WithArgs("scrap_and_store", true, "fill_empty", true, model.JobPending, sqlmock.AnyArg(), nil, nil, sqlmock.AnyArg(), nil, nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "scrap_and_store", "force_refresh": "true", "merge": "fill_empty", "provenance": "true"},
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).enqueueScrapJob(request)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, 202, actualResponse.StatusCode)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestEnqueueScrapJobFailsInvalidMerge(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "scrap_and_store", "merge": "newest"},
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).enqueueScrapJob(request)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, 400, actualResponse.StatusCode)
+	assert.Contains(t, actualResponse.Body, "INVALID_PARAMETER")
+}
+
+func TestEnqueueScrapJobFailsInvalidMode(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Resource:              "/scrap/jobs",
+		HTTPMethod:            "POST",
+		QueryStringParameters: map[string]string{"mode": "retrieve_all"},
+	}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"mode\" must be one of scrap_only, scrap_and_store or scrap_diff"}}`,
+		StatusCode: 400,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).enqueueScrapJob(request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestEnqueueScrapJobFailsDueToDatabase(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.
+		ExpectQuery("INSERT INTO \"scrap_jobs\" (.+)").
+		WillReturnError(errors.New("database error"))
+
+	request := events.APIGatewayProxyRequest{
+		QueryStringParameters: map[string]string{"mode": "scrap_only"},
+	}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":500,"code":"INTERNAL_ERROR","message":"Something went wrong while creating scrap job"}}`,
+		StatusCode: 500,
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).enqueueScrapJob(request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestRetrieveScrapJobFindsJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WithArgs(7).
+		WillReturnRows(
+			sqlmock.NewRows([]string{"id", "mode", "status", "progress", "result"}).
+				AddRow(7, "scrap_only", "succeeded", "Done", `{"numberBooks":0,"books":[]}`),
+		)
+
+	request := events.APIGatewayProxyRequest{
+		Resource:       "/scrap/jobs/{id}",
+		HTTPMethod:     "GET",
+		PathParameters: map[string]string{"id": "7"},
+	}
+
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
+
+	var actualBody map[string]interface{}
+	_ = json.Unmarshal([]byte(actualResponse.Body), &actualBody)
+
+	assert.Equal(t, nil, actualError)
+	assert.Equal(t, 200, actualResponse.StatusCode)
+	assert.Equal(t, float64(7), actualBody["id"])
+	assert.Equal(t, "succeeded", actualBody["status"])
+	assert.Equal(t, "Done", actualBody["progress"])
+	assert.Equal(t, map[string]interface{}{"numberBooks": float64(0), "books": []interface{}{}}, actualBody["result"])
+}
+
+func TestRetrieveScrapJobDoesNotFindJob(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WithArgs(7).
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "7"}}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":404,"code":"SCRAP_JOB_NOT_FOUND","message":"No scrap job with ID 7"}}`,
+		StatusCode: 404,
+	}
+
+	actualResponse, actualError := retrieveScrapJob(request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestRetrieveScrapJobFailsIDNotInt(t *testing.T) {
+	request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "not_int"}}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"id\" parameter must be an integer"}}`,
+		StatusCode: 400,
+	}
+
+	actualResponse, actualError := retrieveScrapJob(request)
+
+	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedResponse, actualResponse)
+}
+
+func TestRunScrapJobSucceeds(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+	gormDB, _ := gorm.Open("postgres", db)
+
+	books := sampleBooksUsedInLocalWebsite
+	booksResponseJSON, _ := json.Marshal(&BooksResponse{
+		Books:   model.Books{NumberBooks: uint(len(books)), Books: books},
+		Summary: &ScrapSummary{Succeeded: 3},
+	})
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WithArgs(sqlmock.AnyArg(), "Scrapping detail pages of 3 books", 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WithArgs(nil, sqlmock.AnyArg(), "Done", string(booksResponseJSON), model.JobSucceeded, 3, model.JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := model.ScrapJob{ID: 3, Mode: "scrap_only", Status: model.JobRunning}
+	err := NewHandler(model.NewInMemoryBookRepository()).runScrapJob(gormDB, &job)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, model.JobSucceeded, job.Status)
+	assert.Equal(t, string(booksResponseJSON), job.Result.String)
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
+
+func TestRunScrapJobIncludesProvenanceItWasCreatedWith(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	ts := createTestServer()
+	defer ts.Close()
+
+	kotlinBooksURL = ts.URL + "/index.html"
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := model.ScrapJob{ID: 3, Mode: "scrap_only", Provenance: true, Status: model.JobRunning}
+	err := NewHandler(model.NewInMemoryBookRepository()).runScrapJob(gormDB, &job)
+
+	assert.Equal(t, nil, err)
+	assert.Contains(t, job.Result.String, `"provenance"`)
+}
+
+func TestRunScrapJobFailsWhenJobIsNotRunningAnymore(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	kotlinBooksURL = "not_a_url"
+	gormDB, _ := gorm.Open("postgres", db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	job := model.ScrapJob{ID: 3, Mode: "scrap_only", Status: model.JobRunning}
+	err := NewHandler(model.NewInMemoryBookRepository()).runScrapJob(gormDB, &job)
+
+	assert.Equal(t, model.ErrScrapJobNotRunning, err)
+}
+
+func TestRunScrapJobFails(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	kotlinBooksURL = "not_a_url"
+	gormDB, _ := gorm.Open("postgres", db)
+
+	expectedFailure := "Working mode scrap_only failed: Something went wrong while searching for books"
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+)").
+		WithArgs(expectedFailure, sqlmock.AnyArg(), "Failed", nil, model.JobFailed, 3, model.JobRunning).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	job := model.ScrapJob{ID: 3, Mode: "scrap_only", Status: model.JobRunning}
+	err := NewHandler(model.NewInMemoryBookRepository()).runScrapJob(gormDB, &job)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, model.JobFailed, job.Status)
+	assert.Equal(t, expectedFailure, job.Error.String)
+}
+
+func TestRunScrapJobSendsHeartbeats(t *testing.T) {
+	defer func(previous time.Duration) { heartbeatInterval = previous }(heartbeatInterval)
+	heartbeatInterval = time.Millisecond
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	db.DB().SetMaxOpenConns(1)
+	db.AutoMigrate(&model.ScrapJob{})
+
+	job := model.ScrapJob{Mode: "scrap_only"}
+	job.Create(db)
+	job.Start(db)
+	db.Model(&model.ScrapJob{}).Where("id = ?", job.ID).Update("heartbeat_at", time.Now().Add(-time.Hour))
+
+	stop := startHeartbeat(db, &job)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+
+	storedJob, _ := model.FindScrapJobByID(db, job.ID)
+	assert.True(t, time.Since(storedJob.HeartbeatAt.Time) < time.Minute)
+}
+
+func TestWorkFailsStaleJobs(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	utils.InjectDB(db)
+
+	mock.ExpectBegin()
+	mock.
+		ExpectExec("UPDATE \"scrap_jobs\" SET (.+) WHERE \\(status = \\$5 AND \\(heartbeat_at < \\$6 OR \\(heartbeat_at IS NULL AND started_at < \\$7\\)\\)\\)").
+		WithArgs("Worker stopped responding for more than 5m0s", sqlmock.AnyArg(), "Failed", model.JobFailed, model.JobRunning, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.
+		ExpectQuery("SELECT (.+) FROM \"scrap_jobs\" (.+)").
+		WillReturnError(gorm.ErrRecordNotFound)
+
+	assert.Equal(t, nil, NewHandler(model.NewInMemoryBookRepository()).Work())
+	assert.Equal(t, nil, mock.ExpectationsWereMet())
+}
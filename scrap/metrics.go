@@ -0,0 +1,62 @@
+package scrap
+
+import (
+	"github.com/felipefill/books/metrics"
+	"github.com/felipefill/books/model"
+)
+
+var pagesTotal = metrics.NewCounter("books_scrap_pages_total", "Pages scrapped, by page (index or detail) and outcome (fetched, failed or skipped)", "page", "outcome")
+var isbnsTotal = metrics.NewCounter("books_scrap_isbns_total", "ISBNs looked for in scrapped books, by result (found or unavailable)", "result")
+var booksStoredPerRun = metrics.NewHistogram("books_scrap_books_stored_per_run", "Scrapped books stored by each scrap_and_store run, by result (inserted, updated, unchanged or duplicate)", metrics.Count, []float64{0, 1, 5, 10, 25, 50, 100, 250}, "result")
+
+// Page kinds and outcomes of books_scrap_pages_total
+const (
+	indexPage  = "index"
+	detailPage = "detail"
+
+	pageFetched = "fetched"
+	pageFailed  = "failed"
+	pageSkipped = "skipped"
+)
+
+func recordIndexPage(err error) {
+	if err != nil {
+		pagesTotal.Inc(indexPage, pageFailed)
+		return
+	}
+
+	pagesTotal.Inc(indexPage, pageFetched)
+}
+
+func recordDetailPages(outcomes []detailPageOutcome) {
+	for _, outcome := range outcomes {
+		switch {
+		case outcome.Link == "":
+			continue
+		case outcome.SkipReason != "":
+			pagesTotal.Inc(detailPage, pageSkipped)
+		case outcome.Err != nil:
+			pagesTotal.Inc(detailPage, pageFailed)
+		default:
+			pagesTotal.Inc(detailPage, pageFetched)
+		}
+	}
+}
+
+func recordISBNs(books []model.Book) {
+	for _, book := range books {
+		if !book.ISBN.Valid || book.ISBN.String == model.UnavailableISBN {
+			isbnsTotal.Inc("unavailable")
+			continue
+		}
+
+		isbnsTotal.Inc("found")
+	}
+}
+
+func recordStored(stored model.UpsertSummary) {
+	booksStoredPerRun.Observe(float64(stored.Inserted), "inserted")
+	booksStoredPerRun.Observe(float64(stored.Updated), "updated")
+	booksStoredPerRun.Observe(float64(stored.Unchanged), "unchanged")
+	booksStoredPerRun.Observe(float64(stored.Duplicates), "duplicate")
+}
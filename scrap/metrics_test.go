@@ -0,0 +1,49 @@
+package scrap
+
+import (
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindKotlinBooksRecordsPagesAndISBNs(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	indexFetched := pagesTotal.Value(indexPage, pageFetched)
+	indexFailed := pagesTotal.Value(indexPage, pageFailed)
+	detailFetched := pagesTotal.Value(detailPage, pageFetched)
+	found := isbnsTotal.Value("found")
+	unavailable := isbnsTotal.Value("unavailable")
+
+	result, _ := testScraper.FindKotlinBooks(ts.URL + "/index.html")
+	testScraper.FindKotlinBooks("not_a_url")
+
+	expectedUnavailable, expectedDetails := 0.0, 0.0
+	for _, book := range result.Books {
+		if book.ISBN.String == model.UnavailableISBN {
+			expectedUnavailable++
+		}
+
+		if book.DetailURL.Valid {
+			expectedDetails++
+		}
+	}
+
+	assert.Equal(t, indexFetched+1, pagesTotal.Value(indexPage, pageFetched))
+	assert.Equal(t, indexFailed+1, pagesTotal.Value(indexPage, pageFailed))
+	assert.Equal(t, detailFetched+expectedDetails, pagesTotal.Value(detailPage, pageFetched))
+	assert.Equal(t, unavailable+expectedUnavailable, isbnsTotal.Value("unavailable"))
+	assert.Equal(t, found+3-expectedUnavailable, isbnsTotal.Value("found"))
+}
+
+func TestRecordStoredObservesEachResult(t *testing.T) {
+	inserted := booksStoredPerRun.Count("inserted")
+	unchanged := booksStoredPerRun.Count("unchanged")
+
+	recordStored(model.UpsertSummary{Inserted: 3})
+
+	assert.Equal(t, inserted+1, booksStoredPerRun.Count("inserted"))
+	assert.Equal(t, unchanged+1, booksStoredPerRun.Count("unchanged"))
+}
@@ -0,0 +1,157 @@
+package scrap
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var defaultUserAgent = "BooksScraper/1.0"
+var defaultContactURL = "https://github.com/felipefill/books"
+var defaultDelay = 500 * time.Millisecond
+var defaultParallelism = 2
+
+// ScraperPolicy describes how politely the scraper behaves towards the websites it visits
+type ScraperPolicy struct {
+	// UserAgent identifies the scraper, it should include a way to contact us
+	UserAgent string
+
+	// RespectRobotsTxt makes the scraper skip pages disallowed by robots.txt
+	RespectRobotsTxt bool
+
+	// Delay is how long to wait between requests to the same domain
+	Delay time.Duration
+
+	// Parallelism is the maximum number of simultaneous requests to the same domain, zero disables both limits
+	Parallelism int
+
+	// AllowedDomains are the only domains that may be visited, empty means any domain
+	AllowedDomains []string
+}
+
+// NewScraperPolicyFromEnv creates a ScraperPolicy from SCRAP_* environment variables, falling back to polite defaults
+func NewScraperPolicyFromEnv() ScraperPolicy {
+	userAgent := getEnvOrDefault("SCRAP_USER_AGENT", defaultUserAgent)
+	contactURL := getEnvOrDefault("SCRAP_CONTACT_URL", defaultContactURL)
+
+	parallelism, err := strconv.Atoi(os.Getenv("SCRAP_PARALLELISM"))
+	if err != nil || parallelism < 0 {
+		parallelism = defaultParallelism
+	}
+
+	allowedDomains := make([]string, 0)
+	for _, domain := range strings.Split(os.Getenv("SCRAP_ALLOWED_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			allowedDomains = append(allowedDomains, domain)
+		}
+	}
+
+	return ScraperPolicy{
+		UserAgent:        fmt.Sprintf("%s (+%s)", userAgent, contactURL),
+		RespectRobotsTxt: os.Getenv("SCRAP_IGNORE_ROBOTS_TXT") != "true",
+		Delay:            getDurationEnvOrDefault("SCRAP_DELAY", defaultDelay),
+		Parallelism:      parallelism,
+		AllowedDomains:   allowedDomains,
+	}
+}
+
+// politeTransport is an http.RoundTripper that applies policy's delay and parallelism to each domain separately
+type politeTransport struct {
+	policy    ScraperPolicy
+	transport http.RoundTripper
+
+	lock  sync.Mutex
+	slots map[string]chan bool
+}
+
+func newPoliteTransport(policy ScraperPolicy, transport http.RoundTripper) *politeTransport {
+	return &politeTransport{
+		policy:    policy,
+		transport: transport,
+		slots:     make(map[string]chan bool),
+	}
+}
+
+// RoundTrip waits for a free slot in request's domain, the slot is held until response's body is closed then for Delay
+func (t *politeTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	// colly doesn't set User-Agent when it downloads robots.txt
+	if request.Header.Get("User-Agent") == "" && t.policy.UserAgent != "" {
+		request = cloneRequest(request)
+		request.Header.Set("User-Agent", t.policy.UserAgent)
+	}
+
+	slots := t.domainSlots(request.URL.Hostname())
+	if slots == nil {
+		return t.transport.RoundTrip(request)
+	}
+
+	slots <- true
+	response, err := t.transport.RoundTrip(request)
+	if err != nil {
+		t.release(slots)
+		return nil, err
+	}
+
+	// Body is still being downloaded from the domain until it's closed
+	response.Body = &releaseOnClose{ReadCloser: response.Body, release: func() { t.release(slots) }}
+	return response, nil
+}
+
+// release frees a slot once Delay went by, caller doesn't need to wait, only the next request to its domain does
+func (t *politeTransport) release(slots chan bool) {
+	go func() {
+		time.Sleep(t.policy.Delay)
+		<-slots
+	}()
+}
+
+func (t *politeTransport) domainSlots(domain string) chan bool {
+	if t.policy.Parallelism <= 0 {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	slots, ok := t.slots[domain]
+	if !ok {
+		slots = make(chan bool, t.policy.Parallelism)
+		t.slots[domain] = slots
+	}
+
+	return slots
+}
+
+// releaseOnClose releases a slot of politeTransport when body is closed, closing it again releases nothing
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (body *releaseOnClose) Close() error {
+	defer body.once.Do(body.release)
+	return body.ReadCloser.Close()
+}
+
+func getEnvOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+func getDurationEnvOrDefault(name string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
@@ -0,0 +1,225 @@
+package scrap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var politeTestBookPage = "<html><body>ISBN 978-3-16-148410-0 is the identifier of this book</body></html>"
+
+// createPoliteTestServer serves an index linking to given detail pages and a robots.txt disallowing /private/,
+// links without a host point to the server itself
+func createPoliteTestServer(userAgents *[]string, detailLinks ...string) *httptest.Server {
+	var lock sync.Mutex
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		*userAgents = append(*userAgents, r.Header.Get("User-Agent"))
+		lock.Unlock()
+
+		switch {
+		case r.URL.Path == "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private/\n"))
+		case r.URL.Path == "/index.html":
+			index := "<html><body><article>"
+			for i, link := range detailLinks {
+				if !strings.HasPrefix(link, "http") {
+					link = "http://" + r.Host + link
+				}
+				index += fmt.Sprintf(`<h2>Book %d</h2><a href="%s">Book %d</a><p>Description %d</p><div>en</div>`, i, link, i, i)
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(index + "</article></body></html>"))
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(politeTestBookPage))
+		}
+	}))
+}
+
+func TestScraperSkipsLinksDisallowedByRobotsTxt(t *testing.T) {
+	userAgents := make([]string, 0)
+	ts := createPoliteTestServer(&userAgents, "/public/book.html", "/private/book.html")
+	defer ts.Close()
+
+	scraper := &Scraper{Policy: ScraperPolicy{UserAgent: "TestBot/1.0 (+http://example.com)", RespectRobotsTxt: true}}
+
+	result, err := scraper.FindKotlinBooks(ts.URL + "/index.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 3, len(userAgents)) // robots.txt, index and the public book
+	assert.Equal(t, "9783161484100", result.Books[0].ISBN.String)
+	assert.Equal(t, "Unavailable", result.Books[1].ISBN.String)
+	assert.Equal(t, []SkippedLink{
+		SkippedLink{Title: "Book 1", Link: ts.URL + "/private/book.html", Reason: "Disallowed by robots.txt"},
+	}, result.Skipped)
+
+	for _, userAgent := range userAgents {
+		assert.Equal(t, "TestBot/1.0 (+http://example.com)", userAgent)
+	}
+}
+
+func TestScraperIgnoresRobotsTxtWhenAskedTo(t *testing.T) {
+	userAgents := make([]string, 0)
+	ts := createPoliteTestServer(&userAgents, "/private/book.html")
+	defer ts.Close()
+
+	scraper := &Scraper{Policy: ScraperPolicy{RespectRobotsTxt: false}}
+
+	result, err := scraper.FindKotlinBooks(ts.URL + "/index.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "9783161484100", result.Books[0].ISBN.String)
+	assert.Equal(t, []SkippedLink{}, result.Skipped)
+}
+
+func TestScraperSkipsLinksOutsideAllowedDomains(t *testing.T) {
+	userAgents := make([]string, 0)
+	publisher := createPoliteTestServer(&userAgents)
+	defer publisher.Close()
+
+	ts := createPoliteTestServer(&userAgents, publisher.URL+"/book.html")
+	defer ts.Close()
+
+	scraper := &Scraper{Policy: ScraperPolicy{AllowedDomains: []string{strings.TrimPrefix(ts.URL, "http://")}}}
+
+	result, err := scraper.FindKotlinBooks(ts.URL + "/index.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Unavailable", result.Books[0].ISBN.String)
+	assert.Equal(t, []SkippedLink{
+		SkippedLink{Title: "Book 0", Link: publisher.URL + "/book.html", Reason: "Domain is not allowed"},
+	}, result.Skipped)
+}
+
+type countingTransport struct {
+	lock     sync.Mutex
+	current  int
+	maximum  int
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	t.lock.Lock()
+	t.current++
+	t.requests++
+	if t.current > t.maximum {
+		t.maximum = t.current
+	}
+	t.lock.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	t.lock.Lock()
+	t.current--
+	t.lock.Unlock()
+
+	return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+}
+
+func TestPoliteTransportLimitsParallelismPerDomain(t *testing.T) {
+	counter := &countingTransport{}
+	transport := newPoliteTransport(ScraperPolicy{Parallelism: 2}, counter)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request, _ := http.NewRequest("GET", "http://example.com/book.html", nil)
+			response, _ := transport.RoundTrip(request)
+			response.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 6, counter.requests)
+	assert.Equal(t, 2, counter.maximum)
+}
+
+func TestPoliteTransportWaitsBetweenRequestsToSameDomain(t *testing.T) {
+	counter := &countingTransport{}
+	transport := newPoliteTransport(ScraperPolicy{Parallelism: 1, Delay: 50 * time.Millisecond}, counter)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequest("GET", "http://example.com/book.html", nil)
+		response, _ := transport.RoundTrip(request)
+		response.Body.Close()
+	}
+
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+}
+
+func TestPoliteTransportHoldsSlotUntilBodyIsClosed(t *testing.T) {
+	transport := newPoliteTransport(ScraperPolicy{Parallelism: 1}, &countingTransport{})
+
+	request, _ := http.NewRequest("GET", "http://example.com/book.html", nil)
+	first, _ := transport.RoundTrip(request)
+
+	second := make(chan *http.Response)
+	go func() {
+		request, _ := http.NewRequest("GET", "http://example.com/other.html", nil)
+		response, _ := transport.RoundTrip(request)
+		second <- response
+	}()
+
+	select {
+	case <-second:
+		t.Fatal("Second request was sent while the body of the first one was being read")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Body.Close()
+	first.Body.Close()
+
+	select {
+	case response := <-second:
+		response.Body.Close()
+	case <-time.After(time.Second):
+		t.Fatal("Second request was not sent once the body of the first one was closed")
+	}
+}
+
+func TestNewScraperPolicyFromEnv(t *testing.T) {
+	expectedPolicy := ScraperPolicy{
+		UserAgent:        "BooksScraper/1.0 (+https://github.com/felipefill/books)",
+		RespectRobotsTxt: true,
+		Delay:            500 * time.Millisecond,
+		Parallelism:      2,
+		AllowedDomains:   []string{},
+	}
+
+	assert.Equal(t, expectedPolicy, NewScraperPolicyFromEnv())
+
+	os.Setenv("SCRAP_USER_AGENT", "MyBot/2.0")
+	os.Setenv("SCRAP_CONTACT_URL", "mailto:books@example.com")
+	os.Setenv("SCRAP_IGNORE_ROBOTS_TXT", "true")
+	os.Setenv("SCRAP_DELAY", "2s")
+	os.Setenv("SCRAP_PARALLELISM", "4")
+	os.Setenv("SCRAP_ALLOWED_DOMAINS", "kotlinlang.org, www.manning.com")
+	defer os.Unsetenv("SCRAP_USER_AGENT")
+	defer os.Unsetenv("SCRAP_CONTACT_URL")
+	defer os.Unsetenv("SCRAP_IGNORE_ROBOTS_TXT")
+	defer os.Unsetenv("SCRAP_DELAY")
+	defer os.Unsetenv("SCRAP_PARALLELISM")
+	defer os.Unsetenv("SCRAP_ALLOWED_DOMAINS")
+
+	expectedPolicy = ScraperPolicy{
+		UserAgent:        "MyBot/2.0 (+mailto:books@example.com)",
+		RespectRobotsTxt: false,
+		Delay:            2 * time.Second,
+		Parallelism:      4,
+		AllowedDomains:   []string{"kotlinlang.org", "www.manning.com"},
+	}
+
+	assert.Equal(t, expectedPolicy, NewScraperPolicyFromEnv())
+}
@@ -0,0 +1,141 @@
+package scrap
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+var defaultMaxAttempts = 3
+var defaultAttemptTimeout = 10 * time.Second
+var defaultRetryBaseDelay = 500 * time.Millisecond
+var defaultRetryMaxDelay = 10 * time.Second
+
+// RetryPolicy describes how transient failures (timeouts, 5xx and 429) are retried
+type RetryPolicy struct {
+	// MaxAttempts is how many times a request is tried before giving up, zero or one means no retries
+	MaxAttempts int
+
+	// AttemptTimeout is how long a single attempt may take, zero means no timeout
+	AttemptTimeout time.Duration
+
+	// BaseDelay is the wait before the first retry, it doubles for each further retry
+	BaseDelay time.Duration
+
+	// MaxDelay caps both the exponential backoff and Retry-After
+	MaxDelay time.Duration
+}
+
+// NewRetryPolicyFromEnv creates a RetryPolicy from SCRAP_* environment variables, falling back to defaults
+func NewRetryPolicyFromEnv() RetryPolicy {
+	maxAttempts, err := strconv.Atoi(os.Getenv("SCRAP_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = defaultMaxAttempts
+	}
+
+	return RetryPolicy{
+		MaxAttempts:    maxAttempts,
+		AttemptTimeout: getDurationEnvOrDefault("SCRAP_ATTEMPT_TIMEOUT", defaultAttemptTimeout),
+		BaseDelay:      getDurationEnvOrDefault("SCRAP_RETRY_BASE_DELAY", defaultRetryBaseDelay),
+		MaxDelay:       getDurationEnvOrDefault("SCRAP_RETRY_MAX_DELAY", defaultRetryMaxDelay),
+	}
+}
+
+// retryTransport is an http.RoundTripper that retries transient failures with exponential backoff
+type retryTransport struct {
+	policy    RetryPolicy
+	transport http.RoundTripper
+}
+
+// RoundTrip tries request until it succeeds, fails for good or attempts run out, the last outcome is returned
+func (t *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		response, err := t.attempt(request)
+
+		if attempt >= t.policy.MaxAttempts || !isTransientFailure(response, err) {
+			return response, err
+		}
+
+		wait := t.backoff(attempt)
+		if response != nil {
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+				wait = retryAfter
+			}
+
+			response.Body.Close()
+		}
+
+		if t.policy.MaxDelay > 0 && wait > t.policy.MaxDelay {
+			wait = t.policy.MaxDelay
+		}
+
+		time.Sleep(wait)
+	}
+}
+
+func (t *retryTransport) attempt(request *http.Request) (*http.Response, error) {
+	if t.policy.AttemptTimeout <= 0 {
+		return t.transport.RoundTrip(request)
+	}
+
+	ctx, cancel := context.WithTimeout(request.Context(), t.policy.AttemptTimeout)
+
+	response, err := t.transport.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Timeout must keep running while body is read, it's only released once body is closed
+	response.Body = &cancelOnClose{ReadCloser: response.Body, cancel: cancel}
+	return response, nil
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	return t.policy.BaseDelay * time.Duration(1<<uint(attempt-1))
+}
+
+func isTransientFailure(response *http.Response, err error) bool {
+	if err != nil {
+		netErr, ok := err.(net.Error)
+		return (ok && netErr.Timeout()) || err == context.DeadlineExceeded
+	}
+
+	return response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+}
+
+// parseRetryAfter understands both forms of Retry-After: seconds and HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+
+		return wait, true
+	}
+
+	return 0, false
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (body *cancelOnClose) Close() error {
+	defer body.cancel()
+	return body.ReadCloser.Close()
+}
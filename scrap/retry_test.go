@@ -0,0 +1,168 @@
+package scrap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createFlakyTestServer answers with given status codes in order, then with a book page
+func createFlakyTestServer(requests *int, statusCodes ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		if *requests <= len(statusCodes) {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(statusCodes[*requests-1])
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(politeTestBookPage))
+	}))
+}
+
+func getThroughRetry(policy RetryPolicy, url string) (*http.Response, error) {
+	transport := &retryTransport{policy: policy, transport: http.DefaultTransport}
+	request, _ := http.NewRequest("GET", url, nil)
+
+	return transport.RoundTrip(request)
+}
+
+func TestRetryTransportRetriesTransientFailures(t *testing.T) {
+	requests := 0
+	ts := createFlakyTestServer(&requests, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer ts.Close()
+
+	response, err := getThroughRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, ts.URL+"/book.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 3, requests)
+}
+
+func TestRetryTransportGivesUpAfterMaxAttempts(t *testing.T) {
+	requests := 0
+	ts := createFlakyTestServer(&requests, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer ts.Close()
+
+	response, err := getThroughRetry(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}, ts.URL+"/book.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusBadGateway, response.StatusCode)
+	assert.Equal(t, 2, requests)
+}
+
+func TestRetryTransportDoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	ts := createFlakyTestServer(&requests, http.StatusNotFound)
+	defer ts.Close()
+
+	response, err := getThroughRetry(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, ts.URL+"/book.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, 1, requests)
+}
+
+func TestRetryTransportRetriesTimeouts(t *testing.T) {
+	// The first attempt is still being handled when the second one arrives
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		w.Write([]byte(politeTestBookPage))
+	}))
+	defer ts.Close()
+
+	policy := RetryPolicy{MaxAttempts: 2, AttemptTimeout: 20 * time.Millisecond, BaseDelay: time.Millisecond}
+	response, err := getThroughRetry(policy, ts.URL+"/book.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	wait, ok := parseRetryAfter("120")
+	assert.True(t, ok)
+	assert.Equal(t, 2*time.Minute, wait)
+
+	wait, ok = parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), wait)
+
+	_, ok = parseRetryAfter("")
+	assert.False(t, ok)
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
+
+func TestScraperReturnsPartialResultsWhenDetailPageFails(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		switch r.URL.Path {
+		case "/index.html":
+			w.Write([]byte(`<html><body><article>` +
+				`<h2>Book 0</h2><a href="http://` + r.Host + `/good/book.html">Book 0</a><p>Description 0</p><div>en</div>` +
+				`<h2>Book 1</h2><a href="http://` + r.Host + `/broken/book.html">Book 1</a><p>Description 1</p><div>en</div>` +
+				`</article></body></html>`))
+		case "/broken/book.html":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte(politeTestBookPage))
+		}
+	}))
+	defer ts.Close()
+
+	scraper := &Scraper{Retry: RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}}
+
+	result, err := scraper.FindKotlinBooks(ts.URL + "/index.html")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 2, len(result.Books))
+	assert.Equal(t, "9783161484100", result.Books[0].ISBN.String)
+	assert.Equal(t, ScrapSummary{Succeeded: 1, Failed: 1}, result.Summary)
+	assert.Equal(t, 1, len(result.Failed))
+	assert.Equal(t, "Book 1", result.Failed[0].Book.Title)
+	assert.Equal(t, ts.URL+"/broken/book.html", result.Failed[0].Link)
+	assert.Equal(t, "Internal Server Error", result.Failed[0].Error)
+}
+
+func TestNewRetryPolicyFromEnv(t *testing.T) {
+	expectedPolicy := RetryPolicy{
+		MaxAttempts:    3,
+		AttemptTimeout: 10 * time.Second,
+		BaseDelay:      500 * time.Millisecond,
+		MaxDelay:       10 * time.Second,
+	}
+
+	assert.Equal(t, expectedPolicy, NewRetryPolicyFromEnv())
+
+	os.Setenv("SCRAP_MAX_ATTEMPTS", "5")
+	os.Setenv("SCRAP_ATTEMPT_TIMEOUT", "3s")
+	os.Setenv("SCRAP_RETRY_BASE_DELAY", "1s")
+	os.Setenv("SCRAP_RETRY_MAX_DELAY", "1m")
+	defer os.Unsetenv("SCRAP_MAX_ATTEMPTS")
+	defer os.Unsetenv("SCRAP_ATTEMPT_TIMEOUT")
+	defer os.Unsetenv("SCRAP_RETRY_BASE_DELAY")
+	defer os.Unsetenv("SCRAP_RETRY_MAX_DELAY")
+
+	expectedPolicy = RetryPolicy{
+		MaxAttempts:    5,
+		AttemptTimeout: 3 * time.Second,
+		BaseDelay:      time.Second,
+		MaxDelay:       time.Minute,
+	}
+
+	assert.Equal(t, expectedPolicy, NewRetryPolicyFromEnv())
+}
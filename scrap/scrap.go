@@ -1,25 +1,110 @@
-package main
+package scrap
 
 import (
+	"fmt"
+	"net/http"
 	"regexp"
 	"strings"
+	"sync"
 
 	null "gopkg.in/guregu/null.v3"
 
 	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
 
 	"github.com/gocolly/colly"
 )
 
-func scrapBooksElements(booksIndex string) (booksElements [][]*colly.HTMLElement, scrapingError error) {
-	booksElements = make([][]*colly.HTMLElement, 0)
+// Scraper scraps Kotlin website, all of its collectors share the same settings. Each page visited is traced within
+// Span when there's one
+type Scraper struct {
+	Cache  *ResponseCache
+	Policy ScraperPolicy
+	Retry  RetryPolicy
+	Span   *tracing.Span
+
+	// Progress, when set, is told what's being done whenever scrapping moves on to another stage
+	Progress func(progress string)
+
+	once sync.Once
+	base *colly.Collector
+}
+
+// SkippedLink represents a book's detail page that was not visited and why
+type SkippedLink struct {
+	Title  string `json:"title"`
+	Link   string `json:"link"`
+	Reason string `json:"reason"`
+}
+
+// FailedBook represents a book whose detail page could not be scrapped even after retrying
+type FailedBook struct {
+	Book  model.Book `json:"book"`
+	Link  string     `json:"link"`
+	Error string     `json:"error"`
+}
+
+// ScrapSummary counts what happened to each book found in the index
+type ScrapSummary struct {
+	Succeeded uint `json:"succeeded"`
+	Failed    uint `json:"failed"`
+	Skipped   uint `json:"skipped"`
+}
+
+// detailPageOutcome tells what happened to a book's detail page, Link is empty when book has none
+type detailPageOutcome struct {
+	Link       string
+	SkipReason string
+	Err        error
+}
+
+// NewScraper creates a Scraper configured from environment, forceRefresh makes it ignore cached responses
+func NewScraper(forceRefresh bool) *Scraper {
+	return &Scraper{
+		Cache:  NewResponseCacheFromEnv(forceRefresh),
+		Policy: NewScraperPolicyFromEnv(),
+		Retry:  NewRetryPolicyFromEnv(),
+	}
+}
+
+// Collectors are cloned from the same base so they share robots.txt rules and the HTTP transport
+func (s *Scraper) newCollector() *colly.Collector {
+	s.once.Do(func() {
+		c := colly.NewCollector()
+		c.AllowURLRevisit = true
+		c.IgnoreRobotsTxt = !s.Policy.RespectRobotsTxt
+		c.AllowedDomains = s.Policy.AllowedDomains
 
-	c := colly.NewCollector()
+		if s.Policy.UserAgent != "" {
+			c.UserAgent = s.Policy.UserAgent
+		}
+
+		// Cached responses shouldn't wait for their turn, so the cache goes on top, each retry waits like any other request
+		var transport http.RoundTripper = newPoliteTransport(s.Policy, http.DefaultTransport)
+		transport = &retryTransport{policy: s.Retry, transport: transport}
+		if s.Cache != nil {
+			s.Cache.Transport = transport
+			transport = s.Cache
+		}
+
+		c.WithTransport(transport)
+
+		// Each attempt has its own timeout, the client's one would cut retries short
+		if s.Retry.AttemptTimeout > 0 {
+			c.SetRequestTimeout(0)
+		}
 
-	c.OnError(func(_ *colly.Response, err error) {
-		scrapingError = err
+		s.base = c
 	})
 
+	return s.base.Clone()
+}
+
+func (s *Scraper) scrapBooksElements(booksIndex string) (booksElements [][]*colly.HTMLElement, scrapingError error) {
+	booksElements = make([][]*colly.HTMLElement, 0)
+
+	c := s.newCollector()
+
 	var currentBookElements []*colly.HTMLElement
 	c.OnHTML("article", func(article *colly.HTMLElement) {
 		article.ForEach("*", func(index int, element *colly.HTMLElement) {
@@ -37,76 +122,149 @@ func scrapBooksElements(booksIndex string) (booksElements [][]*colly.HTMLElement
 		}
 	})
 
-	c.Visit(booksIndex)
-	c.Wait()
-
-	if scrapingError != nil {
-		return nil, scrapingError
+	if err := s.visit(c, indexPage, booksIndex); err != nil {
+		return nil, err
 	}
 
 	return booksElements, nil
 }
 
-func scrapISBN(link string) (isbn string, scrapingError error) {
-	c := colly.NewCollector()
+func (s *Scraper) scrapISBN(link string) (isbn string, scrapingError error) {
+	c := s.newCollector()
 
-	c.OnError(func(_ *colly.Response, err error) {
-		scrapingError = err
+	c.OnHTML("body", func(element *colly.HTMLElement) {
+		isbn = findISBN(element.Text)
 	})
 
-	c.OnHTML("body", func(element *colly.HTMLElement) {
-		// Some pages will show ISBN with some hyphens
-		// That's why I've chosen the magic number 26, so that I'm sure to get the whole ISBN
-		indexOf := strings.Index(element.Text, "978")
-		if indexOf != -1 {
-			isbn = element.Text[indexOf : indexOf+26]
-			isbn = strings.Replace(isbn, "-", "", -1)
-			isbn = isbn[0:13]
-		} else {
-			indexOf = strings.Index(element.Text, "979")
-			if indexOf != -1 {
-				isbn = element.Text[indexOf : indexOf+26]
-				isbn = strings.Replace(isbn, "-", "", -1)
-				isbn = isbn[0:13]
-			} else {
-				isbn = "Unavailable"
-			}
+	scrapingError = s.visit(c, detailPage, link)
+
+	return
+}
+
+// visit visits link, an index or detail page, with c and waits for it to be scrapped. It's traced within s.Span
+func (s *Scraper) visit(c *colly.Collector, page string, link string) (visitErr error) {
+	span := s.Span.StartChild("GET "+page+" page", tracing.KindClient)
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.url", link)
+	span.SetAttribute("scrap.page", page)
+
+	c.OnResponse(func(response *colly.Response) {
+		span.SetAttribute("http.status_code", response.StatusCode)
+	})
+
+	c.OnError(func(response *colly.Response, err error) {
+		if response != nil && response.StatusCode != 0 {
+			span.SetAttribute("http.status_code", response.StatusCode)
 		}
+
+		visitErr = err
 	})
 
-	c.Visit(link)
+	if err := c.Visit(link); err != nil {
+		visitErr = err
+	}
 	c.Wait()
 
-	return
+	span.SetError(visitErr)
+	span.End()
+
+	return visitErr
 }
 
-func scrapBooksISBNs(booksElements [][]*colly.HTMLElement) (booksISBNs []string, scrapingError error) {
-	booksISBNs = make([]string, 0)
+// findISBN returns the first ISBN-13 in text, or UnavailableISBN when there's none. Some pages will show ISBN with
+// some hyphens, they're skipped
+func findISBN(text string) string {
+	for _, prefix := range []string{"978", "979"} {
+		for start := strings.Index(text, prefix); start != -1; {
+			isbn := make([]byte, 0, 13)
+			for index := start; index < len(text) && len(isbn) < 13; index++ {
+				if text[index] == '-' {
+					continue
+				}
+
+				if text[index] < '0' || text[index] > '9' {
+					break
+				}
 
-	for _, currentBookElements := range booksElements {
-		var isbnLink string
-		var isbn = "Unavailable"
+				isbn = append(isbn, text[index])
+			}
 
-		for _, currentElement := range currentBookElements {
-			if currentElement.Name == "a" {
-				isbnLink = currentElement.Attr("href")
+			if len(isbn) == 13 {
+				return string(isbn)
+			}
+
+			next := strings.Index(text[start+1:], prefix)
+			if next == -1 {
 				break
 			}
+
+			start += 1 + next
 		}
+	}
 
-		if isbnLink != "" {
-			isbn, scrapingError = scrapISBN(isbnLink)
-			if scrapingError != nil {
-				return nil, scrapingError
-			}
+	return model.UnavailableISBN
+}
+
+// Detail pages are visited simultaneously, policy decides how many of them hit the same domain at once.
+// A page that fails doesn't stop the others, its outcome tells what went wrong.
+func (s *Scraper) scrapBooksISBNs(booksElements [][]*colly.HTMLElement) (booksISBNs []string, outcomes []detailPageOutcome) {
+	booksISBNs = make([]string, len(booksElements))
+	outcomes = make([]detailPageOutcome, len(booksElements))
+
+	var wg sync.WaitGroup
+
+	for index, currentBookElements := range booksElements {
+		booksISBNs[index] = model.UnavailableISBN
+
+		isbnLink := findBookLink(currentBookElements)
+		if isbnLink == "" {
+			continue
 		}
 
-		booksISBNs = append(booksISBNs, isbn)
+		outcomes[index].Link = isbnLink
+
+		wg.Add(1)
+		go func(index int, isbnLink string) {
+			defer wg.Done()
+
+			isbn, err := s.scrapISBN(isbnLink)
+			if err != nil {
+				outcomes[index].SkipReason = skipReason(err)
+				outcomes[index].Err = err
+				return
+			}
+
+			booksISBNs[index] = isbn
+		}(index, isbnLink)
 	}
 
+	wg.Wait()
+
 	return
 }
 
+// skipReason tells whether err means page was not visited on purpose, empty means it's an actual failure
+func skipReason(err error) string {
+	switch err {
+	case colly.ErrRobotsTxtBlocked:
+		return "Disallowed by robots.txt"
+	case colly.ErrForbiddenDomain:
+		return "Domain is not allowed"
+	default:
+		return ""
+	}
+}
+
+func findBookLink(bookElements []*colly.HTMLElement) string {
+	for _, element := range bookElements {
+		if element.Name == "a" {
+			return element.Attr("href")
+		}
+	}
+
+	return ""
+}
+
 func combineBooksElementsAndISBNsIntoBooks(booksElements [][]*colly.HTMLElement, booksISBNs []string) (books []model.Book) {
 	books = make([]model.Book, 0)
 
@@ -138,25 +296,66 @@ func combineBooksElementsAndISBNsIntoBooks(booksElements [][]*colly.HTMLElement,
 		currentBook.Description = bookDescription
 		currentBook.ISBN = null.StringFrom(booksISBNs[index])
 
+		if link := findBookLink(bookElements); link != "" {
+			currentBook.DetailURL = null.StringFrom(link)
+		}
+
 		books = append(books, currentBook)
 	}
 
 	return
 }
 
-// FindKotlinBooks scraps and scraps Kotlin website's books section searching for new books for our library
-func FindKotlinBooks(kotlinBooksURL string) ([]model.Book, error) {
-	scrappedBooks, err := scrapBooksElements(kotlinBooksURL)
-	if err != nil {
-		return nil, err
+// report tells Progress what's being done, if anyone is listening
+func (s *Scraper) report(format string, args ...interface{}) {
+	if s.Progress != nil {
+		s.Progress(fmt.Sprintf(format, args...))
 	}
+}
+
+// ScrapResult represents books found in Kotlin website and what happened to their detail pages
+type ScrapResult struct {
+	Books   []model.Book
+	Failed  []FailedBook
+	Skipped []SkippedLink
+	Summary ScrapSummary
+}
 
-	scrapBooksISBN, err := scrapBooksISBNs(scrappedBooks)
+// FindKotlinBooks scraps and scraps Kotlin website's books section searching for new books for our library,
+// it only fails when books index can't be scrapped
+func (s *Scraper) FindKotlinBooks(kotlinBooksURL string) (*ScrapResult, error) {
+	scrappedBooks, err := s.scrapBooksElements(kotlinBooksURL)
+	recordIndexPage(err)
 	if err != nil {
 		return nil, err
 	}
 
-	books := combineBooksElementsAndISBNsIntoBooks(scrappedBooks, scrapBooksISBN)
+	s.report("Scrapping detail pages of %d books", len(scrappedBooks))
+	scrapBooksISBN, outcomes := s.scrapBooksISBNs(scrappedBooks)
+	recordDetailPages(outcomes)
+
+	result := &ScrapResult{
+		Books:   combineBooksElementsAndISBNsIntoBooks(scrappedBooks, scrapBooksISBN),
+		Failed:  make([]FailedBook, 0),
+		Skipped: make([]SkippedLink, 0),
+	}
+
+	recordISBNs(result.Books)
+
+	for index, outcome := range outcomes {
+		book := result.Books[index]
+
+		switch {
+		case outcome.SkipReason != "":
+			result.Summary.Skipped++
+			result.Skipped = append(result.Skipped, SkippedLink{Title: book.Title, Link: outcome.Link, Reason: outcome.SkipReason})
+		case outcome.Err != nil:
+			result.Summary.Failed++
+			result.Failed = append(result.Failed, FailedBook{Book: book, Link: outcome.Link, Error: outcome.Err.Error()})
+		default:
+			result.Summary.Succeeded++
+		}
+	}
 
-	return books, nil
+	return result, nil
 }
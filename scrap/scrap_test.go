@@ -1,4 +1,4 @@
-package main
+package scrap
 
 import (
 	"errors"
@@ -9,10 +9,12 @@ import (
 	"net/url"
 	"testing"
 
-	"github.com/felipefill/books/model"
-
+	"github.com/felipefill/books/tracing"
 	"github.com/gocolly/colly"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func createTestServer() *httptest.Server {
@@ -47,7 +49,7 @@ func TestScrapBooksElementsSucceeds(t *testing.T) {
 	expectedElementsInBook2 := 7
 	expectedElementsInBook3 := 3
 
-	elements, actualErr := scrapBooksElements(ts.URL + "/index.html")
+	elements, actualErr := testScraper.scrapBooksElements(ts.URL + "/index.html")
 
 	assert.Equal(t, expectedErr, actualErr)
 	assert.Equal(t, expectedFoundBooksNumber, len(elements))
@@ -64,7 +66,7 @@ func TestScrapBooksElementsFails(t *testing.T) {
 		Err: errors.New("http: no Host in request URL"),
 	}
 
-	actualElements, actualError := scrapBooksElements("not_a_url")
+	actualElements, actualError := testScraper.scrapBooksElements("not_a_url")
 
 	assert.Equal(t, expectedElements, actualElements)
 	assert.Equal(t, expectedError, actualError)
@@ -77,7 +79,7 @@ func TestScrapISBNSucceedsAndFindsISBN(t *testing.T) {
 	var expectedError error
 	expectedISBN := "9783161484100"
 
-	actualISBN, actualError := scrapISBN(ts.URL + "/book1.html")
+	actualISBN, actualError := testScraper.scrapISBN(ts.URL + "/book1.html")
 
 	assert.Equal(t, expectedISBN, actualISBN)
 	assert.Equal(t, expectedError, actualError)
@@ -90,12 +92,23 @@ func TestScrapISBNSucceedsAndDoesNotFindISBN(t *testing.T) {
 	var expectedError error
 	expectedISBN := "Unavailable"
 
-	actualISBN, actualError := scrapISBN(ts.URL + "/book2.html")
+	actualISBN, actualError := testScraper.scrapISBN(ts.URL + "/book2.html")
 
 	assert.Equal(t, expectedISBN, actualISBN)
 	assert.Equal(t, expectedError, actualError)
 }
 
+func TestFindISBN(t *testing.T) {
+	assert.Equal(t, "9781617293290", findISBN("ISBN: 978-1-61729-329-0, 2017"))
+	assert.Equal(t, "9791032101234", findISBN("Published in 2017\nISBN 979-10-321-0123-4"))
+
+	// Pages ending right after the ISBN, or with a truncated one, used to make scrapping panic
+	assert.Equal(t, "9781617293290", findISBN("ISBN 9781617293290"))
+	assert.Equal(t, "Unavailable", findISBN("Call us at 978-555"))
+	assert.Equal(t, "9791032101234", findISBN("Room 978 or ISBN 9791032101234"))
+	assert.Equal(t, "Unavailable", findISBN("No ISBN here"))
+}
+
 func TestScrapISBNFails(t *testing.T) {
 	expectedISBN := ""
 	expectedError := &url.Error{
@@ -104,7 +117,7 @@ func TestScrapISBNFails(t *testing.T) {
 		Err: errors.New("http: no Host in request URL"),
 	}
 
-	actualISBN, actualError := scrapISBN("not_a_url")
+	actualISBN, actualError := testScraper.scrapISBN("not_a_url")
 
 	assert.Equal(t, expectedISBN, actualISBN)
 	assert.Equal(t, expectedError, actualError)
@@ -114,23 +127,27 @@ func TestScrapBooksISBNs(t *testing.T) {
 	ts := createTestServer()
 	defer ts.Close()
 
-	scrappedBooksElements, scrappedBooksElementsError := scrapBooksElements(ts.URL + "/index.html")
+	scrappedBooksElements, scrappedBooksElementsError := testScraper.scrapBooksElements(ts.URL + "/index.html")
 	assert.Equal(t, nil, scrappedBooksElementsError)
 
-	var expectedError error
 	expectedISBNs := sampleBooksISBNs
+	expectedOutcomes := []detailPageOutcome{
+		detailPageOutcome{Link: "http://localhost:8080/book1.html"},
+		detailPageOutcome{Link: "http://localhost:8080/book2.html"},
+		detailPageOutcome{},
+	}
 
-	actualISBNs, actualError := scrapBooksISBNs(scrappedBooksElements)
+	actualISBNs, actualOutcomes := testScraper.scrapBooksISBNs(scrappedBooksElements)
 
 	assert.Equal(t, expectedISBNs, actualISBNs)
-	assert.Equal(t, expectedError, actualError)
+	assert.Equal(t, expectedOutcomes, actualOutcomes)
 }
 
 func TestCombineBooksElementsAndISBNsIntoBooks(t *testing.T) {
 	ts := createTestServer()
 	defer ts.Close()
 
-	scrappedBooksElements, scrappedBooksElementsError := scrapBooksElements(ts.URL + "/index.html")
+	scrappedBooksElements, scrappedBooksElementsError := testScraper.scrapBooksElements(ts.URL + "/index.html")
 	assert.Equal(t, nil, scrappedBooksElementsError)
 
 	expectedBooks := sampleBooksUsedInLocalWebsite
@@ -144,24 +161,83 @@ func TestFindKotlinBooksSucceeds(t *testing.T) {
 	defer ts.Close()
 
 	var expectedError error
-	expectedBooks := sampleBooksUsedInLocalWebsite
+	expectedResult := &ScrapResult{
+		Books:   sampleBooksUsedInLocalWebsite,
+		Failed:  []FailedBook{},
+		Skipped: []SkippedLink{},
+		Summary: ScrapSummary{Succeeded: 3},
+	}
 
-	actualBooks, actualError := FindKotlinBooks(ts.URL + "/index.html")
+	actualResult, actualError := testScraper.FindKotlinBooks(ts.URL + "/index.html")
 
-	assert.Equal(t, expectedBooks, actualBooks)
+	assert.Equal(t, expectedResult, actualResult)
 	assert.Equal(t, expectedError, actualError)
 }
 
 func TestFindKotlinBooksFailsToScrap(t *testing.T) {
-	var expectedBooks []model.Book
+	var expectedResult *ScrapResult
 	expectedError := &url.Error{
 		Op:  "Get",
 		URL: "http://not_a_url",
 		Err: errors.New("http: no Host in request URL"),
 	}
 
-	actualBooks, actualError := FindKotlinBooks("not_a_url")
+	actualResult, actualError := testScraper.FindKotlinBooks("not_a_url")
 
-	assert.Equal(t, expectedBooks, actualBooks)
+	assert.Equal(t, expectedResult, actualResult)
 	assert.Equal(t, expectedError, actualError)
 }
+
+func TestFindKotlinBooksTracesEachVisit(t *testing.T) {
+	ts := createTestServer()
+	defer ts.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter, "books")
+	parent := tracer.Start("GET /books", tracing.KindServer, trace.SpanContext{})
+
+	scraper := &Scraper{Span: parent}
+	result, _ := scraper.FindKotlinBooks(ts.URL + "/index.html")
+	tracer.Flush()
+
+	spans := exporter.GetSpans()
+	index := spans[0]
+	indexAttributes := attribute.NewSet(index.Attributes...)
+	assert.Equal(t, "GET index page", index.Name)
+
+	url, _ := indexAttributes.Value("http.url")
+	statusCode, _ := indexAttributes.Value("http.status_code")
+	assert.Equal(t, ts.URL+"/index.html", url.AsString())
+	assert.Equal(t, int64(200), statusCode.AsInt64())
+
+	details := make([]string, 0)
+	for _, span := range spans[1:] {
+		assert.Equal(t, "GET detail page", span.Name)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+
+		attributes := attribute.NewSet(span.Attributes...)
+		url, _ := attributes.Value("http.url")
+		details = append(details, url.AsString())
+	}
+
+	expected := make([]string, 0)
+	for _, book := range result.Books {
+		if book.DetailURL.Valid {
+			expected = append(expected, book.DetailURL.String)
+		}
+	}
+
+	assert.ElementsMatch(t, expected, details)
+}
+
+func TestFindKotlinBooksTracesFailedVisits(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter, "books")
+
+	scraper := &Scraper{Span: tracer.Start("GET /books", tracing.KindServer, trace.SpanContext{})}
+	scraper.FindKotlinBooks("not_a_url")
+	tracer.Flush()
+
+	assert.Equal(t, 1, len(exporter.GetSpans()))
+	assert.Contains(t, exporter.GetSpans()[0].Status.Description, "no Host in request URL")
+}
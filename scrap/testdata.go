@@ -1,10 +1,15 @@
-package main
+package scrap
 
 import (
 	"github.com/felipefill/books/model"
 	null "gopkg.in/guregu/null.v3"
 )
 
+// testScraper has no cache so that tests always hit the local website
+var testScraper = &Scraper{}
+
+var keepExisting = model.MergePolicy{Strategy: model.KeepExisting}
+
 var sampleBooksISBNs = []string{
 	"9783161484100", // First book's page has ISBN
 	"Unavailable",   // Second book's page does not have ISBN
@@ -18,6 +23,7 @@ var sampleBooksUsedInLocalWebsite = []model.Book{
 		Description: "This book was created by me and it's really great, please read it. Oh, this was also my first pharagraph. So, this is my second paragraph and I think I'll write another one after this. Yep, last one I swear. Oh, by the way, here's another link to my book1.",
 		ISBN:        null.StringFrom("9783161484100"),
 		Language:    "EN",
+		Provenance:  model.Provenance{DetailURL: null.StringFrom("http://localhost:8080/book1.html")},
 	},
 
 	model.Book{
@@ -26,6 +32,7 @@ var sampleBooksUsedInLocalWebsite = []model.Book{
 		Description: "This book was created by me and it's really great, not as great as the first one. Sequels, right? Yep, last paragraph I swear. Oh, by the way, here's another link to my book2. I fooled you! Here's another paragraph.",
 		ISBN:        null.StringFrom("Unavailable"),
 		Language:    "EN",
+		Provenance:  model.Provenance{DetailURL: null.StringFrom("http://localhost:8080/book2.html")},
 	},
 
 	model.Book{
@@ -36,3 +43,15 @@ var sampleBooksUsedInLocalWebsite = []model.Book{
 		Language:    "EN",
 	},
 }
+
+// storedSampleBooks returns a copy of sample books with the IDs they get once stored
+func storedSampleBooks() []model.Book {
+	books := make([]model.Book, len(sampleBooksUsedInLocalWebsite))
+	copy(books, sampleBooksUsedInLocalWebsite)
+
+	for index := range books {
+		books[index].ID = uint(index + 1)
+	}
+
+	return books
+}
@@ -1,4 +1,4 @@
-package main
+package scrap
 
 import "strings"
 
@@ -14,6 +14,9 @@ const (
 
 	// ScrapAndStore will scarp Kotlin books, store them and then retrieve all stored books and return
 	ScrapAndStore WorkingMode = 2
+
+	// ScrapDiff will scrap Kotlin books and compare them against stored books without storing anything
+	ScrapDiff WorkingMode = 3
 )
 
 // WorkingModeFromString receives a string and returns equivalent WorkingMode
@@ -28,5 +31,23 @@ func WorkingModeFromString(mode string) WorkingMode {
 		return ScrapAndStore
 	}
 
+	if normalizedMode == "SCRAP_DIFF" {
+		return ScrapDiff
+	}
+
 	return RetrieveAll
 }
+
+// String returns the string used to request WorkingMode
+func (mode WorkingMode) String() string {
+	switch mode {
+	case ScrapOnly:
+		return "scrap_only"
+	case ScrapAndStore:
+		return "scrap_and_store"
+	case ScrapDiff:
+		return "scrap_diff"
+	default:
+		return "retrieve_all"
+	}
+}
@@ -1,4 +1,4 @@
-package main
+package scrap
 
 import (
 	"testing"
@@ -9,13 +9,22 @@ import (
 func TestWorkingModeFromString(t *testing.T) {
 	scrapOnlyModeString := "sCrAp_OnLy"
 	scrapAndStoreModeString := "scrap_and_store"
+	scrapDiffModeString := "Scrap_Diff"
 	retrieveAllModeString := "RETRIEVE_ALL"
 	unknownModeString := "i_like_dogs"
 	emptyString := ""
 
 	assert.Equal(t, ScrapOnly, WorkingModeFromString(scrapOnlyModeString))
 	assert.Equal(t, ScrapAndStore, WorkingModeFromString(scrapAndStoreModeString))
+	assert.Equal(t, ScrapDiff, WorkingModeFromString(scrapDiffModeString))
 	assert.Equal(t, RetrieveAll, WorkingModeFromString(retrieveAllModeString))
 	assert.Equal(t, RetrieveAll, WorkingModeFromString(unknownModeString))
 	assert.Equal(t, RetrieveAll, WorkingModeFromString(emptyString))
 }
+
+func TestWorkingModeString(t *testing.T) {
+	assert.Equal(t, "retrieve_all", RetrieveAll.String())
+	assert.Equal(t, "scrap_only", ScrapOnly.String())
+	assert.Equal(t, "scrap_and_store", ScrapAndStore.String())
+	assert.Equal(t, "scrap_diff", ScrapDiff.String())
+}
@@ -0,0 +1,90 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/middleware"
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
+	"github.com/felipefill/books/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler finds books in its repository
+type Handler struct {
+	books model.BookRepository
+}
+
+// NewHandler creates a Handler that searches given repository
+func NewHandler(books model.BookRepository) *Handler {
+	return &Handler{books: books}
+}
+
+// traced returns a copy of h whose queries are traced within span, see utils.TraceBookRepository
+func (h *Handler) traced(span *tracing.Span) *Handler {
+	return &Handler{books: utils.TraceBookRepository(h.books, span)}
+}
+
+// Handle is our lambda handler, it's invoked by `lambda.Start` (see cmd/search) or by the HTTP server (see cmd/server)
+func (h *Handler) Handle(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id, err := retrieveIDFromRequest(request)
+	if err != nil {
+		return apierror.New(400, apierror.InvalidParameter, err.Error()).Response(request), nil
+	}
+
+	book, err := h.traced(middleware.GetSpan(ctx)).findBookByID(id)
+	if utils.IsUnavailable(err) {
+		return utils.UnavailableResponse(request, err), nil
+	}
+
+	if err != nil {
+		return apierror.New(500, apierror.InternalError, err.Error()).Response(request), nil
+	}
+
+	if book == nil {
+		return apierror.New(404, apierror.BookNotFound, fmt.Sprintf("No book with ID %d", id)).Response(request), nil
+	}
+
+	book.IncludeProvenance, _ = strconv.ParseBool(request.QueryStringParameters["provenance"])
+
+	json, err := json.Marshal(book)
+	if err != nil {
+		return apierror.Internal().Response(request), nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(json), StatusCode: 200}, nil
+}
+
+func (h *Handler) findBookByID(id int) (*model.Book, error) {
+	book, err := h.books.FindByID(uint(id))
+	if utils.IsUnavailable(err) {
+		return nil, err
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve book with ID: %d", id)
+	}
+
+	return book, nil
+}
+
+func retrieveIDFromRequest(request events.APIGatewayProxyRequest) (int, error) {
+	params := request.PathParameters
+	idAsString, ok := params["id"]
+	if !ok {
+		return -1, errors.New("Missing \"id\" parameter")
+	}
+
+	id, err := strconv.Atoi(idAsString)
+	if err != nil {
+		return -1, errors.New("\"id\" parameter must be an integer")
+	}
+
+	return id, nil
+}
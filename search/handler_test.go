@@ -1,9 +1,12 @@
-package main
+package search
 
 import (
+	"context"
 	"errors"
+	"os"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/felipefill/books/model"
 	"github.com/felipefill/books/utils"
@@ -12,6 +15,7 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
+	null "gopkg.in/guregu/null.v3"
 )
 
 func TestSearchHandlerFindsBook(t *testing.T) {
@@ -19,18 +23,7 @@ func TestSearchHandlerFindsBook(t *testing.T) {
 	request.PathParameters = make(map[string]string)
 	request.PathParameters["id"] = strconv.Itoa(int(sampleBook.ID))
 
-	db, mock, _ := sqlmock.New()
-	defer db.Close()
-
-	utils.InjectDB(db)
-
-	mock.
-		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(sampleBook.ID).
-		WillReturnRows(
-			sqlmock.NewRows([]string{"id", "title", "description", "isbn", "language"}).
-				AddRow(sampleBook.ID, sampleBook.Title, sampleBook.Description, sampleBook.ISBN.String, sampleBook.Language),
-		)
+	books := model.NewInMemoryBookRepository(sampleBook)
 
 	var expectedError error
 	expectedResponse := events.APIGatewayProxyResponse{
@@ -38,34 +31,50 @@ func TestSearchHandlerFindsBook(t *testing.T) {
 		StatusCode: 200,
 	}
 
-	actualResponse, actualError := Handler(request)
+	actualResponse, actualError := NewHandler(books).Handle(context.Background(), request)
 
 	assert.Equal(t, expectedResponse, actualResponse)
 	assert.Equal(t, expectedError, actualError)
 }
 
-func TestSearchHandlerDoesNotFindBook(t *testing.T) {
+func TestSearchHandlerFindsBookWithProvenance(t *testing.T) {
 	request := events.APIGatewayProxyRequest{}
-	request.PathParameters = make(map[string]string)
-	request.PathParameters["id"] = "20"
+	request.PathParameters = map[string]string{"id": strconv.Itoa(int(sampleBook.ID))}
+	request.QueryStringParameters = map[string]string{"provenance": "true"}
+
+	book := sampleBook
+	book.Provenance = model.Provenance{
+		SourceName:  null.StringFrom("kotlinlang.org"),
+		SourceURL:   null.StringFrom("https://kotlinlang.org/docs/books.html"),
+		FirstSeenAt: null.TimeFrom(time.Date(2018, 10, 1, 10, 0, 0, 0, time.UTC)),
+		LastSeenAt:  null.TimeFrom(time.Date(2018, 10, 2, 10, 0, 0, 0, time.UTC)),
+		ContentHash: null.StringFrom("abc"),
+	}
 
-	db, mock, _ := sqlmock.New()
-	defer db.Close()
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       sampleBookWithProvenanceAsJSONString,
+		StatusCode: 200,
+	}
 
-	utils.InjectDB(db)
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository(book)).Handle(context.Background(), request)
 
-	mock.
-		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
-		WithArgs(20).
-		WillReturnError(gorm.ErrRecordNotFound)
+	assert.Equal(t, expectedResponse, actualResponse)
+	assert.Equal(t, expectedError, actualError)
+}
+
+func TestSearchHandlerDoesNotFindBook(t *testing.T) {
+	request := events.APIGatewayProxyRequest{Headers: map[string]string{"X-Request-Id": "abc"}}
+	request.PathParameters = make(map[string]string)
+	request.PathParameters["id"] = "20"
 
 	var expectedError error
 	expectedResponse := events.APIGatewayProxyResponse{
-		Body:       "",
+		Body:       `{"error":{"status":404,"code":"BOOK_NOT_FOUND","message":"No book with ID 20","requestId":"abc"}}`,
 		StatusCode: 404,
 	}
 
-	actualResponse, actualError := Handler(request)
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository(sampleBook)).Handle(context.Background(), request)
 
 	assert.Equal(t, expectedResponse, actualResponse)
 	assert.Equal(t, expectedError, actualError)
@@ -79,7 +88,7 @@ func TestSearchHandlerFailsDueToDatabase(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
-	utils.InjectDB(db)
+	gormDB, _ := gorm.Open("postgres", db)
 
 	mock.
 		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
@@ -88,11 +97,31 @@ func TestSearchHandlerFailsDueToDatabase(t *testing.T) {
 
 	var expectedError error
 	expectedResponse := events.APIGatewayProxyResponse{
-		Body:       `{"error": "Failed to retrieve book with ID: 20"}`,
+		Body:       `{"error":{"status":500,"code":"INTERNAL_ERROR","message":"Failed to retrieve book with ID: 20"}}`,
 		StatusCode: 500,
 	}
 
-	actualResponse, actualError := Handler(request)
+	actualResponse, actualError := NewHandler(model.NewGormBookRepository(gormDB)).Handle(context.Background(), request)
+
+	assert.Equal(t, expectedResponse, actualResponse)
+	assert.Equal(t, expectedError, actualError)
+}
+
+func TestSearchHandlerAnswersUnavailableWithoutDatabaseConfiguration(t *testing.T) {
+	for _, name := range []string{"DB_DRIVER", "DATABASE_URL", "DB_HOST", "DB_NAME", "DB_USER", "DB_PSWD"} {
+		os.Unsetenv(name)
+	}
+
+	request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "20"}}
+
+	var expectedError error
+	expectedResponse := events.APIGatewayProxyResponse{
+		Body:       `{"error":{"status":503,"code":"DATABASE_UNAVAILABLE","message":"Database is unavailable: DATABASE_URL or DB_HOST, DB_NAME, DB_USER and DB_PSWD must be set, missing DB_HOST, DB_NAME, DB_USER, DB_PSWD"}}`,
+		StatusCode: 503,
+		Headers:    map[string]string{"Retry-After": "5"},
+	}
+
+	actualResponse, actualError := NewHandler(utils.NewBookRepository()).Handle(context.Background(), request)
 
 	assert.Equal(t, expectedResponse, actualResponse)
 	assert.Equal(t, expectedError, actualError)
@@ -103,11 +132,11 @@ func TestSearchHandlerFailsIDMissing(t *testing.T) {
 
 	var expectedError error
 	expectedResponse := events.APIGatewayProxyResponse{
-		Body:       `{"error": "Missing "id" parameter"}`,
+		Body:       `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"Missing \"id\" parameter"}}`,
 		StatusCode: 400,
 	}
 
-	actualResponse, actualError := Handler(request)
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
 
 	assert.Equal(t, expectedResponse, actualResponse)
 	assert.Equal(t, expectedError, actualError)
@@ -120,11 +149,11 @@ func TestSearchHandlerFailsIDNotInt(t *testing.T) {
 
 	var expectedError error
 	expectedResponse := events.APIGatewayProxyResponse{
-		Body:       `{"error": ""id" parameter must be an integer"}`,
+		Body:       `{"error":{"status":400,"code":"INVALID_PARAMETER","message":"\"id\" parameter must be an integer"}}`,
 		StatusCode: 400,
 	}
 
-	actualResponse, actualError := Handler(request)
+	actualResponse, actualError := NewHandler(model.NewInMemoryBookRepository()).Handle(context.Background(), request)
 
 	assert.Equal(t, expectedResponse, actualResponse)
 	assert.Equal(t, expectedError, actualError)
@@ -134,7 +163,7 @@ func TestFindBookByIDFindsBook(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
-	utils.InjectDB(db)
+	gormDB, _ := gorm.Open("postgres", db)
 
 	var expectedBook = sampleBook
 	var expectedError error
@@ -147,7 +176,7 @@ func TestFindBookByIDFindsBook(t *testing.T) {
 				AddRow(expectedBook.ID, expectedBook.Title, expectedBook.Description, expectedBook.ISBN.String, expectedBook.Language),
 		)
 
-	actualBook, actualError := findBookByID(22)
+	actualBook, actualError := NewHandler(model.NewGormBookRepository(gormDB)).findBookByID(22)
 
 	assert.Equal(t, &expectedBook, actualBook)
 	assert.Equal(t, expectedError, actualError)
@@ -157,7 +186,7 @@ func TestFindBookByIDDoesNotFindBook(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
-	utils.InjectDB(db)
+	gormDB, _ := gorm.Open("postgres", db)
 
 	mock.
 		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
@@ -167,7 +196,7 @@ func TestFindBookByIDDoesNotFindBook(t *testing.T) {
 	var expectedBook *model.Book
 	var expectedError error
 
-	actualBook, actualError := findBookByID(22)
+	actualBook, actualError := NewHandler(model.NewGormBookRepository(gormDB)).findBookByID(22)
 
 	assert.Equal(t, expectedBook, actualBook)
 	assert.Equal(t, expectedError, actualError)
@@ -177,7 +206,7 @@ func TestFindBookByIDFailsDueToDatabase(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
-	utils.InjectDB(db)
+	gormDB, _ := gorm.Open("postgres", db)
 
 	mock.
 		ExpectQuery("SELECT (.+) FROM \"books\" (.+)").
@@ -187,7 +216,7 @@ func TestFindBookByIDFailsDueToDatabase(t *testing.T) {
 	var expectedBook *model.Book
 	expectedError := errors.New("Failed to retrieve book with ID: 22")
 
-	actualBook, actualError := findBookByID(22)
+	actualBook, actualError := NewHandler(model.NewGormBookRepository(gormDB)).findBookByID(22)
 
 	assert.Equal(t, expectedBook, actualBook)
 	assert.Equal(t, expectedError, actualError)
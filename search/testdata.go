@@ -1,4 +1,4 @@
-package main
+package search
 
 import (
 	"github.com/felipefill/books/model"
@@ -14,3 +14,7 @@ var sampleBook = model.Book{
 }
 
 var sampleBookAsJSONString = `{"id":99,"isbn":"0123456789012","title":"Sample book","description":"This is a great book, 10/10.","language":"EN"}`
+
+var sampleBookWithProvenanceAsJSONString = `{"id":99,"isbn":"0123456789012","title":"Sample book","description":"This is a great book, 10/10.","language":"EN",` +
+	`"provenance":{"sourceName":"kotlinlang.org","sourceUrl":"https://kotlinlang.org/docs/books.html","detailUrl":null,` +
+	`"firstSeenAt":"2018-10-01T10:00:00Z","lastSeenAt":"2018-10-02T10:00:00Z","contentHash":"abc"}}`
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var defaultServiceName = "books"
+
+// NewExporterFromEnv creates the exporter OTEL_TRACES_EXPORTER names, following OpenTelemetry's environment variables:
+//
+//   - none (default) records nothing
+//   - stdout writes each span as a line of JSON to stdout, for local use
+//   - otlp sends spans to an OpenTelemetry collector over OTLP/HTTP, configured by OTEL_EXPORTER_OTLP_ENDPOINT,
+//     OTEL_EXPORTER_OTLP_TRACES_ENDPOINT, OTEL_EXPORTER_OTLP_HEADERS and the like, see otlptracehttp
+func NewExporterFromEnv() (sdktrace.SpanExporter, error) {
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", "none":
+		return nil, nil
+	case "stdout", "console":
+		return stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		return otlptracehttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("OTEL_TRACES_EXPORTER must be one of none, stdout or otlp, got %q", name)
+	}
+}
+
+// serviceNameFromEnv is who spans are exported on behalf of: OTEL_SERVICE_NAME, Lambda's function name or books
+func serviceNameFromEnv() string {
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		return name
+	}
+
+	return getEnvOrDefault("AWS_LAMBDA_FUNCTION_NAME", defaultServiceName)
+}
+
+// serviceResource describes the service spans are exported on behalf of
+func serviceResource(serviceName string) *resource.Resource {
+	return resource.NewSchemaless(attribute.String("service.name", serviceName))
+}
+
+func getEnvOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/trace"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// otelEnv are the environment variables exporters read, unset before and after each test using them
+var otelEnv = []string{
+	"OTEL_TRACES_EXPORTER",
+	"OTEL_SERVICE_NAME",
+	"OTEL_EXPORTER_OTLP_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT",
+	"OTEL_EXPORTER_OTLP_HEADERS",
+	"AWS_LAMBDA_FUNCTION_NAME",
+}
+
+func resetOTelEnv() {
+	for _, name := range otelEnv {
+		os.Unsetenv(name)
+	}
+}
+
+func TestStdoutExporterWritesSpansAsJSONLines(t *testing.T) {
+	var output bytes.Buffer
+	exporter, _ := stdouttrace.New(stdouttrace.WithWriter(&output))
+	tracer := NewTracer(exporter, "books-search")
+
+	tracer.Start("GET /book/{id}", KindServer, trace.SpanContext{}).End()
+	tracer.Start("GET /books", KindServer, trace.SpanContext{}).End()
+	assert.Equal(t, nil, tracer.Flush())
+
+	lines := bytes.Split(bytes.TrimSpace(output.Bytes()), []byte("\n"))
+	assert.Equal(t, 2, len(lines))
+
+	var span struct{ Name string }
+	assert.Equal(t, nil, json.Unmarshal(lines[0], &span))
+	assert.Equal(t, "GET /book/{id}", span.Name)
+	assert.Contains(t, string(lines[0]), `"books-search"`)
+}
+
+func TestOTLPExporterPostsSpansToCollector(t *testing.T) {
+	resetOTelEnv()
+	defer resetOTelEnv()
+
+	var received collectortrace.ExportTraceServiceRequest
+	var request *http.Request
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		proto.Unmarshal(body, &received)
+		request = r
+	}))
+	defer collector.Close()
+
+	os.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+	os.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "X-Api-Key=secret")
+
+	exporter, err := NewExporterFromEnv()
+	assert.Equal(t, nil, err)
+
+	tracer := NewTracer(exporter, "books")
+	span := tracer.Start("GET /book/{id}", KindServer, trace.SpanContext{})
+	span.SetAttribute("http.status_code", 500)
+	span.SetErrorStatus("Internal Server Error")
+	span.End()
+	assert.Equal(t, nil, tracer.Flush())
+
+	assert.Equal(t, "/v1/traces", request.URL.Path)
+	assert.Equal(t, "secret", request.Header.Get("X-Api-Key"))
+
+	resourceSpans := received.ResourceSpans[0]
+	assert.Equal(t, "service.name", resourceSpans.Resource.Attributes[0].Key)
+	assert.Equal(t, "books", resourceSpans.Resource.Attributes[0].Value.GetStringValue())
+
+	exported := resourceSpans.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "GET /book/{id}", exported.Name)
+	assert.Equal(t, "http.status_code", exported.Attributes[0].Key)
+	assert.Equal(t, int64(500), exported.Attributes[0].Value.GetIntValue())
+	assert.Equal(t, "Internal Server Error", exported.Status.Message)
+}
+
+func TestOTLPExporterFailsWhenCollectorRejectsSpans(t *testing.T) {
+	resetOTelEnv()
+	defer resetOTelEnv()
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(400)
+	}))
+	defer collector.Close()
+
+	os.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	os.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", collector.URL+"/otlp")
+	exporter, _ := NewExporterFromEnv()
+
+	tracer := NewTracer(exporter, "books")
+	tracer.Start("GET /books", KindServer, trace.SpanContext{}).End()
+	assert.NotEqual(t, nil, tracer.Flush())
+}
+
+func TestNewExporterFromEnv(t *testing.T) {
+	resetOTelEnv()
+	defer resetOTelEnv()
+
+	exporter, err := NewExporterFromEnv()
+	assert.Equal(t, nil, err)
+	assert.Nil(t, exporter)
+
+	os.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+	exporter, _ = NewExporterFromEnv()
+	assert.IsType(t, &stdouttrace.Exporter{}, exporter)
+
+	os.Setenv("OTEL_TRACES_EXPORTER", "otlp")
+	exporter, _ = NewExporterFromEnv()
+	assert.IsType(t, &otlptrace.Exporter{}, exporter)
+
+	os.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	_, err = NewExporterFromEnv()
+	assert.Equal(t, `OTEL_TRACES_EXPORTER must be one of none, stdout or otlp, got "jaeger"`, err.Error())
+}
+
+func TestServiceNameFromEnv(t *testing.T) {
+	resetOTelEnv()
+	defer resetOTelEnv()
+
+	assert.Equal(t, "books", serviceNameFromEnv())
+
+	os.Setenv("AWS_LAMBDA_FUNCTION_NAME", "books-dev-search")
+	assert.Equal(t, "books-dev-search", serviceNameFromEnv())
+
+	os.Setenv("OTEL_SERVICE_NAME", "books")
+	assert.Equal(t, "books", serviceNameFromEnv())
+}
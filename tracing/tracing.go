@@ -0,0 +1,264 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPendingSpans bounds the spans a Tracer keeps until it's flushed, spans beyond it are dropped
+var maxPendingSpans = 1000
+
+// defaultExportTimeout bounds how long Flush waits for spans to be exported
+var defaultExportTimeout = 5 * time.Second
+
+// instrumentationName is the OpenTelemetry instrumentation scope spans are started within
+const instrumentationName = "github.com/felipefill/books"
+
+// Default is the Tracer middleware and instrumented code start spans with, it records nothing until Configure sets
+// an exporter
+var Default = NewTracer(nil, defaultServiceName)
+
+// Kinds of spans, see OpenTelemetry's span kinds
+const (
+	// KindServer spans handle a request from a client, e.g. a handler answering API Gateway
+	KindServer = trace.SpanKindServer
+
+	// KindClient spans make a request to a server, e.g. a database query or a page visit
+	KindClient = trace.SpanKindClient
+
+	// KindInternal spans are operations that don't cross the process, e.g. a scrap job
+	KindInternal = trace.SpanKindInternal
+)
+
+// traceContext reads and writes W3C Trace Context headers, traceparent and tracestate:
+//
+// https://www.w3.org/TR/trace-context/
+var traceContext = propagation.TraceContext{}
+
+// Extract returns the span context carried by W3C Trace Context headers, header gets a request header by name. It's
+// invalid when there's none or it's malformed
+func Extract(header func(name string) string) trace.SpanContext {
+	carrier := propagation.MapCarrier{}
+	for _, name := range traceContext.Fields() {
+		if value := header(name); value != "" {
+			carrier.Set(name, value)
+		}
+	}
+
+	return trace.SpanContextFromContext(traceContext.Extract(context.Background(), carrier))
+}
+
+// Span is an operation within a trace, it's exported by its tracer once it ends. A nil *Span is valid and records
+// nothing, which is what code that isn't traced gets, so callers don't need to check for it.
+// A span is meant to be used by a single goroutine, children may be started from others
+type Span struct {
+	tracer *Tracer
+	ctx    context.Context
+	span   trace.Span
+}
+
+// SpanContext returns what s propagates to its children, an invalid one when s is nil
+func (s *Span) SpanContext() trace.SpanContext {
+	if s == nil {
+		return trace.SpanContext{}
+	}
+
+	return s.span.SpanContext()
+}
+
+// StartChild starts a span within s, it's nil when s is
+func (s *Span) StartChild(name string, kind trace.SpanKind) *Span {
+	if s == nil {
+		return nil
+	}
+
+	return s.tracer.start(s.ctx, name, kind)
+}
+
+// SetName renames s, for operations whose name is only known once they've run, like a request's route
+func (s *Span) SetName(name string) {
+	if s != nil {
+		s.span.SetName(name)
+	}
+}
+
+// SetAttribute describes s, values are strings, booleans, integers or floats. Names follow OpenTelemetry's semantic
+// conventions, e.g. "http.method" or "db.sql.table"
+func (s *Span) SetAttribute(name string, value interface{}) {
+	if s == nil {
+		return
+	}
+
+	switch typed := value.(type) {
+	case bool:
+		s.span.SetAttributes(attribute.Bool(name, typed))
+	case int:
+		s.span.SetAttributes(attribute.Int(name, typed))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(name, typed))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(name, typed))
+	case string:
+		s.span.SetAttributes(attribute.String(name, typed))
+	default:
+		s.span.SetAttributes(attribute.String(name, fmt.Sprint(typed)))
+	}
+}
+
+// SetError marks s as failed with err, which is recorded as an exception event, nil errors are ignored
+func (s *Span) SetError(err error) {
+	if s != nil && err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// SetErrorStatus marks s as failed with description, for failures that aren't errors, like a 5xx response
+func (s *Span) SetErrorStatus(description string) {
+	if s != nil {
+		s.span.SetStatus(codes.Error, description)
+	}
+}
+
+// End ends s and hands it over to its tracer, s mustn't be changed afterwards
+func (s *Span) End() {
+	if s != nil {
+		s.span.End()
+	}
+}
+
+// Tracer starts spans with OpenTelemetry's SDK and keeps those that ended until it's flushed, it's safe for
+// concurrent use
+type Tracer struct {
+	tracer  trace.Tracer
+	pending *pendingSpans
+}
+
+// NewTracer creates a Tracer exporting spans with exporter on behalf of serviceName, it records nothing when exporter
+// is nil. Traces started elsewhere are continued when they were sampled, new ones always are
+func NewTracer(exporter sdktrace.SpanExporter, serviceName string) *Tracer {
+	if exporter == nil {
+		return &Tracer{}
+	}
+
+	pending := &pendingSpans{exporter: exporter}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(pending),
+		sdktrace.WithResource(serviceResource(serviceName)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+
+	return &Tracer{tracer: provider.Tracer(instrumentationName), pending: pending}
+}
+
+// Start starts a span named name within parent, or a new trace when parent isn't valid, see Extract. It's nil when t
+// has no exporter or parent wasn't sampled
+func (t *Tracer) Start(name string, kind trace.SpanKind, parent trace.SpanContext) *Span {
+	ctx := context.Background()
+	if parent.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, parent)
+	}
+
+	return t.start(ctx, name, kind)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, kind trace.SpanKind) *Span {
+	if t == nil || t.tracer == nil {
+		return nil
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	if !span.IsRecording() {
+		return nil
+	}
+
+	return &Span{tracer: t, ctx: ctx, span: span}
+}
+
+// Flush exports spans that ended since it was last called. Functions call it once they've handled each event, since
+// Lambda may freeze them right after, see middleware.Tracing
+func (t *Tracer) Flush() error {
+	if t == nil || t.pending == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultExportTimeout)
+	defer cancel()
+
+	return t.pending.ForceFlush(ctx)
+}
+
+// pendingSpans is a sdktrace.SpanProcessor keeping up to maxPendingSpans ended spans until they're flushed. Unlike
+// the SDK's batch processor, it never exports in the background, which Lambda would freeze midway
+type pendingSpans struct {
+	exporter sdktrace.SpanExporter
+
+	lock    sync.Mutex
+	spans   []sdktrace.ReadOnlySpan
+	dropped int
+}
+
+func (p *pendingSpans) OnStart(parent context.Context, span sdktrace.ReadWriteSpan) {}
+
+func (p *pendingSpans) OnEnd(span sdktrace.ReadOnlySpan) {
+	if !span.SpanContext().IsSampled() {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if len(p.spans) < maxPendingSpans {
+		p.spans = append(p.spans, span)
+	} else {
+		p.dropped++
+	}
+}
+
+// ForceFlush exports pending spans, dropped ones are logged
+func (p *pendingSpans) ForceFlush(ctx context.Context) error {
+	p.lock.Lock()
+	spans, dropped := p.spans, p.dropped
+	p.spans, p.dropped = nil, 0
+	p.lock.Unlock()
+
+	if dropped > 0 {
+		log.Printf("Dropped %d spans, more than %d ended before spans were exported", dropped, maxPendingSpans)
+	}
+
+	if len(spans) == 0 {
+		return nil
+	}
+
+	return p.exporter.ExportSpans(ctx, spans)
+}
+
+func (p *pendingSpans) Shutdown(ctx context.Context) error {
+	if err := p.ForceFlush(ctx); err != nil {
+		return err
+	}
+
+	return p.exporter.Shutdown(ctx)
+}
+
+// Configure sets Default up with the exporter NewExporterFromEnv returns, every binary calls it before handling
+// anything
+func Configure() error {
+	exporter, err := NewExporterFromEnv()
+	if err != nil {
+		return err
+	}
+
+	Default = NewTracer(exporter, serviceNameFromEnv())
+
+	return nil
+}
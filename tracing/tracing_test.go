@@ -0,0 +1,129 @@
+package tracing
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func headers(values map[string]string) func(string) string {
+	return func(name string) string {
+		return values[name]
+	}
+}
+
+func TestExtract(t *testing.T) {
+	sc := Extract(headers(map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}))
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.IsRemote())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+
+	sc = Extract(headers(map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}))
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.IsSampled())
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-not-hex-01",
+	} {
+		assert.False(t, Extract(headers(map[string]string{"traceparent": header})).IsValid(), header)
+	}
+}
+
+func TestTracerStartsTracesAndChildren(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := NewTracer(exporter, "books")
+
+	root := tracer.Start("GET /books", KindServer, trace.SpanContext{})
+	child := root.StartChild("query books", KindClient)
+	child.SetAttribute("db.sql.table", "books")
+	child.SetError(errors.New("connection refused"))
+	child.End()
+	root.End()
+
+	assert.True(t, root.SpanContext().IsValid())
+	assert.Equal(t, root.SpanContext().TraceID(), child.SpanContext().TraceID())
+	assert.NotEqual(t, root.SpanContext().SpanID(), child.SpanContext().SpanID())
+
+	assert.Equal(t, 0, len(exporter.GetSpans()))
+	assert.Equal(t, nil, tracer.Flush())
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "query books", spans[0].Name)
+	assert.Equal(t, KindClient, spans[0].SpanKind)
+	assert.Equal(t, root.SpanContext().SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, []attribute.KeyValue{attribute.String("db.sql.table", "books")}, spans[0].Attributes)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "connection refused", spans[0].Status.Description)
+	assert.Equal(t, "exception", spans[0].Events[0].Name)
+	assert.False(t, spans[1].Parent.IsValid())
+
+	serviceName, _ := spans[1].Resource.Set().Value("service.name")
+	assert.Equal(t, "books", serviceName.AsString())
+
+	assert.Equal(t, nil, tracer.Flush())
+	assert.Equal(t, 2, len(exporter.GetSpans()))
+}
+
+func TestTracerContinuesSampledTracesOnly(t *testing.T) {
+	tracer := NewTracer(tracetest.NewInMemoryExporter(), "books")
+	parent := Extract(headers(map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}))
+
+	span := tracer.Start("GET /books", KindServer, parent)
+	assert.Equal(t, parent.TraceID(), span.SpanContext().TraceID())
+
+	parent = parent.WithTraceFlags(0)
+	assert.Nil(t, tracer.Start("GET /books", KindServer, parent))
+}
+
+func TestTracerWithoutExporterRecordsNothing(t *testing.T) {
+	tracer := NewTracer(nil, "books")
+	span := tracer.Start("GET /books", KindServer, trace.SpanContext{})
+	assert.Nil(t, span)
+
+	// Nil spans can be used all the same
+	child := span.StartChild("query books", KindClient)
+	child.SetName("query authors")
+	child.SetAttribute("db.sql.table", "authors")
+	child.SetError(errors.New("connection refused"))
+	child.SetErrorStatus("Service Unavailable")
+	child.End()
+
+	assert.Nil(t, child)
+	assert.False(t, span.SpanContext().IsValid())
+	assert.Equal(t, nil, tracer.Flush())
+}
+
+func TestTracerBoundsPendingSpansAndLogsDroppedOnes(t *testing.T) {
+	defer func(previous int) { maxPendingSpans = previous }(maxPendingSpans)
+	maxPendingSpans = 2
+
+	output := &bytes.Buffer{}
+	log.SetOutput(output)
+	defer log.SetOutput(os.Stderr)
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := NewTracer(exporter, "books")
+	for index := 0; index < 3; index++ {
+		tracer.Start("GET /books", KindServer, trace.SpanContext{}).End()
+	}
+
+	tracer.Flush()
+	assert.Equal(t, 2, len(exporter.GetSpans()))
+	assert.Contains(t, output.String(), "Dropped 1 spans, more than 2 ended before spans were exported")
+}
@@ -1,33 +1,356 @@
 package utils
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/felipefill/books/apierror"
+	"github.com/felipefill/books/migrations"
 	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
 	"github.com/jinzhu/gorm"
 	_ "github.com/jinzhu/gorm/dialects/postgres" // Postgres dialect for GORM
+	_ "github.com/jinzhu/gorm/dialects/sqlite"   // SQLite dialect for GORM
 )
 
 var _db *gorm.DB
+var _dbCheckedAt time.Time
+var _dbLock sync.Mutex
 
-// GetDB gets DB connection, in case of failure it will panic
-func GetDB() *gorm.DB {
+// _dbSuspect is set when a query failed, see recheckOnError
+var _dbSuspect int32
+
+// dbCheckInterval is how long cached connection is trusted before GetDB pings it again
+var dbCheckInterval = time.Minute
+
+var defaultSQLitePath = "books.db"
+var defaultSSLMode = "require"
+var defaultMaxOpenConns = 5
+var defaultMaxIdleConns = 2
+var defaultConnMaxLifetime = 5 * time.Minute
+var defaultConnectTimeout = 5 * time.Second
+
+// ErrNoDatabase is what NewDBConfigFromEnv, and so GetDB, fail with when DB_DRIVER is memory. Only books are kept in
+// memory, what needs a database, like API keys, scrap jobs or rate limit buckets kept in it, isn't available
+var ErrNoDatabase = errors.New("DB_DRIVER is memory, there's no database for API keys, scrap jobs or rate limit buckets")
+
+// InMemory tells whether DB_DRIVER is memory, see NewBookRepository and ErrNoDatabase
+func InMemory() bool {
+	return os.Getenv("DB_DRIVER") == "memory"
+}
+
+// UnavailableError tells that database can't be used right now, handlers answer 503 when they get one
+type UnavailableError struct {
+	Reason string
+	Err    error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("Database is unavailable: %s", e.Reason)
+}
+
+// IsUnavailable tells whether err comes from database being unavailable
+func IsUnavailable(err error) bool {
+	_, ok := err.(*UnavailableError)
+	return ok
+}
+
+// UnavailableResponse is what handlers answer to request when database is unavailable
+func UnavailableResponse(request events.APIGatewayProxyRequest, err error) events.APIGatewayProxyResponse {
+	return apierror.New(503, apierror.DatabaseUnavailable, err.Error()).WithHeader("Retry-After", "5").Response(request)
+}
+
+// DBConfig tells how to connect to database
+type DBConfig struct {
+	// Driver is either postgres or sqlite3
+	Driver string
+
+	// URL is a postgres:// URL for Postgres and a file path for SQLite
+	URL string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+
+	// ConnectTimeout bounds both establishing a connection and checking cached one is still alive
+	ConnectTimeout time.Duration
+}
+
+// NewDBConfigFromEnv creates a DBConfig from environment variables. Postgres is reached through DATABASE_URL or, when it's
+// not set, DB_HOST, DB_NAME, DB_USER and DB_PSWD. DB_SSLMODE applies unless DATABASE_URL has its own sslmode. It fails
+// with ErrNoDatabase when DB_DRIVER is memory
+func NewDBConfigFromEnv() (DBConfig, error) {
+	config := DBConfig{
+		Driver:          os.Getenv("DB_DRIVER"),
+		MaxOpenConns:    getIntEnvOrDefault("DB_MAX_OPEN_CONNS", defaultMaxOpenConns),
+		MaxIdleConns:    getIntEnvOrDefault("DB_MAX_IDLE_CONNS", defaultMaxIdleConns),
+		ConnMaxLifetime: getDurationEnvOrDefault("DB_CONN_MAX_LIFETIME", defaultConnMaxLifetime),
+		ConnectTimeout:  getDurationEnvOrDefault("DB_CONNECT_TIMEOUT", defaultConnectTimeout),
+	}
+
+	switch config.Driver {
+	case "", "postgres":
+		config.Driver = "postgres"
+
+		postgresURL, err := getPostgresURL(config.ConnectTimeout)
+		if err != nil {
+			return config, err
+		}
+
+		config.URL = postgresURL
+	case "sqlite3":
+		config.URL = os.Getenv("DB_PATH")
+		if config.URL == "" {
+			config.URL = defaultSQLitePath
+		}
+
+		// SQLite has a single writer, sharing one connection avoids "database is locked" errors
+		config.MaxOpenConns = 1
+	case "memory":
+		return config, ErrNoDatabase
+	default:
+		return config, fmt.Errorf("Unknown DB_DRIVER %s, it must be postgres, sqlite3 or memory", config.Driver)
+	}
+
+	return config, nil
+}
+
+func getPostgresURL(connectTimeout time.Duration) (string, error) {
+	postgresURL := &url.URL{Scheme: "postgres"}
+
+	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
+		parsedURL, err := url.Parse(databaseURL)
+		if err != nil || (parsedURL.Scheme != "postgres" && parsedURL.Scheme != "postgresql") {
+			return "", fmt.Errorf("DATABASE_URL must be a postgres:// URL")
+		}
+
+		postgresURL = parsedURL
+	} else {
+		host, name, user, pswd, err := getDatabaseInfo()
+		if err != nil {
+			return "", err
+		}
+
+		postgresURL.Host = host
+		postgresURL.Path = "/" + name
+		postgresURL.User = url.UserPassword(user, pswd)
+	}
+
+	query := postgresURL.Query()
+	if query.Get("sslmode") == "" || os.Getenv("DB_SSLMODE") != "" {
+		query.Set("sslmode", getEnvOrDefault("DB_SSLMODE", defaultSSLMode))
+	}
+
+	if query.Get("connect_timeout") == "" && connectTimeout > 0 {
+		query.Set("connect_timeout", strconv.Itoa(int(math.Ceil(connectTimeout.Seconds()))))
+	}
+
+	postgresURL.RawQuery = query.Encode()
+
+	return postgresURL.String(), nil
+}
+
+// GetDB gets DB connection, DB_DRIVER chooses the database: postgres (default) or sqlite3, which is stored in DB_PATH.
+// Cached connection is trusted for dbCheckInterval, or until a query through it fails, then it's pinged again and
+// replaced when it went bad. It fails with an UnavailableError when database can't be reached or its schema is behind,
+// migrations must be applied first in that case
+func GetDB() (*gorm.DB, error) {
+	_dbLock.Lock()
+	defer _dbLock.Unlock()
+
+	if _db != nil && atomic.LoadInt32(&_dbSuspect) == 0 && time.Since(_dbCheckedAt) < dbCheckInterval {
+		return _db, nil
+	}
+
+	db, err := connect()
+	if err != nil {
+		return nil, err
+	}
+
+	if db != _db {
+		if err := migrations.CheckUpToDate(db); err != nil {
+			db.Close()
+			return nil, &UnavailableError{Reason: err.Error(), Err: err}
+		}
+
+		_db = db
+	}
+
+	_dbCheckedAt = time.Now()
+	atomic.StoreInt32(&_dbSuspect, 0)
+
+	return _db, nil
+}
+
+// recheckOnError registers callbacks making the next GetDB ping cached connection once a query through db failed,
+// since connection going bad may be why. They don't take _dbLock, GetDB itself queries while holding it
+func recheckOnError(db *gorm.DB) {
+	callbacks := db.Callback()
+	processors := map[string]func() *gorm.CallbackProcessor{
+		"create":    callbacks.Create,
+		"query":     callbacks.Query,
+		"update":    callbacks.Update,
+		"delete":    callbacks.Delete,
+		"row_query": callbacks.RowQuery,
+	}
+
+	for operation, processor := range processors {
+		processor().After("gorm:"+operation).Register("utils:recheck_after_"+operation, func(scope *gorm.Scope) {
+			if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+				atomic.StoreInt32(&_dbSuspect, 1)
+			}
+		})
+	}
+}
+
+// connect returns cached connection when it's still alive or a new one otherwise, which isn't cached since its
+// schema wasn't checked yet. _dbLock must be held
+func connect() (*gorm.DB, error) {
+	config, configErr := NewDBConfigFromEnv()
 	if _db != nil {
-		return _db
+		if err := ping(_db.DB(), config.ConnectTimeout); err == nil {
+			return _db, nil
+		}
+
+		log.Printf("Cached database connection went bad, reconnecting")
+		_db.Close()
+		_db = nil
+	}
+
+	if configErr != nil {
+		return nil, &UnavailableError{Reason: configErr.Error(), Err: configErr}
+	}
+
+	db, err := OpenDB(config)
+	if err != nil {
+		log.Printf("Could not connect to database: %s", err.Error())
+		return nil, &UnavailableError{Reason: "could not connect, see logs for details", Err: err}
+	}
+
+	return db, nil
+}
+
+// OpenDB connects to database described by config without checking its schema, it's meant for applying migrations.
+// Query durations are recorded into metrics, queries are traced, see TraceDB, and failing ones make GetDB ping again
+func OpenDB(config DBConfig) (*gorm.DB, error) {
+	sqlDB, err := sql.Open(config.Driver, config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+
+	if err := ping(sqlDB, config.ConnectTimeout); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+
+	db, err := gorm.Open(config.Driver, sqlDB)
+	if err != nil {
+		return nil, err
+	}
+
+	instrument(db)
+	traceQueries(db)
+	recheckOnError(db)
+
+	return db, nil
+}
+
+func ping(db *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return db.PingContext(ctx)
+}
+
+// NewBookRepository creates the BookRepository handlers use, books are kept in memory when DB_DRIVER is "memory"
+// (only meant for running locally, nothing outlives the process) and in database otherwise. Database is only
+// reached once a book is needed, so its errors (UnavailableError included) come from repository methods
+func NewBookRepository() model.BookRepository {
+	if InMemory() {
+		return model.NewInMemoryBookRepository()
+	}
+
+	return &dbBookRepository{}
+}
+
+// dbBookRepository is a GormBookRepository over whatever GetDB returns at each call, its queries are traced within span
+// when there's one, see TraceBookRepository
+type dbBookRepository struct {
+	span *tracing.Span
+}
+
+func (r *dbBookRepository) repository() (model.BookRepository, error) {
+	db, err := GetDB()
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewGormBookRepository(TraceDB(db, r.span)), nil
+}
+
+func (r *dbBookRepository) FindByID(id uint) (*model.Book, error) {
+	repository, err := r.repository()
+	if err != nil {
+		return nil, err
+	}
+
+	return repository.FindByID(id)
+}
+
+func (r *dbBookRepository) GetAll() (*model.Books, error) {
+	repository, err := r.repository()
+	if err != nil {
+		return nil, err
 	}
 
-	host, name, user, pswd := getDatabaseInfo()
-	db, err := gorm.Open("postgres", fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=require", user, pswd, host, name))
+	return repository.GetAll()
+}
+
+func (r *dbBookRepository) Each(fn func(model.Book) error) error {
+	repository, err := r.repository()
 	if err != nil {
-		panic(fmt.Sprintf("Could not connect to database: %s", err.Error()))
+		return err
 	}
 
-	_db = db
-	migrateSchema(_db)
+	return repository.Each(fn)
+}
+
+func (r *dbBookRepository) StoreOrMerge(book *model.Book, policy model.MergePolicy) (model.MergeResult, error) {
+	repository, err := r.repository()
+	if err != nil {
+		return model.MergeResult{}, err
+	}
 
-	return _db
+	return repository.StoreOrMerge(book, policy)
+}
+
+func (r *dbBookRepository) UpsertBooks(books []model.Book, policy model.MergePolicy) (model.UpsertSummary, error) {
+	repository, err := r.repository()
+	if err != nil {
+		return model.UpsertSummary{}, err
+	}
+
+	return repository.UpsertBooks(books, policy)
 }
 
 // InjectDB injects given database
@@ -37,26 +360,54 @@ func InjectDB(db *sql.DB) {
 		panic("Failed to convert sql.DB to gorm.DB")
 	}
 
+	_dbLock.Lock()
+	defer _dbLock.Unlock()
+
 	_db = gormDB
+	_dbCheckedAt = time.Now()
 }
 
-func migrateSchema(db *gorm.DB) {
-	db.AutoMigrate(&model.Book{})
+func getDatabaseInfo() (host string, name string, user string, pswd string, err error) {
+	missing := make([]string, 0)
+	get := func(name string) string {
+		value := os.Getenv(name)
+		if value == "" {
+			missing = append(missing, name)
+		}
+
+		return value
+	}
+
+	host, name, user, pswd = get("DB_HOST"), get("DB_NAME"), get("DB_USER"), get("DB_PSWD")
+	if len(missing) > 0 {
+		err = fmt.Errorf("DATABASE_URL or DB_HOST, DB_NAME, DB_USER and DB_PSWD must be set, missing %s", strings.Join(missing, ", "))
+	}
+
+	return host, name, user, pswd, err
 }
 
-func getDatabaseInfo() (host string, name string, user string, pswd string) {
-	return mustGetEnvVar("DB_HOST"),
-		mustGetEnvVar("DB_NAME"),
-		mustGetEnvVar("DB_USER"),
-		mustGetEnvVar("DB_PSWD")
+func getEnvOrDefault(name string, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return defaultValue
 }
 
-func mustGetEnvVar(v string) string {
-	envVar := os.Getenv(v)
+func getIntEnvOrDefault(name string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
 
-	if envVar == "" {
-		panic(fmt.Sprintf("Failed to retrieve %s environment variable", v))
+	return value
+}
+
+func getDurationEnvOrDefault(name string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(name))
+	if err != nil {
+		return defaultValue
 	}
 
-	return envVar
+	return value
 }
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/felipefill/books/migrations"
+	"github.com/felipefill/books/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// withMigratedSQLite points DB_DRIVER and DB_PATH to an up to date SQLite database until the returned function is
+// called, cached connection is dropped before and after
+func withMigratedSQLite(t *testing.T) func() {
+	dir, _ := ioutil.TempDir("", "books")
+	os.Setenv("DB_DRIVER", "sqlite3")
+	os.Setenv("DB_PATH", filepath.Join(dir, "books.db"))
+
+	config, _ := NewDBConfigFromEnv()
+	db, err := OpenDB(config)
+	assert.Equal(t, nil, err)
+	_, err = migrations.Up(db)
+	assert.Equal(t, nil, err)
+	db.Close()
+
+	_db = nil
+	return func() {
+		if _db != nil {
+			_db.Close()
+			_db = nil
+		}
+
+		os.Unsetenv("DB_DRIVER")
+		os.Unsetenv("DB_PATH")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestGetDBTrustsCachedConnectionUntilAQueryFails(t *testing.T) {
+	defer withMigratedSQLite(t)()
+
+	cached, err := GetDB()
+	assert.Equal(t, nil, err)
+
+	// Connection isn't pinged on each call, so it's returned even though it went bad
+	cached.DB().Close()
+	db, _ := GetDB()
+	assert.True(t, db == cached)
+
+	assert.NotEqual(t, nil, db.First(&model.APIKey{}).Error)
+
+	db, err = GetDB()
+	assert.Equal(t, nil, err)
+	assert.True(t, db != cached)
+	assert.Equal(t, nil, db.DB().Ping())
+
+	again, _ := GetDB()
+	assert.True(t, again == db)
+}
+
+func TestGetDBChecksCachedConnectionAgainAfterInterval(t *testing.T) {
+	defer withMigratedSQLite(t)()
+	defer func(previous time.Duration) { dbCheckInterval = previous }(dbCheckInterval)
+
+	cached, _ := GetDB()
+	cached.DB().Close()
+
+	dbCheckInterval = 0
+	db, err := GetDB()
+	assert.Equal(t, nil, err)
+	assert.True(t, db != cached)
+}
+
+func TestGetDBHasNoDatabaseInMemory(t *testing.T) {
+	os.Setenv("DB_DRIVER", "memory")
+	defer os.Unsetenv("DB_DRIVER")
+
+	_, err := NewDBConfigFromEnv()
+	assert.Equal(t, ErrNoDatabase, err)
+
+	_, err = GetDB()
+	assert.True(t, IsUnavailable(err))
+	assert.Equal(t, "Database is unavailable: "+ErrNoDatabase.Error(), err.Error())
+}
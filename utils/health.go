@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/felipefill/books/migrations"
+)
+
+// Version is the build version health reports, it's set when building with
+// -ldflags "-X github.com/felipefill/books/utils.Version=..."
+var Version = "dev"
+
+// DBHealth tells whether database can be reached, how long reaching it took and which version its schema is at
+type DBHealth struct {
+	Driver     string             `json:"driver"`
+	Reachable  bool               `json:"reachable"`
+	LatencyMs  float64            `json:"latencyMs"`
+	Migrations *migrations.Status `json:"migrations,omitempty"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// Healthy tells whether handlers can use database, it must be reachable and its schema up to date
+func (h DBHealth) Healthy() bool {
+	return h.Reachable && h.Error == ""
+}
+
+// CheckDBHealth pings database through the same connection GetDB uses and reads its migration status. Books kept in
+// memory (DB_DRIVER is "memory") need no database, so there's nothing to check
+func CheckDBHealth() DBHealth {
+	if InMemory() {
+		return DBHealth{Driver: "memory", Reachable: true}
+	}
+
+	_dbLock.Lock()
+	defer _dbLock.Unlock()
+
+	health := DBHealth{Driver: getEnvOrDefault("DB_DRIVER", "postgres")}
+
+	// connect pings cached connection, or a new one when it went bad, so that ping is the one timed
+	start := time.Now()
+	db, err := connect()
+	_dbCheckedAt = time.Now()
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+
+	health.Reachable = true
+	health.LatencyMs = float64(_dbCheckedAt.Sub(start)) / float64(time.Millisecond)
+	atomic.StoreInt32(&_dbSuspect, 0)
+
+	status, err := migrations.CurrentStatus(db)
+	if err != nil {
+		health.Error = fmt.Sprintf("Failed to read schema version: %s", err.Error())
+	} else {
+		health.Migrations = &status
+		if !status.UpToDate() {
+			health.Error = migrations.CheckUpToDate(db).Error()
+		}
+	}
+
+	// A new connection is kept the way GetDB would keep it, so that requests coming next don't connect again
+	if db != _db {
+		if health.Healthy() {
+			_db = db
+		} else {
+			db.Close()
+		}
+	}
+
+	return health
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDBHealthRecordsWhenCachedConnectionWasChecked(t *testing.T) {
+	defer withMigratedSQLite(t)()
+
+	cached, err := GetDB()
+	assert.Equal(t, nil, err)
+
+	_dbCheckedAt = time.Time{}
+	health := CheckDBHealth()
+
+	assert.True(t, health.Healthy())
+	assert.True(t, time.Since(_dbCheckedAt) < time.Minute)
+
+	db, _ := GetDB()
+	assert.True(t, db == cached)
+}
+
+func TestCheckDBHealthReplacesCachedConnectionThatWentBad(t *testing.T) {
+	defer withMigratedSQLite(t)()
+
+	cached, _ := GetDB()
+	cached.DB().Close()
+
+	health := CheckDBHealth()
+
+	assert.True(t, health.Healthy())
+	assert.True(t, _db != cached)
+	assert.Equal(t, nil, _db.DB().Ping())
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"time"
+
+	"github.com/felipefill/books/metrics"
+	"github.com/jinzhu/gorm"
+)
+
+var queryDuration = metrics.NewHistogram("books_db_query_duration_seconds", "How long database queries took, by operation and table", metrics.Seconds, metrics.DurationBuckets, "operation", "table")
+
+const queryStartKey = "metrics:query_start"
+const unknownTable = "unknown"
+
+// instrument registers callbacks timing each GORM operation of db into books_db_query_duration_seconds, only the
+// statement itself is timed, hooks and transaction handling around it aren't
+func instrument(db *gorm.DB) {
+	callbacks := db.Callback()
+	timeAround(callbacks.Create, "create", "gorm:create")
+	timeAround(callbacks.Query, "query", "gorm:query")
+	timeAround(callbacks.Update, "update", "gorm:update")
+	timeAround(callbacks.Delete, "delete", "gorm:delete")
+	timeAround(callbacks.RowQuery, "row_query", "gorm:row_query")
+}
+
+// timeAround registers callbacks around callbackName, processor is called for each of them since GORM's processors
+// keep the position they were given
+func timeAround(processor func() *gorm.CallbackProcessor, operation string, callbackName string) {
+	processor().Before(callbackName).Register("metrics:before_"+operation, func(scope *gorm.Scope) {
+		scope.InstanceSet(queryStartKey, time.Now())
+	})
+
+	processor().After(callbackName).Register("metrics:after_"+operation, func(scope *gorm.Scope) {
+		start, ok := scope.InstanceGet(queryStartKey)
+		if !ok {
+			return
+		}
+
+		table := scope.TableName()
+		if table == "" {
+			table = unknownTable
+		}
+
+		queryDuration.ObserveSince(start.(time.Time), operation, table)
+	})
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentRecordsQueryDurations(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	instrument(db)
+	db.AutoMigrate(&model.APIKey{})
+
+	before := queryDuration.Count("create", "api_keys")
+	db.Create(&model.APIKey{Name: "ci", Hash: "hash"})
+	assert.Equal(t, before+1, queryDuration.Count("create", "api_keys"))
+
+	before = queryDuration.Count("query", "api_keys")
+	db.First(&model.APIKey{})
+	assert.Equal(t, before+1, queryDuration.Count("query", "api_keys"))
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
+	"github.com/jinzhu/gorm"
+)
+
+// parentSpanKey is the GORM setting TraceDB keeps the span queries are traced within
+const parentSpanKey = "tracing:parent_span"
+
+const querySpanKey = "tracing:query_span"
+
+// TraceDB returns db tracing each of its queries as a child of span, db itself is left untouched. Queries made through
+// a db that isn't traced aren't either
+func TraceDB(db *gorm.DB, span *tracing.Span) *gorm.DB {
+	if span == nil {
+		return db
+	}
+
+	return db.Set(parentSpanKey, span)
+}
+
+// TraceBookRepository returns books tracing its queries as children of span when it's backed by database
+func TraceBookRepository(books model.BookRepository, span *tracing.Span) model.BookRepository {
+	if _, ok := books.(*dbBookRepository); !ok || span == nil {
+		return books
+	}
+
+	return &dbBookRepository{span: span}
+}
+
+// traceQueries registers callbacks starting a span for each GORM operation of db that's traced, see TraceDB
+func traceQueries(db *gorm.DB) {
+	callbacks := db.Callback()
+	traceAround(callbacks.Create, "create", "gorm:create")
+	traceAround(callbacks.Query, "query", "gorm:query")
+	traceAround(callbacks.Update, "update", "gorm:update")
+	traceAround(callbacks.Delete, "delete", "gorm:delete")
+	traceAround(callbacks.RowQuery, "row_query", "gorm:row_query")
+}
+
+// traceAround registers callbacks around callbackName, see timeAround
+func traceAround(processor func() *gorm.CallbackProcessor, operation string, callbackName string) {
+	processor().Before(callbackName).Register("tracing:before_"+operation, func(scope *gorm.Scope) {
+		parent, ok := scope.Get(parentSpanKey)
+		if !ok {
+			return
+		}
+
+		if span := parent.(*tracing.Span).StartChild(operation, tracing.KindClient); span != nil {
+			scope.InstanceSet(querySpanKey, span)
+		}
+	})
+
+	processor().After(callbackName).Register("tracing:after_"+operation, func(scope *gorm.Scope) {
+		value, ok := scope.InstanceGet(querySpanKey)
+		if !ok {
+			return
+		}
+
+		table := scope.TableName()
+		if table == "" {
+			table = unknownTable
+		}
+
+		span := value.(*tracing.Span)
+		span.SetName(operation + " " + table)
+		span.SetAttribute("db.system", scope.Dialect().GetName())
+		span.SetAttribute("db.operation", operation)
+		span.SetAttribute("db.sql.table", table)
+		span.SetAttribute("db.statement", scope.SQL)
+		span.SetAttribute("db.rows_affected", scope.DB().RowsAffected)
+
+		if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+			span.SetError(err)
+		}
+
+		span.End()
+	})
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/felipefill/books/model"
+	"github.com/felipefill/books/tracing"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceDBTracesQueriesWithinSpan(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	assert.Equal(t, nil, err)
+	defer db.Close()
+
+	traceQueries(db)
+	db.AutoMigrate(&model.APIKey{})
+
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := tracing.NewTracer(exporter, "books")
+	parent := tracer.Start("POST /book", tracing.KindServer, trace.SpanContext{})
+
+	TraceDB(db, parent).Create(&model.APIKey{Name: "ci", Hash: "hash"})
+	TraceDB(db, parent).Where("name = ?", "nobody").First(&model.APIKey{})
+	db.First(&model.APIKey{})
+	tracer.Flush()
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+
+	created := spans[0]
+	attributes := attribute.NewSet(created.Attributes...)
+	system, _ := attributes.Value("db.system")
+	table, _ := attributes.Value("db.sql.table")
+	rowsAffected, _ := attributes.Value("db.rows_affected")
+	statement, _ := attributes.Value("db.statement")
+
+	assert.Equal(t, "create api_keys", created.Name)
+	assert.Equal(t, tracing.KindClient, created.SpanKind)
+	assert.Equal(t, parent.SpanContext().SpanID(), created.Parent.SpanID())
+	assert.Equal(t, "sqlite3", system.AsString())
+	assert.Equal(t, "api_keys", table.AsString())
+	assert.Equal(t, int64(1), rowsAffected.AsInt64())
+	assert.Contains(t, statement.AsString(), `INSERT INTO "api_keys"`)
+
+	// Not finding a record isn't a failure
+	assert.Equal(t, "query api_keys", spans[1].Name)
+	assert.Equal(t, codes.Unset, spans[1].Status.Code)
+}
+
+func TestTraceBookRepository(t *testing.T) {
+	parent := tracing.NewTracer(tracetest.NewInMemoryExporter(), "books").Start("GET /books", tracing.KindServer, trace.SpanContext{})
+
+	memory := model.NewInMemoryBookRepository()
+	assert.Equal(t, memory, TraceBookRepository(memory, parent))
+
+	books := &dbBookRepository{}
+	assert.Equal(t, books, TraceBookRepository(books, nil))
+	assert.Equal(t, &dbBookRepository{span: parent}, TraceBookRepository(books, parent))
+}